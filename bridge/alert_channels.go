@@ -0,0 +1,282 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	AlertChannelWebhook  = "webhook"
+	AlertChannelSMTP     = "smtp"
+	AlertChannelTelegram = "telegram"
+	AlertChannelDiscord  = "discord"
+
+	defaultTelegramBaseURL = "https://api.telegram.org"
+	defaultDiscordBaseURL  = "https://discord.com"
+
+	alertSendTimeout = 15 * time.Second
+)
+
+// AlertChannel is one destination for alerts. Which fields apply depends on Type.
+type AlertChannel struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"` // webhook / smtp / telegram / discord
+
+	// webhook, and discord when posting to a webhook URL instead of a bot
+	URL      string            `yaml:"url" json:"url"`
+	Method   string            `yaml:"method" json:"method"`
+	Headers  map[string]string `yaml:"headers" json:"headers"`
+	Template string            `yaml:"template" json:"template"` // text/template rendering the request body
+
+	// telegram and discord bots
+	BaseURL string `yaml:"baseURL" json:"baseURL"`
+	Token   string `yaml:"token" json:"token"`
+	ChatID  string `yaml:"chatId" json:"chatId"` // discord channel id for bots
+
+	// smtp
+	Host     string   `yaml:"host" json:"host"`
+	Port     int      `yaml:"port" json:"port"`
+	Security string   `yaml:"security" json:"security"` // starttls / tls / none
+	Username string   `yaml:"username" json:"username"`
+	Password string   `yaml:"password" json:"password"`
+	From     string   `yaml:"from" json:"from"`
+	To       []string `yaml:"to" json:"to"`
+}
+
+// Alert is what a rule hands to its channels, and the data of webhook templates.
+type Alert struct {
+	Rule    string `json:"rule"`
+	Event   string `json:"event"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+	Time    int64  `json:"time"`
+	Payload any    `json:"payload"`
+}
+
+var alertTemplateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+const defaultWebhookTemplate = `{"rule":{{json .Rule}},"event":{{json .Event}},"title":{{json .Title}},"message":{{json .Message}},"time":{{.Time}},"payload":{{json .Payload}}}`
+
+func sendAlert(ctx context.Context, channel AlertChannel, alert Alert) error {
+	ctx, cancel := context.WithTimeout(ctx, alertSendTimeout)
+	defer cancel()
+
+	switch channel.Type {
+	case AlertChannelWebhook:
+		return sendWebhookAlert(ctx, channel, alert)
+	case AlertChannelSMTP:
+		return sendSMTPAlert(ctx, channel, alert)
+	case AlertChannelTelegram:
+		return sendTelegramAlert(ctx, channel, alert)
+	case AlertChannelDiscord:
+		return sendDiscordAlert(ctx, channel, alert)
+	default:
+		return errors.New("unknown channel type: " + channel.Type)
+	}
+}
+
+func sendWebhookAlert(ctx context.Context, channel AlertChannel, alert Alert) error {
+	text := channel.Template
+	if text == "" {
+		text = defaultWebhookTemplate
+	}
+	tmpl, err := template.New(channel.Name).Funcs(alertTemplateFuncs).Parse(text)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, alert); err != nil {
+		return err
+	}
+	method := channel.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	headers := map[string]string{"Content-Type": "application/json"}
+	for k, v := range channel.Headers {
+		headers[k] = v
+	}
+	return postAlert(ctx, method, channel.URL, headers, body.Bytes())
+}
+
+// sendTelegramAlert calls the Bot API sendMessage method.
+func sendTelegramAlert(ctx context.Context, channel AlertChannel, alert Alert) error {
+	if channel.Token == "" || channel.ChatID == "" {
+		return errors.New("telegram channels need a token and a chatId")
+	}
+	base := strings.TrimSuffix(channel.BaseURL, "/")
+	if base == "" {
+		base = defaultTelegramBaseURL
+	}
+	body, _ := json.Marshal(map[string]any{
+		"chat_id": channel.ChatID,
+		"text":    alertText(alert),
+	})
+	err := postAlert(ctx, http.MethodPost, base+"/bot"+channel.Token+"/sendMessage", map[string]string{"Content-Type": "application/json"}, body)
+	if err != nil {
+		return fmt.Errorf("telegram: %w", err)
+	}
+	return nil
+}
+
+// sendDiscordAlert posts to a webhook URL when one is set, otherwise as a bot to channel ChatID.
+func sendDiscordAlert(ctx context.Context, channel AlertChannel, alert Alert) error {
+	body, _ := json.Marshal(map[string]any{"content": alertText(alert)})
+	headers := map[string]string{"Content-Type": "application/json"}
+	if channel.URL != "" {
+		return postAlert(ctx, http.MethodPost, channel.URL, headers, body)
+	}
+	if channel.Token == "" || channel.ChatID == "" {
+		return errors.New("discord channels need a webhook url, or a token and a chatId")
+	}
+	base := strings.TrimSuffix(channel.BaseURL, "/")
+	if base == "" {
+		base = defaultDiscordBaseURL
+	}
+	headers["Authorization"] = "Bot " + channel.Token
+	return postAlert(ctx, http.MethodPost, base+"/api/v10/channels/"+channel.ChatID+"/messages", headers, body)
+}
+
+// postAlert drops the URL from request errors, since telegram bots and discord webhooks
+// carry their token in the path and the error ends up in the delivery log.
+func postAlert(ctx context.Context, method, rawURL string, headers map[string]string, body []byte) error {
+	if rawURL == "" {
+		return errors.New("missing url")
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return withoutURL(err)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return withoutURL(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	return nil
+}
+
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+func sendSMTPAlert(ctx context.Context, channel AlertChannel, alert Alert) error {
+	if channel.Host == "" || channel.From == "" || len(channel.To) == 0 {
+		return errors.New("smtp channels need a host, from and to")
+	}
+	port := channel.Port
+	if port == 0 {
+		switch channel.Security {
+		case "tls":
+			port = 465
+		case "none":
+			port = 25
+		default:
+			port = 587
+		}
+	}
+	addr := net.JoinHostPort(channel.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: channel.Host}
+
+	dialer := &net.Dialer{}
+	var conn net.Conn
+	var err error
+	if channel.Security == "tls" {
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	client, err := smtp.NewClient(conn, channel.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if channel.Security == "" || channel.Security == "starttls" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New("the smtp server does not offer STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return err
+		}
+	}
+	if channel.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", channel.Username, channel.Password, channel.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(channel.From); err != nil {
+		return err
+	}
+	for _, to := range channel.To {
+		if err := client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	message := "From: " + channel.From + "\r\n" +
+		"To: " + strings.Join(channel.To, ", ") + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", sanitizeHeader(alert.Title)) + "\r\n" +
+		"Date: " + time.UnixMilli(alert.Time).Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		strings.ReplaceAll(alert.Message, "\n", "\r\n") + "\r\n"
+	if _, err := w.Write([]byte(message)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func alertText(alert Alert) string {
+	if alert.Message == "" {
+		return alert.Title
+	}
+	return alert.Title + "\n" + alert.Message
+}
+
+func sanitizeHeader(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
@@ -0,0 +1,462 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	AlertsFilePath = "data/alerts.yaml"
+
+	alertQueueSize     = 256
+	alertCheckInterval = time.Hour
+	alertReloadEvery   = time.Minute
+	alertDeliveryLimit = 100
+)
+
+// AlertRule maps bus events to channels. Match compares top-level payload fields by their
+// string form, e.g. {success: "false"} for failed scheduled tasks.
+type AlertRule struct {
+	Name      string            `yaml:"name" json:"name"`
+	Events    []string          `yaml:"events" json:"events"`
+	Match     map[string]string `yaml:"match" json:"match"`
+	Channels  []string          `yaml:"channels" json:"channels"`
+	Title     string            `yaml:"title" json:"title"`         // text/template, defaults to the event name
+	Message   string            `yaml:"message" json:"message"`     // text/template, defaults to the payload as JSON
+	Dedup     int               `yaml:"dedup" json:"dedup"`         // seconds an identical alert is suppressed, -1 for never
+	DedupKey  string            `yaml:"dedupKey" json:"dedupKey"`   // text/template, defaults to the event and the match fields
+	RateLimit int               `yaml:"rateLimit" json:"rateLimit"` // alerts per hour, -1 for unlimited
+	Disabled  bool              `yaml:"disabled" json:"disabled"`
+}
+
+// AlertThresholds decide when the alerter raises its own events. Subscription quota and
+// expiry events come from the QuotaTracker and its quota.yaml.
+type AlertThresholds struct {
+	CertificateDays int `yaml:"certificateDays" json:"certificateDays"` // tls::expiring
+}
+
+type AlertSettings struct {
+	Disabled   bool            `yaml:"disabled" json:"disabled"`
+	Channels   []AlertChannel  `yaml:"channels" json:"channels"`
+	Rules      []AlertRule     `yaml:"rules" json:"rules"`
+	Thresholds AlertThresholds `yaml:"thresholds" json:"thresholds"`
+}
+
+type AlertDelivery struct {
+	Time       int64  `json:"time"`
+	Rule       string `json:"rule"`
+	Event      string `json:"event"`
+	Channel    string `json:"channel"`
+	Title      string `json:"title"`
+	Suppressed string `json:"suppressed,omitempty"` // dedup / rate-limit
+	Error      string `json:"error,omitempty"`
+}
+
+type alertEvent struct {
+	name    string
+	payload any
+}
+
+// Alerter forwards bus events to webhook, SMTP and chat bot channels according to alerts.yaml.
+type Alerter struct {
+	app *App
+
+	mu         sync.Mutex
+	sent       map[string]int64   // dedup key -> end of its dedup window
+	rate       map[string][]int64 // rule -> send times within the last hour
+	deliveries []AlertDelivery
+
+	// watched holds the events some rule listens to, so chatty events such as exec output
+	// never reach the queue
+	watched atomic.Pointer[map[string]bool]
+
+	queue chan alertEvent
+	stop  chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+func NewAlerter(a *App) *Alerter {
+	return &Alerter{
+		app:   a,
+		sent:  make(map[string]int64),
+		rate:  make(map[string][]int64),
+		queue: make(chan alertEvent, alertQueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+}
+
+func (al *Alerter) Start() {
+	al.Reload()
+	if al.app.Bus != nil {
+		al.app.Bus.Observe(al.observe)
+	}
+	go al.loop()
+}
+
+func (al *Alerter) Stop() {
+	al.once.Do(func() {
+		close(al.stop)
+	})
+	<-al.done
+}
+
+// Reload re-reads alerts.yaml; it is also picked up every minute.
+func (al *Alerter) Reload() {
+	settings := loadAlertSettings()
+	watched := map[string]bool{}
+	if !settings.Disabled {
+		for _, rule := range settings.Rules {
+			for _, event := range rule.Events {
+				watched[event] = true
+			}
+		}
+	}
+	al.watched.Store(&watched)
+}
+
+// Status reports the rules and recent deliveries; channel credentials are left out.
+func (al *Alerter) Status() map[string]any {
+	settings := loadAlertSettings()
+	rules := settings.Rules
+	if rules == nil {
+		rules = []AlertRule{}
+	}
+	return map[string]any{
+		"disabled":   settings.Disabled,
+		"channels":   al.Channels(),
+		"rules":      rules,
+		"thresholds": settings.Thresholds,
+		"deliveries": al.Deliveries(),
+	}
+}
+
+// Deliveries returns recent sends and suppressions, newest first.
+func (al *Alerter) Deliveries() []AlertDelivery {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	deliveries := slices.Clone(al.deliveries)
+	slices.Reverse(deliveries)
+	return deliveries
+}
+
+// Channels lists the configured channels without their credentials.
+func (al *Alerter) Channels() []map[string]string {
+	channels := []map[string]string{}
+	for _, channel := range loadAlertSettings().Channels {
+		channels = append(channels, map[string]string{"name": channel.Name, "type": channel.Type})
+	}
+	return channels
+}
+
+// Test sends a message straight to one channel, bypassing rules, dedup and rate limits.
+func (al *Alerter) Test(ctx context.Context, name, title, message string) error {
+	slog.Info("Alerter Test", "channel", name)
+
+	for _, channel := range loadAlertSettings().Channels {
+		if channel.Name == name {
+			return sendAlert(ctx, channel, Alert{
+				Rule:    "test",
+				Event:   "alert::test",
+				Title:   title,
+				Message: message,
+				Time:    time.Now().UnixMilli(),
+			})
+		}
+	}
+	return errors.New("channel not found: " + name)
+}
+
+func (al *Alerter) observe(event string, payload []any) {
+	// Deliveries are reported on the bus too; alerting on them would loop
+	if strings.HasPrefix(event, "alert::") {
+		return
+	}
+	if watched := al.watched.Load(); watched == nil || !(*watched)[event] {
+		return
+	}
+	var data any
+	if len(payload) > 0 {
+		data = payload[0]
+	}
+	select {
+	case al.queue <- alertEvent{event, data}:
+	default:
+		slog.Warn("Alerter: queue full, dropping", "event", event)
+	}
+}
+
+func (al *Alerter) loop() {
+	defer close(al.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-al.stop
+		cancel()
+	}()
+
+	check := time.NewTicker(alertCheckInterval)
+	defer check.Stop()
+	reload := time.NewTicker(alertReloadEvery)
+	defer reload.Stop()
+	al.checkCertificates(ctx)
+
+	for {
+		select {
+		case <-al.stop:
+			return
+		case event := <-al.queue:
+			settings := loadAlertSettings()
+			if settings.Disabled {
+				continue
+			}
+			al.dispatch(ctx, settings, event)
+		case <-reload.C:
+			al.Reload()
+		case <-check.C:
+			al.checkCertificates(ctx)
+		}
+	}
+}
+
+func (al *Alerter) checkCertificates(ctx context.Context) {
+	settings := loadAlertSettings()
+	if settings.Disabled {
+		return
+	}
+	for _, event := range certificateExpiryEvents(settings.Thresholds.CertificateDays) {
+		al.dispatch(ctx, settings, event)
+	}
+}
+
+func (al *Alerter) dispatch(ctx context.Context, settings AlertSettings, event alertEvent) {
+	// Round-trip through JSON so rules and templates see the payload as it is on the bus
+	var payload any
+	if b, err := json.Marshal(event.payload); err == nil {
+		_ = json.Unmarshal(b, &payload)
+	}
+
+	for _, rule := range settings.Rules {
+		if rule.Disabled || !slices.Contains(rule.Events, event.name) || !matchAlertPayload(rule.Match, payload) {
+			continue
+		}
+		alert := Alert{Rule: rule.Name, Event: event.name, Time: time.Now().UnixMilli(), Payload: payload}
+		alert.Title = renderAlertText(rule.Title, alert, event.name)
+		defaultMessage := ""
+		if payload != nil {
+			b, _ := json.Marshal(payload)
+			defaultMessage = string(b)
+		}
+		alert.Message = renderAlertText(rule.Message, alert, defaultMessage)
+
+		if reason := al.admit(rule, alert, alertDedupKey(rule, alert)); reason != "" {
+			al.record(AlertDelivery{Time: alert.Time, Rule: rule.Name, Event: event.name, Title: alert.Title, Suppressed: reason})
+			continue
+		}
+		for _, name := range rule.Channels {
+			delivery := AlertDelivery{Time: alert.Time, Rule: rule.Name, Event: event.name, Channel: name, Title: alert.Title}
+			index := slices.IndexFunc(settings.Channels, func(c AlertChannel) bool { return c.Name == name })
+			if index < 0 {
+				delivery.Error = "channel not found"
+			} else if err := sendAlert(ctx, settings.Channels[index], alert); err != nil {
+				delivery.Error = err.Error()
+			}
+			if delivery.Error != "" {
+				slog.Error("Alerter delivery", "rule", rule.Name, "channel", name, "error", delivery.Error)
+			}
+			al.record(delivery)
+		}
+	}
+}
+
+// alertDedupKey identifies alerts that count as identical. The default leaves the rendered
+// message out, since payloads such as core::crashed carry a pid or time that never repeats.
+func alertDedupKey(rule AlertRule, alert Alert) string {
+	key := rule.Name + "\x00" + alert.Event
+	if rule.DedupKey != "" {
+		return key + "\x00" + renderAlertText(rule.DedupKey, alert, "")
+	}
+	for _, field := range slices.Sorted(maps.Keys(rule.Match)) {
+		key += "\x00" + field + "=" + rule.Match[field]
+	}
+	return key
+}
+
+// admit applies the rule's dedup window and hourly rate limit, returning why an alert is
+// suppressed or "" when it may be sent.
+func (al *Alerter) admit(rule AlertRule, alert Alert, key string) string {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	now := alert.Time
+	if rule.Dedup > 0 && now < al.sent[key] {
+		return "dedup"
+	}
+	recent := slices.DeleteFunc(al.rate[rule.Name], func(t int64) bool { return now-t >= time.Hour.Milliseconds() })
+	if rule.RateLimit > 0 && len(recent) >= rule.RateLimit {
+		al.rate[rule.Name] = recent
+		return "rate-limit"
+	}
+	al.rate[rule.Name] = append(recent, now)
+	for k, until := range al.sent {
+		if until <= now {
+			delete(al.sent, k)
+		}
+	}
+	if rule.Dedup > 0 {
+		al.sent[key] = now + int64(rule.Dedup)*1000
+	}
+	return ""
+}
+
+func (al *Alerter) record(delivery AlertDelivery) {
+	al.mu.Lock()
+	al.deliveries = append(al.deliveries, delivery)
+	if overflow := len(al.deliveries) - alertDeliveryLimit; overflow > 0 {
+		al.deliveries = slices.Clone(al.deliveries[overflow:])
+	}
+	al.mu.Unlock()
+
+	if al.app.Bus != nil {
+		al.app.Bus.Emit("alert::delivered", delivery)
+	}
+}
+
+func matchAlertPayload(match map[string]string, payload any) bool {
+	if len(match) == 0 {
+		return true
+	}
+	fields, ok := payload.(map[string]any)
+	if !ok {
+		return false
+	}
+	for key, want := range match {
+		value, ok := fields[key]
+		if !ok || fmt.Sprint(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+func renderAlertText(text string, alert Alert, fallback string) string {
+	if text == "" {
+		return fallback
+	}
+	tmpl, err := template.New(alert.Rule).Funcs(alertTemplateFuncs).Parse(text)
+	if err != nil {
+		return text
+	}
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, alert); err != nil {
+		return text
+	}
+	return out.String()
+}
+
+// certificateExpiryEvents raises tls::expiring for inbound certificates of the core config that
+// expire within days.
+func certificateExpiryEvents(days int) []alertEvent {
+	b, err := os.ReadFile(GetPath(CoreConfigFilePath))
+	if err != nil {
+		return nil
+	}
+	var config struct {
+		Inbounds []struct {
+			Tag string `json:"tag"`
+			TLS *struct {
+				Enabled         bool            `json:"enabled"`
+				CertificatePath string          `json:"certificate_path"`
+				Certificate     json.RawMessage `json:"certificate"`
+			} `json:"tls"`
+		} `json:"inbounds"`
+	}
+	if err := json.Unmarshal(b, &config); err != nil {
+		return nil
+	}
+
+	var events []alertEvent
+	for _, inbound := range config.Inbounds {
+		if inbound.TLS == nil || !inbound.TLS.Enabled {
+			continue
+		}
+		var data []byte
+		source := inbound.TLS.CertificatePath
+		if source != "" {
+			path := source
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(GetPath(CoreWorkingDirectory), path)
+			}
+			if data, err = os.ReadFile(path); err != nil {
+				continue
+			}
+		} else if len(inbound.TLS.Certificate) > 0 {
+			// Inline certificates are a string or a list of lines
+			var lines []string
+			var text string
+			if json.Unmarshal(inbound.TLS.Certificate, &lines) == nil {
+				text = strings.Join(lines, "\n")
+			} else if json.Unmarshal(inbound.TLS.Certificate, &text) != nil {
+				continue
+			}
+			data, source = []byte(text), "inline"
+		} else {
+			continue
+		}
+
+		infos, err := parseCertificateInfos(data)
+		if err != nil || len(infos) == 0 {
+			continue
+		}
+		if leaf := infos[0]; leaf.DaysLeft < days {
+			events = append(events, alertEvent{"tls::expiring", map[string]any{
+				"inbound":  inbound.Tag,
+				"source":   source,
+				"subject":  leaf.Subject,
+				"notAfter": leaf.NotAfter,
+				"days":     leaf.DaysLeft,
+				"expired":  leaf.Expired,
+			}})
+		}
+	}
+	return events
+}
+
+func loadAlertSettings() AlertSettings {
+	settings := AlertSettings{}
+	b, err := os.ReadFile(GetPath(AlertsFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	for i := range settings.Rules {
+		rule := &settings.Rules[i]
+		if rule.Dedup == 0 {
+			rule.Dedup = 600
+		}
+		if rule.RateLimit == 0 {
+			rule.RateLimit = 20
+		}
+	}
+	if settings.Thresholds.CertificateDays <= 0 {
+		settings.Thresholds.CertificateDays = 14
+	}
+	return settings
+}
@@ -0,0 +1,43 @@
+package bridge
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestAlertDedup(t *testing.T) {
+	al := NewAlerter(&App{})
+	rule := AlertRule{Name: "crash", Events: []string{"core::crashed"}, Dedup: 2 * 86400}
+
+	start := time.Now().UnixMilli()
+	alertAt := func(offset time.Duration, pid int) Alert {
+		payload := map[string]any{"pid": pid}
+		return Alert{Rule: rule.Name, Event: "core::crashed", Time: start + offset.Milliseconds(), Payload: payload, Message: "pid " + strconv.Itoa(pid)}
+	}
+
+	steps := []struct {
+		offset time.Duration
+		pid    int
+		want   string
+	}{
+		{0, 1, ""},
+		{time.Minute, 2, "dedup"},           // a crash loop changes the pid every time
+		{25 * time.Hour, 3, "dedup"},        // windows longer than a day still hold
+		{48*time.Hour + time.Minute, 4, ""}, // the window has passed
+		{48*time.Hour + 2*time.Minute, 5, "dedup"},
+	}
+	for _, step := range steps {
+		alert := alertAt(step.offset, step.pid)
+		if got := al.admit(rule, alert, alertDedupKey(rule, alert)); got != step.want {
+			t.Errorf("at %s: admit = %q, want %q", step.offset, got, step.want)
+		}
+	}
+
+	// An explicit key separates alerts the default key would merge
+	rule.DedupKey = "{{.Payload.pid}}"
+	first, second := alertAt(72*time.Hour, 6), alertAt(72*time.Hour, 7)
+	if alertDedupKey(rule, first) == alertDedupKey(rule, second) {
+		t.Errorf("dedupKey template did not separate the alerts")
+	}
+}
@@ -55,12 +55,21 @@ func NewApp(bus *eventbus.Bus) *App {
 		loadConfig()
 	}
 
-	return &App{
+	app := &App{
 		Bus: bus,
 		Exit: func() {
 			os.Exit(0)
 		},
 	}
+	app.Scheduler = NewScheduler(app)
+	app.Stats = NewStatsRecorder(app)
+	app.Prober = NewLatencyProber(app)
+	app.Failover = NewFailover(app)
+	app.Alerts = NewAlerter(app)
+	app.Inbox = NewInbox(app)
+	app.Quota = NewQuotaTracker(app)
+
+	return app
 }
 
 func loadConfig() {
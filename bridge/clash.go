@@ -0,0 +1,503 @@
+package bridge
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// clashFields reads a Clash proxy and remembers every key that was consumed, so the
+// fields left over can be reported as dropped.
+type clashFields struct {
+	m      fieldMap
+	prefix string
+	used   map[string]bool
+	lost   map[string]bool
+}
+
+func newClashFields(proxy map[string]any) *clashFields {
+	return &clashFields{m: fieldMap(proxy), used: make(map[string]bool), lost: make(map[string]bool)}
+}
+
+func (c *clashFields) use(key string) {
+	c.used[c.prefix+key] = true
+}
+
+// drop reports a field that was read but cannot be represented in sing-box.
+func (c *clashFields) drop(key string) {
+	c.lost[c.prefix+key] = true
+}
+
+func (c *clashFields) has(key string) bool {
+	_, ok := c.m[key]
+	return ok
+}
+
+func (c *clashFields) str(key string) string {
+	c.use(key)
+	return c.m.str(key)
+}
+
+func (c *clashFields) num(key string) int {
+	c.use(key)
+	return c.m.num(key)
+}
+
+func (c *clashFields) boolean(key string) bool {
+	c.use(key)
+	return c.m.boolean(key)
+}
+
+func (c *clashFields) list(key string) []string {
+	c.use(key)
+	return c.m.list(key)
+}
+
+func (c *clashFields) obj(key string) *clashFields {
+	return c.with(c.m.obj(key), key+".")
+}
+
+func (c *clashFields) with(m fieldMap, prefix string) *clashFields {
+	return &clashFields{m: m, prefix: c.prefix + prefix, used: c.used, lost: c.lost}
+}
+
+// dropped lists the leaf fields of the proxy that no converter consumed.
+func (c *clashFields) dropped() []string {
+	fields := []string{}
+	for path := range c.lost {
+		fields = append(fields, path)
+	}
+	var walk func(m fieldMap, prefix string)
+	walk = func(m fieldMap, prefix string) {
+		for key, value := range m {
+			path := prefix + key
+			if c.used[path] || c.lost[path] {
+				continue
+			}
+			if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+				walk(nested, path+".")
+				continue
+			}
+			fields = append(fields, path)
+		}
+	}
+	walk(c.m, "")
+	slices.Sort(fields)
+	return fields
+}
+
+type ClashConvertItem struct {
+	Name     string         `json:"name"`
+	Type     string         `json:"type"`
+	Outbound map[string]any `json:"outbound"`
+	Dropped  []string       `json:"dropped"`
+	Error    string         `json:"error"`
+}
+
+type ClashConvertResult struct {
+	Outbounds []map[string]any   `json:"outbounds"`
+	Report    []ClashConvertItem `json:"report"` // entries that failed or lost fields
+}
+
+// ConvertClashConfig converts the `proxies` of a Clash/Mihomo config, or a bare proxy list,
+// into sing-box outbounds.
+func (a *App) ConvertClashConfig(config string) (ClashConvertResult, error) {
+	slog.Info("ConvertClashConfig", "bytes", len(config))
+
+	var proxies []any
+	var document struct {
+		Proxies []any `yaml:"proxies"`
+	}
+	if err := yaml.Unmarshal([]byte(config), &document); err == nil && document.Proxies != nil {
+		proxies = document.Proxies
+	} else if err := yaml.Unmarshal([]byte(config), &proxies); err != nil || proxies == nil {
+		return ClashConvertResult{}, errors.New("no proxies found in the Clash config")
+	}
+
+	result := ClashConvertResult{Outbounds: []map[string]any{}, Report: []ClashConvertItem{}}
+	for _, item := range ConvertClashProxies(proxies) {
+		if item.Outbound != nil {
+			result.Outbounds = append(result.Outbounds, item.Outbound)
+		}
+		if item.Error != "" || len(item.Dropped) > 0 {
+			result.Report = append(result.Report, item)
+		}
+	}
+	return result, nil
+}
+
+// ConvertClashProxies converts a Clash `proxies` list entry by entry.
+func ConvertClashProxies(proxies []any) []ClashConvertItem {
+	items := make([]ClashConvertItem, 0, len(proxies))
+	for _, entry := range proxies {
+		proxy, ok := entry.(map[string]any)
+		if !ok {
+			items = append(items, ClashConvertItem{Error: "invalid proxy entry"})
+			continue
+		}
+		item := ClashConvertItem{
+			Name: anyToString(proxy["name"]),
+			Type: anyToString(proxy["type"]),
+		}
+		outbound, dropped, err := ConvertClashProxy(proxy)
+		if err != nil {
+			item.Error = err.Error()
+		} else {
+			item.Outbound = outbound
+			item.Dropped = dropped
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// ConvertClashProxy converts one Clash/Mihomo proxy into a sing-box outbound and returns the
+// fields that have no sing-box equivalent.
+func ConvertClashProxy(proxy map[string]any) (map[string]any, []string, error) {
+	p := newClashFields(proxy)
+
+	server := p.str("server")
+	port := p.num("port")
+	if server == "" || port == 0 {
+		return nil, nil, errors.New("missing server or port")
+	}
+
+	outbound := map[string]any{
+		"tag":         linkTag(p.str("name"), server, port),
+		"server":      server,
+		"server_port": port,
+	}
+
+	switch p.str("type") {
+	case "ss":
+		outbound["type"] = "shadowsocks"
+		outbound["method"] = p.str("cipher")
+		outbound["password"] = p.str("password")
+		if p.boolean("udp-over-tcp") {
+			uot := map[string]any{"enabled": true}
+			if version := p.num("udp-over-tcp-version"); version > 0 {
+				uot["version"] = version
+			}
+			outbound["udp_over_tcp"] = uot
+		}
+		if err := convertClashSSPlugin(p, outbound); err != nil {
+			return nil, nil, err
+		}
+		convertClashMultiplex(p, outbound)
+	case "vmess":
+		outbound["type"] = "vmess"
+		outbound["uuid"] = p.str("uuid")
+		outbound["alter_id"] = p.num("alterId")
+		outbound["security"] = firstNonEmpty(p.str("cipher"), "auto")
+		if p.boolean("global-padding") {
+			outbound["global_padding"] = true
+		}
+		if p.boolean("authenticated-length") {
+			outbound["authenticated_length"] = true
+		}
+		convertClashPacketEncoding(p, outbound)
+		convertClashTLS(p, outbound, p.boolean("tls"))
+		convertClashTransport(p, outbound)
+		convertClashMultiplex(p, outbound)
+	case "vless":
+		outbound["type"] = "vless"
+		outbound["uuid"] = p.str("uuid")
+		if flow := p.str("flow"); flow != "" {
+			outbound["flow"] = flow
+		}
+		convertClashPacketEncoding(p, outbound)
+		convertClashTLS(p, outbound, p.boolean("tls"))
+		convertClashTransport(p, outbound)
+		convertClashMultiplex(p, outbound)
+	case "trojan":
+		outbound["type"] = "trojan"
+		outbound["password"] = p.str("password")
+		convertClashTLS(p, outbound, true)
+		convertClashTransport(p, outbound)
+		convertClashMultiplex(p, outbound)
+	case "hysteria2":
+		outbound["type"] = "hysteria2"
+		outbound["password"] = p.str("password")
+		if ports := p.str("ports"); ports != "" {
+			outbound["server_ports"] = splitPortRanges(ports)
+		}
+		if up := p.num("up"); up > 0 {
+			outbound["up_mbps"] = up
+		}
+		if down := p.num("down"); down > 0 {
+			outbound["down_mbps"] = down
+		}
+		if obfs := p.str("obfs"); obfs != "" {
+			outbound["obfs"] = map[string]any{"type": obfs, "password": p.str("obfs-password")}
+		}
+		convertClashTLS(p, outbound, true)
+	case "tuic":
+		if p.has("token") && !p.has("uuid") {
+			return nil, nil, errors.New("TUIC v4 token authentication is not supported")
+		}
+		outbound["type"] = "tuic"
+		outbound["uuid"] = p.str("uuid")
+		outbound["password"] = p.str("password")
+		if cc := p.str("congestion-controller"); cc != "" {
+			outbound["congestion_control"] = cc
+		}
+		if mode := p.str("udp-relay-mode"); mode != "" {
+			outbound["udp_relay_mode"] = mode
+		}
+		if p.boolean("reduce-rtt") {
+			outbound["zero_rtt_handshake"] = true
+		}
+		if p.has("heartbeat-interval") {
+			outbound["heartbeat"] = strconv.Itoa(p.num("heartbeat-interval")) + "ms"
+		}
+		convertClashTLS(p, outbound, true)
+	case "wireguard":
+		if err := convertClashWireGuard(p, outbound); err != nil {
+			return nil, nil, err
+		}
+	case "socks5":
+		outbound["type"] = "socks"
+		outbound["version"] = "5"
+		if username := p.str("username"); username != "" {
+			outbound["username"] = username
+			outbound["password"] = p.str("password")
+		}
+		if p.boolean("tls") {
+			p.drop("tls")
+		}
+	case "http":
+		outbound["type"] = "http"
+		if username := p.str("username"); username != "" {
+			outbound["username"] = username
+			outbound["password"] = p.str("password")
+		}
+		convertClashTLS(p, outbound, p.boolean("tls"))
+	default:
+		return nil, nil, errors.New("unsupported proxy type: " + p.str("type"))
+	}
+
+	convertClashDial(p, outbound)
+
+	return outbound, p.dropped(), nil
+}
+
+// convertClashDial maps the dial options shared by every proxy type.
+func convertClashDial(p *clashFields, outbound map[string]any) {
+	// UDP is always available in sing-box, so only an explicit `udp: false` is lost
+	if !p.has("udp") || p.m.boolean("udp") {
+		p.use("udp")
+	}
+	if p.boolean("tfo") {
+		outbound["tcp_fast_open"] = true
+	}
+	if p.boolean("mptcp") {
+		outbound["tcp_multi_path"] = true
+	}
+	if detour := p.str("dialer-proxy"); detour != "" {
+		outbound["detour"] = detour
+	}
+}
+
+func convertClashTLS(p *clashFields, outbound map[string]any, enabled bool) {
+	p.use("tls")
+	if !enabled {
+		return
+	}
+	reality := p.obj("reality-opts")
+	outbound["tls"] = buildTLS(
+		firstNonEmpty(p.str("servername"), p.str("sni")),
+		p.boolean("skip-cert-verify"),
+		p.list("alpn"),
+		p.str("client-fingerprint"),
+		reality.str("public-key"),
+		reality.str("short-id"),
+	)
+}
+
+func convertClashPacketEncoding(p *clashFields, outbound map[string]any) {
+	if encoding := p.str("packet-encoding"); encoding != "" {
+		outbound["packet_encoding"] = encoding
+	} else if p.boolean("xudp") {
+		outbound["packet_encoding"] = "xudp"
+	} else if p.boolean("packet-addr") {
+		outbound["packet_encoding"] = "packetaddr"
+	}
+}
+
+func convertClashTransport(p *clashFields, outbound map[string]any) {
+	switch p.str("network") {
+	case "ws":
+		opts := p.obj("ws-opts")
+		headers := opts.obj("headers")
+		transport := buildTransport("ws", headers.str("Host"), opts.str("path"), "")
+		if early := opts.num("max-early-data"); early > 0 {
+			transport["max_early_data"] = early
+			transport["early_data_header_name"] = firstNonEmpty(opts.str("early-data-header-name"), "Sec-WebSocket-Protocol")
+		}
+		if opts.boolean("v2ray-http-upgrade") {
+			transport["type"] = "httpupgrade"
+			delete(transport, "headers")
+			if host := headers.str("Host"); host != "" {
+				transport["host"] = host
+			}
+		}
+		outbound["transport"] = transport
+	case "grpc":
+		outbound["transport"] = buildTransport("grpc", "", "", p.obj("grpc-opts").str("grpc-service-name"))
+	case "h2":
+		opts := p.obj("h2-opts")
+		outbound["transport"] = buildTransport("http", strings.Join(opts.list("host"), ","), opts.str("path"), "")
+	case "http":
+		opts := p.obj("http-opts")
+		path := ""
+		if paths := opts.list("path"); len(paths) > 0 {
+			path = paths[0]
+		}
+		transport := buildTransport("http", strings.Join(opts.obj("headers").list("Host"), ","), path, "")
+		if method := opts.str("method"); method != "" {
+			transport["method"] = method
+		}
+		outbound["transport"] = transport
+	}
+}
+
+func convertClashMultiplex(p *clashFields, outbound map[string]any) {
+	smux := p.obj("smux")
+	if !smux.boolean("enabled") {
+		return
+	}
+	multiplex := map[string]any{"enabled": true}
+	if protocol := smux.str("protocol"); protocol != "" {
+		multiplex["protocol"] = protocol
+	}
+	for key, field := range map[string]string{
+		"max-connections": "max_connections",
+		"min-streams":     "min_streams",
+		"max-streams":     "max_streams",
+	} {
+		if n := smux.num(key); n > 0 {
+			multiplex[field] = n
+		}
+	}
+	if smux.boolean("padding") {
+		multiplex["padding"] = true
+	}
+	outbound["multiplex"] = multiplex
+}
+
+func convertClashSSPlugin(p *clashFields, outbound map[string]any) error {
+	plugin := p.str("plugin")
+	if plugin == "" {
+		return nil
+	}
+	opts := p.obj("plugin-opts")
+
+	switch plugin {
+	case "obfs":
+		outbound["plugin"] = "obfs-local"
+		pluginOpts := "obfs=" + firstNonEmpty(opts.str("mode"), "http")
+		if host := opts.str("host"); host != "" {
+			pluginOpts += ";obfs-host=" + host
+		}
+		outbound["plugin_opts"] = pluginOpts
+	case "v2ray-plugin":
+		outbound["plugin"] = "v2ray-plugin"
+		pluginOpts := []string{"mode=" + firstNonEmpty(opts.str("mode"), "websocket")}
+		if opts.boolean("tls") {
+			pluginOpts = append(pluginOpts, "tls")
+		}
+		if host := opts.str("host"); host != "" {
+			pluginOpts = append(pluginOpts, "host="+host)
+		}
+		if path := opts.str("path"); path != "" {
+			pluginOpts = append(pluginOpts, "path="+path)
+		}
+		outbound["plugin_opts"] = strings.Join(pluginOpts, ";")
+	default:
+		return errors.New("unsupported shadowsocks plugin: " + plugin)
+	}
+
+	return nil
+}
+
+// convertClashWireGuard produces a legacy wireguard outbound. Only the first entry of
+// `peers` can be represented; further peers are reported as dropped.
+func convertClashWireGuard(p *clashFields, outbound map[string]any) error {
+	outbound["type"] = "wireguard"
+	outbound["private_key"] = p.str("private-key")
+
+	peer := p
+	if peers, ok := p.m["peers"].([]any); ok && len(peers) > 0 {
+		first, ok := peers[0].(map[string]any)
+		if !ok {
+			return errors.New("invalid wireguard peer")
+		}
+		p.use("peers")
+		peer = p.with(fieldMap(first), "peers.0.")
+		if server := peer.str("server"); server != "" {
+			outbound["server"] = server
+		}
+		if port := peer.num("port"); port > 0 {
+			outbound["server_port"] = port
+		}
+		peer.use("allowed-ips")
+		for i := 1; i < len(peers); i++ {
+			p.drop("peers." + strconv.Itoa(i))
+		}
+	}
+
+	publicKey := peer.str("public-key")
+	if publicKey == "" {
+		return errors.New("missing wireguard public-key")
+	}
+	outbound["peer_public_key"] = publicKey
+	if psk := firstNonEmpty(peer.str("pre-shared-key"), peer.str("preshared-key")); psk != "" {
+		outbound["pre_shared_key"] = psk
+	}
+
+	address := []string{}
+	if ip := p.str("ip"); ip != "" {
+		if !strings.Contains(ip, "/") {
+			ip += "/32"
+		}
+		address = append(address, ip)
+	}
+	if ip := p.str("ipv6"); ip != "" {
+		if !strings.Contains(ip, "/") {
+			ip += "/128"
+		}
+		address = append(address, ip)
+	}
+	if len(address) > 0 {
+		outbound["local_address"] = address
+	}
+
+	if reserved := peer.list("reserved"); len(reserved) > 0 {
+		values := []int{}
+		for _, item := range reserved {
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				// Mihomo also accepts the reserved bytes as a base64 string
+				decoded, decodeErr := decodeBase64String(item)
+				if decodeErr != nil || len(reserved) != 1 {
+					return errors.New("invalid wireguard reserved bytes")
+				}
+				for _, b := range decoded {
+					values = append(values, int(b))
+				}
+				break
+			}
+			values = append(values, n)
+		}
+		outbound["reserved"] = values
+	}
+	if mtu := p.num("mtu"); mtu > 0 {
+		outbound["mtu"] = mtu
+	}
+
+	return nil
+}
@@ -0,0 +1,305 @@
+package bridge
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ExportClashProxy is the reverse of ConvertClashProxy, producing a Mihomo proxy entry.
+func ExportClashProxy(outbound map[string]any) (map[string]any, error) {
+	o := fieldMap(outbound)
+
+	server := o.str("server")
+	port := o.num("server_port")
+	if server == "" || port == 0 {
+		return nil, errors.New("missing server or server_port")
+	}
+
+	proxy := map[string]any{
+		"name":   o.str("tag"),
+		"server": server,
+		"port":   port,
+		"udp":    true,
+	}
+
+	switch o.str("type") {
+	case "shadowsocks":
+		proxy["type"] = "ss"
+		proxy["cipher"] = o.str("method")
+		proxy["password"] = o.str("password")
+		if uot := o.obj("udp_over_tcp"); uot.boolean("enabled") {
+			proxy["udp-over-tcp"] = true
+			if version := uot.num("version"); version > 0 {
+				proxy["udp-over-tcp-version"] = version
+			}
+		}
+		if err := exportClashSSPlugin(o, proxy); err != nil {
+			return nil, err
+		}
+		exportClashMultiplex(o, proxy)
+	case "vmess":
+		proxy["type"] = "vmess"
+		proxy["uuid"] = o.str("uuid")
+		proxy["alterId"] = o.num("alter_id")
+		proxy["cipher"] = firstNonEmpty(o.str("security"), "auto")
+		if o.boolean("global_padding") {
+			proxy["global-padding"] = true
+		}
+		if o.boolean("authenticated_length") {
+			proxy["authenticated-length"] = true
+		}
+		exportClashPacketEncoding(o, proxy)
+		exportClashTLS(o, proxy, "servername")
+		exportClashTransport(o, proxy)
+		exportClashMultiplex(o, proxy)
+	case "vless":
+		proxy["type"] = "vless"
+		proxy["uuid"] = o.str("uuid")
+		if flow := o.str("flow"); flow != "" {
+			proxy["flow"] = flow
+		}
+		exportClashPacketEncoding(o, proxy)
+		exportClashTLS(o, proxy, "servername")
+		exportClashTransport(o, proxy)
+		exportClashMultiplex(o, proxy)
+	case "trojan":
+		proxy["type"] = "trojan"
+		proxy["password"] = o.str("password")
+		exportClashTLS(o, proxy, "sni")
+		delete(proxy, "tls")
+		exportClashTransport(o, proxy)
+		exportClashMultiplex(o, proxy)
+	case "hysteria2":
+		proxy["type"] = "hysteria2"
+		proxy["password"] = o.str("password")
+		if ports := o.list("server_ports"); len(ports) > 0 {
+			proxy["ports"] = strings.ReplaceAll(strings.Join(ports, ","), ":", "-")
+		}
+		if up := o.num("up_mbps"); up > 0 {
+			proxy["up"] = strconv.Itoa(up) + " Mbps"
+		}
+		if down := o.num("down_mbps"); down > 0 {
+			proxy["down"] = strconv.Itoa(down) + " Mbps"
+		}
+		if obfs := o.obj("obfs"); obfs.str("type") != "" {
+			proxy["obfs"] = obfs.str("type")
+			proxy["obfs-password"] = obfs.str("password")
+		}
+		exportClashTLS(o, proxy, "sni")
+		delete(proxy, "tls")
+	case "tuic":
+		proxy["type"] = "tuic"
+		proxy["uuid"] = o.str("uuid")
+		proxy["password"] = o.str("password")
+		if cc := o.str("congestion_control"); cc != "" {
+			proxy["congestion-controller"] = cc
+		}
+		if mode := o.str("udp_relay_mode"); mode != "" {
+			proxy["udp-relay-mode"] = mode
+		}
+		if o.boolean("zero_rtt_handshake") {
+			proxy["reduce-rtt"] = true
+		}
+		exportClashTLS(o, proxy, "sni")
+		delete(proxy, "tls")
+	case "wireguard":
+		proxy["type"] = "wireguard"
+		proxy["private-key"] = o.str("private_key")
+		proxy["public-key"] = o.str("peer_public_key")
+		if psk := o.str("pre_shared_key"); psk != "" {
+			proxy["pre-shared-key"] = psk
+		}
+		for _, address := range o.list("local_address") {
+			ip, _, _ := strings.Cut(address, "/")
+			if strings.Contains(ip, ":") {
+				proxy["ipv6"] = ip
+			} else {
+				proxy["ip"] = ip
+			}
+		}
+		if reserved := o.list("reserved"); len(reserved) > 0 {
+			values := []int{}
+			for _, item := range reserved {
+				n, _ := strconv.Atoi(item)
+				values = append(values, n)
+			}
+			proxy["reserved"] = values
+		}
+		if mtu := o.num("mtu"); mtu > 0 {
+			proxy["mtu"] = mtu
+		}
+	case "socks":
+		proxy["type"] = "socks5"
+		if username := o.str("username"); username != "" {
+			proxy["username"] = username
+			proxy["password"] = o.str("password")
+		}
+	case "http":
+		proxy["type"] = "http"
+		if username := o.str("username"); username != "" {
+			proxy["username"] = username
+			proxy["password"] = o.str("password")
+		}
+		exportClashTLS(o, proxy, "sni")
+	default:
+		return nil, errors.New("unsupported outbound type: " + o.str("type"))
+	}
+
+	if o.boolean("tcp_fast_open") {
+		proxy["tfo"] = true
+	}
+	if o.boolean("tcp_multi_path") {
+		proxy["mptcp"] = true
+	}
+	if detour := o.str("detour"); detour != "" {
+		proxy["dialer-proxy"] = detour
+	}
+
+	return proxy, nil
+}
+
+// exportClashTLS writes the TLS fields; sniKey is "servername" for vmess/vless and "sni"
+// for the other types.
+func exportClashTLS(o fieldMap, proxy map[string]any, sniKey string) {
+	tls := o.obj("tls")
+	if !tls.boolean("enabled") {
+		return
+	}
+	proxy["tls"] = true
+	if sni := tls.str("server_name"); sni != "" {
+		proxy[sniKey] = sni
+	}
+	if tls.boolean("insecure") {
+		proxy["skip-cert-verify"] = true
+	}
+	if alpn := tls.list("alpn"); len(alpn) > 0 {
+		proxy["alpn"] = alpn
+	}
+	if fp := tls.obj("utls").str("fingerprint"); fp != "" {
+		proxy["client-fingerprint"] = fp
+	}
+	if reality := tls.obj("reality"); reality.boolean("enabled") {
+		opts := map[string]any{"public-key": reality.str("public_key")}
+		if sid := reality.str("short_id"); sid != "" {
+			opts["short-id"] = sid
+		}
+		proxy["reality-opts"] = opts
+	}
+}
+
+func exportClashPacketEncoding(o fieldMap, proxy map[string]any) {
+	switch o.str("packet_encoding") {
+	case "xudp":
+		proxy["xudp"] = true
+	case "packetaddr":
+		proxy["packet-addr"] = true
+	}
+}
+
+func exportClashTransport(o fieldMap, proxy map[string]any) {
+	transport := o.obj("transport")
+	switch transport.str("type") {
+	case "ws":
+		proxy["network"] = "ws"
+		opts := map[string]any{"path": firstNonEmpty(transport.str("path"), "/")}
+		if host := transport.obj("headers").str("Host"); host != "" {
+			opts["headers"] = map[string]any{"Host": host}
+		}
+		if early := transport.num("max_early_data"); early > 0 {
+			opts["max-early-data"] = early
+			opts["early-data-header-name"] = firstNonEmpty(transport.str("early_data_header_name"), "Sec-WebSocket-Protocol")
+		}
+		proxy["ws-opts"] = opts
+	case "httpupgrade":
+		proxy["network"] = "ws"
+		opts := map[string]any{
+			"path":               firstNonEmpty(transport.str("path"), "/"),
+			"v2ray-http-upgrade": true,
+		}
+		if host := transport.str("host"); host != "" {
+			opts["headers"] = map[string]any{"Host": host}
+		}
+		proxy["ws-opts"] = opts
+	case "grpc":
+		proxy["network"] = "grpc"
+		proxy["grpc-opts"] = map[string]any{"grpc-service-name": transport.str("service_name")}
+	case "http":
+		// sing-box uses one transport for both; Mihomo only speaks h2 over TLS
+		opts := map[string]any{"path": firstNonEmpty(transport.str("path"), "/")}
+		if _, ok := proxy["tls"]; ok {
+			proxy["network"] = "h2"
+			if host := transport.list("host"); len(host) > 0 {
+				opts["host"] = host
+			}
+			proxy["h2-opts"] = opts
+			break
+		}
+		proxy["network"] = "http"
+		opts["path"] = []string{opts["path"].(string)}
+		if method := transport.str("method"); method != "" {
+			opts["method"] = method
+		}
+		if host := transport.list("host"); len(host) > 0 {
+			opts["headers"] = map[string]any{"Host": host}
+		}
+		proxy["http-opts"] = opts
+	}
+}
+
+func exportClashMultiplex(o fieldMap, proxy map[string]any) {
+	multiplex := o.obj("multiplex")
+	if !multiplex.boolean("enabled") {
+		return
+	}
+	smux := map[string]any{"enabled": true}
+	if protocol := multiplex.str("protocol"); protocol != "" {
+		smux["protocol"] = protocol
+	}
+	for field, key := range map[string]string{
+		"max_connections": "max-connections",
+		"min_streams":     "min-streams",
+		"max_streams":     "max-streams",
+	} {
+		if n := multiplex.num(field); n > 0 {
+			smux[key] = n
+		}
+	}
+	if multiplex.boolean("padding") {
+		smux["padding"] = true
+	}
+	proxy["smux"] = smux
+}
+
+func exportClashSSPlugin(o fieldMap, proxy map[string]any) error {
+	plugin := o.str("plugin")
+	if plugin == "" {
+		return nil
+	}
+	opts := map[string]any{}
+	for _, item := range strings.Split(o.str("plugin_opts"), ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(item), "=")
+		switch key {
+		case "obfs":
+			opts["mode"] = value
+		case "obfs-host", "host":
+			opts["host"] = value
+		case "mode", "path":
+			opts[key] = value
+		case "tls":
+			opts["tls"] = true
+		}
+	}
+
+	switch plugin {
+	case "obfs-local":
+		proxy["plugin"] = "obfs"
+	case "v2ray-plugin":
+		proxy["plugin"] = "v2ray-plugin"
+	default:
+		return errors.New("unsupported shadowsocks plugin: " + plugin)
+	}
+	proxy["plugin-opts"] = opts
+
+	return nil
+}
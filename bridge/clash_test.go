@@ -0,0 +1,243 @@
+package bridge
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestConvertClashProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		proxy    string // Clash YAML
+		outbound string // sing-box JSON
+		dropped  []string
+	}{
+		{
+			name: "ss obfs",
+			proxy: `
+name: ss
+type: ss
+server: 1.2.3.4
+port: 8388
+cipher: aes-128-gcm
+password: pw
+udp: false
+plugin: obfs
+plugin-opts: {mode: tls, host: bing.com, fast-open: true}`,
+			outbound: `{"tag":"ss","type":"shadowsocks","server":"1.2.3.4","server_port":8388,"method":"aes-128-gcm","password":"pw",
+				"plugin":"obfs-local","plugin_opts":"obfs=tls;obfs-host=bing.com"}`,
+			dropped: []string{"plugin-opts.fast-open", "udp"},
+		},
+		{
+			name: "vmess ws",
+			proxy: `
+name: vmess
+type: vmess
+server: example.com
+port: 443
+uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+alterId: 0
+cipher: auto
+tls: true
+servername: example.com
+network: ws
+ws-opts:
+  path: /ws
+  max-early-data: 2048
+  headers: {Host: cdn.example.com, User-Agent: curl}`,
+			outbound: `{"tag":"vmess","type":"vmess","server":"example.com","server_port":443,
+				"uuid":"b831381d-6324-4d53-ad4f-8cda48b30811","alter_id":0,"security":"auto",
+				"tls":{"enabled":true,"server_name":"example.com"},
+				"transport":{"type":"ws","path":"/ws","headers":{"Host":"cdn.example.com"},"max_early_data":2048,"early_data_header_name":"Sec-WebSocket-Protocol"}}`,
+			dropped: []string{"ws-opts.headers.User-Agent"},
+		},
+		{
+			name: "vless reality grpc",
+			proxy: `
+name: reality
+type: vless
+server: example.com
+port: 443
+uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+flow: xtls-rprx-vision
+tls: true
+servername: www.microsoft.com
+client-fingerprint: chrome
+reality-opts: {public-key: jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0, short-id: 6ba85179e30d4fc2}
+network: grpc
+grpc-opts: {grpc-service-name: grpc-svc}`,
+			outbound: `{"tag":"reality","type":"vless","server":"example.com","server_port":443,
+				"uuid":"b831381d-6324-4d53-ad4f-8cda48b30811","flow":"xtls-rprx-vision",
+				"tls":{"enabled":true,"server_name":"www.microsoft.com","utls":{"enabled":true,"fingerprint":"chrome"},
+					"reality":{"enabled":true,"public_key":"jNXHt1yRo0vDuchQlIP6Z0ZvjT3KtzVI-T4E7RoLJS0","short_id":"6ba85179e30d4fc2"}},
+				"transport":{"type":"grpc","service_name":"grpc-svc"}}`,
+			dropped: []string{},
+		},
+		{
+			name: "vless httpupgrade",
+			proxy: `
+name: upgrade
+type: vless
+server: example.com
+port: 80
+uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+network: ws
+ws-opts: {path: /up, headers: {Host: cdn.example.com}, v2ray-http-upgrade: true}`,
+			outbound: `{"tag":"upgrade","type":"vless","server":"example.com","server_port":80,
+				"uuid":"b831381d-6324-4d53-ad4f-8cda48b30811",
+				"transport":{"type":"httpupgrade","path":"/up","host":"cdn.example.com"}}`,
+			dropped: []string{},
+		},
+		{
+			name: "trojan",
+			proxy: `
+name: trojan
+type: trojan
+server: example.com
+port: 443
+password: pw
+sni: example.com
+skip-cert-verify: true
+alpn: [h2, http/1.1]
+ss-opts: {enabled: true, method: aes-128-gcm, password: inner}`,
+			outbound: `{"tag":"trojan","type":"trojan","server":"example.com","server_port":443,"password":"pw",
+				"tls":{"enabled":true,"server_name":"example.com","insecure":true,"alpn":["h2","http/1.1"]}}`,
+			dropped: []string{"ss-opts.enabled", "ss-opts.method", "ss-opts.password"},
+		},
+		{
+			name: "hysteria2",
+			proxy: `
+name: hy2
+type: hysteria2
+server: example.com
+port: 443
+ports: 20000-30000,40000
+password: letmein
+up: 100 Mbps
+down: "200"
+obfs: salamander
+obfs-password: gawrgura
+sni: example.com`,
+			outbound: `{"tag":"hy2","type":"hysteria2","server":"example.com","server_port":443,"password":"letmein",
+				"server_ports":["20000:30000","40000"],"up_mbps":100,"down_mbps":200,
+				"obfs":{"type":"salamander","password":"gawrgura"},
+				"tls":{"enabled":true,"server_name":"example.com"}}`,
+			dropped: []string{},
+		},
+		{
+			name: "tuic",
+			proxy: `
+name: tuic
+type: tuic
+server: example.com
+port: 443
+uuid: b831381d-6324-4d53-ad4f-8cda48b30811
+password: secret
+congestion-controller: bbr
+udp-relay-mode: native
+reduce-rtt: true
+heartbeat-interval: 10000
+alpn: [h3]
+sni: example.com
+disable-sni: true`,
+			outbound: `{"tag":"tuic","type":"tuic","server":"example.com","server_port":443,
+				"uuid":"b831381d-6324-4d53-ad4f-8cda48b30811","password":"secret",
+				"congestion_control":"bbr","udp_relay_mode":"native","zero_rtt_handshake":true,"heartbeat":"10000ms",
+				"tls":{"enabled":true,"server_name":"example.com","alpn":["h3"]}}`,
+			dropped: []string{"disable-sni"},
+		},
+		{
+			name: "wireguard",
+			proxy: `
+name: wg
+type: wireguard
+server: wg.example.com
+port: 51820
+private-key: gN3XRxGCGNhVWBzPvVOEnx53mQ9C0NeMpo9FBhlY0GE=
+ip: 10.0.0.2
+ipv6: fd00::2
+mtu: 1420
+remote-dns-resolve: true
+peers:
+  - server: peer.example.com
+    port: 51821
+    public-key: Z1XXLsKYkYxuiYjJIkRvtIKFepCYHTgON+GfPFOAgmE=
+    pre-shared-key: psk
+    allowed-ips: [0.0.0.0/0]
+    reserved: [1, 2, 3]
+  - server: second.example.com
+    port: 51822
+    public-key: second`,
+			outbound: `{"tag":"wg","type":"wireguard","server":"peer.example.com","server_port":51821,
+				"private_key":"gN3XRxGCGNhVWBzPvVOEnx53mQ9C0NeMpo9FBhlY0GE=",
+				"peer_public_key":"Z1XXLsKYkYxuiYjJIkRvtIKFepCYHTgON+GfPFOAgmE=","pre_shared_key":"psk",
+				"local_address":["10.0.0.2/32","fd00::2/128"],"reserved":[1,2,3],"mtu":1420}`,
+			dropped: []string{"peers.1", "remote-dns-resolve"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var proxy map[string]any
+			if err := yaml.Unmarshal([]byte(tt.proxy), &proxy); err != nil {
+				t.Fatalf("yaml: %v", err)
+			}
+			outbound, dropped, err := ConvertClashProxy(proxy)
+			if err != nil {
+				t.Fatalf("ConvertClashProxy: %v", err)
+			}
+
+			var got, want any
+			b, _ := json.Marshal(outbound)
+			_ = json.Unmarshal(b, &got)
+			if err := json.Unmarshal([]byte(tt.outbound), &want); err != nil {
+				t.Fatalf("want: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("outbound\n got %s\nwant %s", b, tt.outbound)
+			}
+			if !reflect.DeepEqual(dropped, tt.dropped) {
+				t.Errorf("dropped = %q, want %q", dropped, tt.dropped)
+			}
+		})
+	}
+}
+
+func TestConvertClashProxies(t *testing.T) {
+	var proxies []any
+	err := yaml.Unmarshal([]byte(`
+- {name: snell, type: snell, server: example.com, port: 443, psk: key}
+- {name: tuic4, type: tuic, server: example.com, port: 443, token: secret}
+- {name: noport, type: trojan, server: example.com, password: pw}
+- plain string
+- {name: ok, type: trojan, server: example.com, port: 443, password: pw}`), &proxies)
+	if err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+
+	items := ConvertClashProxies(proxies)
+	wantErrors := []string{
+		"unsupported proxy type: snell",
+		"TUIC v4 token authentication is not supported",
+		"missing server or port",
+		"invalid proxy entry",
+		"",
+	}
+	if len(items) != len(wantErrors) {
+		t.Fatalf("got %d items, want %d", len(items), len(wantErrors))
+	}
+	for i, item := range items {
+		if item.Error != wantErrors[i] {
+			t.Errorf("item %d error = %q, want %q", i, item.Error, wantErrors[i])
+		}
+		if (item.Outbound != nil) != (wantErrors[i] == "") {
+			t.Errorf("item %d outbound = %v", i, item.Outbound)
+		}
+	}
+	if items[0].Name != "snell" || items[0].Type != "snell" {
+		t.Errorf("item 0 = %s/%s, want snell/snell", items[0].Name, items[0].Type)
+	}
+}
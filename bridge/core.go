@@ -0,0 +1,209 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	sysruntime "runtime"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CoreWorkingDirectory     = "data/sing-box"
+	CoreConfigFilePath       = CoreWorkingDirectory + "/config.json"
+	CorePidFilePath          = CoreWorkingDirectory + "/pid.txt"
+	CoreProcessCacheFilePath = "data/.cache/core-process"
+
+	branchAlpha = "alpha"
+)
+
+var (
+	ansiEscapePattern    = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	logPrefixPattern     = regexp.MustCompile(`^[A-Z]+\[\d+\]\s*`)
+	decodeConfigPattern  = regexp.MustCompile(`decode config at [^:]+:\s*`)
+	jsonPathPattern      = regexp.MustCompile(`^([A-Za-z_][\w-]*(?:\[\d+\])?(?:\.[A-Za-z_][\w-]*(?:\[\d+\])?)*):\s*(.+)$`)
+	componentPathPattern = regexp.MustCompile(`(?:^|:\s*)(?:parse |initialize |create )?(route rule|dns rule|dns server|rule-set|outbound|inbound|endpoint|service)\[(\d+)\]`)
+)
+
+// componentPaths maps sing-box component names in error messages to their config JSON path.
+var componentPaths = map[string]string{
+	"route rule": "route.rules",
+	"dns rule":   "dns.rules",
+	"dns server": "dns.servers",
+	"rule-set":   "route.rule_set",
+	"outbound":   "outbounds",
+	"inbound":    "inbounds",
+	"endpoint":   "endpoints",
+	"service":    "services",
+}
+
+func loadKernelConfig() KernelConfig {
+	var settings struct {
+		Kernel KernelConfig `yaml:"kernel"`
+	}
+	b, err := os.ReadFile(GetPath("data/user.yaml"))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	return settings.Kernel
+}
+
+func coreFileName(isAlpha bool) string {
+	name := "sing-box"
+	if isAlpha {
+		name += "-latest"
+	}
+	if sysruntime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// CoreBinaryPath returns the absolute path of the core binary for the configured branch.
+func CoreBinaryPath() string {
+	return GetPath(CoreWorkingDirectory + "/" + coreFileName(loadKernelConfig().Branch == branchAlpha))
+}
+
+func coreEnviron(runtime CoreRuntimeConfig) []string {
+	env := os.Environ()
+	for key, value := range runtime.Env {
+		env = append(env, key+"="+processMagicVariables(value))
+	}
+	return env
+}
+
+func processMagicVariables(value string) string {
+	value = strings.ReplaceAll(value, "$APP_BASE_PATH", Env.BasePath)
+	return strings.ReplaceAll(value, "$CORE_BASE_PATH", CoreWorkingDirectory)
+}
+
+func (a *App) ValidateCoreConfig(config []byte, timeout int) (CoreValidateResult, error) {
+	slog.Info("ValidateCoreConfig", "bytes", len(config))
+
+	if err := checkConfigSyntax(config); err != nil {
+		return CoreValidateResult{Valid: false, Errors: []CoreConfigError{*err}}, nil
+	}
+
+	corePath := CoreBinaryPath()
+	if _, err := os.Stat(corePath); err != nil {
+		return CoreValidateResult{}, errors.New("core binary not found: " + corePath)
+	}
+
+	cacheDir := GetPath("data/.cache")
+	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
+		return CoreValidateResult{}, err
+	}
+	tmp, err := os.CreateTemp(cacheDir, "config-check-*.json")
+	if err != nil {
+		return CoreValidateResult{}, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(config); err != nil {
+		tmp.Close()
+		return CoreValidateResult{}, err
+	}
+	if err := tmp.Close(); err != nil {
+		return CoreValidateResult{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), GetTimeout(timeout))
+	defer cancel()
+
+	kernel := loadKernelConfig()
+	runtime := kernel.Main
+	if kernel.Branch == branchAlpha {
+		runtime = kernel.Alpha
+	}
+
+	env := make(map[string]string, len(runtime.Env))
+	for key, value := range runtime.Env {
+		env[key] = processMagicVariables(value)
+	}
+
+	out, err := a.execContext(ctx, corePath, []string{"check", "--disable-color", "-c", tmp.Name(), "-D", GetPath(CoreWorkingDirectory)}, ExecOptions{Env: env})
+	output := strings.TrimSpace(ansiEscapePattern.ReplaceAllString(string(out), ""))
+	output = strings.ReplaceAll(output, tmp.Name(), "config.json")
+	output = strings.ReplaceAll(output, filepath.ToSlash(tmp.Name()), "config.json")
+
+	if ctx.Err() == context.DeadlineExceeded {
+		return CoreValidateResult{}, errors.New("core check timed out")
+	}
+
+	result := CoreValidateResult{Valid: err == nil, Errors: []CoreConfigError{}, Output: output}
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return CoreValidateResult{}, err
+		}
+		result.Errors = parseCoreCheckOutput(output)
+	}
+
+	return result, nil
+}
+
+func checkConfigSyntax(config []byte) *CoreConfigError {
+	var v any
+	err := json.Unmarshal(config, &v)
+	if err == nil {
+		return nil
+	}
+	result := &CoreConfigError{Message: err.Error()}
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		result.Line, result.Column = offsetToLineColumn(config, syntaxErr.Offset)
+	}
+	return result
+}
+
+func offsetToLineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+func parseCoreCheckOutput(output string) []CoreConfigError {
+	var result []CoreConfigError
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		line = logPrefixPattern.ReplaceAllString(line, "")
+
+		item := CoreConfigError{Message: line}
+
+		if loc := decodeConfigPattern.FindStringIndex(line); loc != nil {
+			rest := line[loc[1]:]
+			if m := jsonPathPattern.FindStringSubmatch(rest); m != nil {
+				item.Path = m[1]
+				item.Message = m[2]
+			} else {
+				item.Message = rest
+			}
+		} else if m := componentPathPattern.FindStringSubmatch(line); m != nil {
+			item.Path = componentPaths[m[1]] + "[" + m[2] + "]"
+		}
+
+		result = append(result, item)
+	}
+
+	if len(result) == 0 {
+		result = append(result, CoreConfigError{Message: "core check failed"})
+	}
+
+	return result
+}
@@ -0,0 +1,337 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CoreConfigBackupFilePath = CoreWorkingDirectory + "/config.json.bak"
+
+	defaultCoreApplyGracePeriod = 5
+	coreApplyPollInterval       = 500 * time.Millisecond
+	defaultClashAPIController   = "127.0.0.1:20123"
+)
+
+var (
+	coreApplyMu sync.Mutex
+
+	activeClashAPIMu    sync.Mutex
+	activeClashAPICache struct {
+		modTime time.Time
+		size    int64
+		api     ClashAPI
+		err     error
+	}
+)
+
+type CoreApplyOptions struct {
+	GracePeriod  int `json:"gracePeriod"`  // seconds the new config must stay healthy
+	StartTimeout int `json:"startTimeout"` // seconds to wait for the core to report started
+}
+
+type CoreApplyResult struct {
+	Applied    bool   `json:"applied"`
+	RolledBack bool   `json:"rolledBack"`
+	PID        int    `json:"pid"`
+	Version    string `json:"version"`
+	Error      string `json:"error"`
+}
+
+type ClashAPI struct {
+	Base   string `json:"base"`
+	Secret string `json:"secret"`
+}
+
+// ResolveClashAPI extracts the experimental.clash_api controller from a core config and
+// normalises it to a loopback base URL, mirroring resolveCoreConnection in the frontend.
+func ResolveClashAPI(config []byte) (ClashAPI, error) {
+	var parsed struct {
+		Experimental struct {
+			ClashAPI *struct {
+				ExternalController string `json:"external_controller"`
+				Secret             string `json:"secret"`
+			} `json:"clash_api"`
+		} `json:"experimental"`
+	}
+	if err := json.Unmarshal(config, &parsed); err != nil {
+		return ClashAPI{}, err
+	}
+	if parsed.Experimental.ClashAPI == nil {
+		return ClashAPI{}, errors.New("clash_api is not enabled")
+	}
+
+	controller := strings.TrimSpace(parsed.Experimental.ClashAPI.ExternalController)
+	if controller == "" {
+		controller = defaultClashAPIController
+	}
+	if !strings.Contains(controller, "://") {
+		controller = "http://" + controller
+	}
+	u, err := url.Parse(controller)
+	if err != nil {
+		return ClashAPI{}, err
+	}
+
+	host := u.Hostname()
+	switch host {
+	case "", "0.0.0.0", "*":
+		host = "127.0.0.1"
+	case "::":
+		host = "::1"
+	}
+	port := u.Port()
+	if port == "" {
+		_, port, _ = net.SplitHostPort(defaultClashAPIController)
+	}
+	scheme := "http"
+	if u.Scheme == "https" {
+		scheme = "https"
+	}
+
+	return ClashAPI{
+		Base:   scheme + "://" + net.JoinHostPort(host, port),
+		Secret: parsed.Experimental.ClashAPI.Secret,
+	}, nil
+}
+
+// ActiveClashAPI resolves the Clash API of the installed config.json, so requests to the
+// core can carry its secret without the browser ever holding it.
+func ActiveClashAPI() (ClashAPI, error) {
+	path := GetPath(CoreConfigFilePath)
+	info, err := os.Stat(path)
+	if err != nil {
+		return ClashAPI{}, errors.New("core config not found")
+	}
+
+	activeClashAPIMu.Lock()
+	defer activeClashAPIMu.Unlock()
+
+	cache := &activeClashAPICache
+	if cache.modTime.Equal(info.ModTime()) && cache.size == info.Size() {
+		return cache.api, cache.err
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return ClashAPI{}, err
+	}
+	cache.api, cache.err = ResolveClashAPI(b)
+	cache.modTime, cache.size = info.ModTime(), info.Size()
+	return cache.api, cache.err
+}
+
+// SameClashAPIBase reports whether base points at the same controller as api, treating
+// "localhost" as 127.0.0.1.
+func SameClashAPIBase(api ClashAPI, base string) bool {
+	normalize := func(raw string) string {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return ""
+		}
+		host := u.Hostname()
+		if host == "localhost" {
+			host = "127.0.0.1"
+		}
+		return u.Scheme + "://" + net.JoinHostPort(host, u.Port())
+	}
+	want := normalize(api.Base)
+	return want != "" && want == normalize(base)
+}
+
+// clashAPIRequest sends a JSON request to the core's Clash API and decodes the reply into out,
+// surfacing the {"message"} body of a failed call as the error.
+func clashAPIRequest(ctx context.Context, api ClashAPI, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, api.Base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if api.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+api.Secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var failure struct {
+			Message string `json:"message"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&failure) == nil && failure.Message != "" {
+			return errors.New(failure.Message)
+		}
+		return errors.New("clash api responded " + resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ClashAPIVersion queries /version on the core's Clash API.
+func ClashAPIVersion(api ClashAPI, timeout time.Duration) (string, error) {
+	req, err := http.NewRequest(http.MethodGet, api.Base+"/version", nil)
+	if err != nil {
+		return "", err
+	}
+	if api.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+api.Secret)
+	}
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("clash api responded " + resp.Status)
+	}
+	var body struct {
+		Version string `json:"version"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.Version, nil
+}
+
+// ApplyCoreConfig installs a new config.json and restarts the core. If the core does not
+// stay healthy for the grace period, the previous config is restored and the core restarted;
+// without a previous config the core is stopped and the new one removed.
+func (a *App) ApplyCoreConfig(config []byte, options CoreApplyOptions) (CoreApplyResult, error) {
+	slog.Info("ApplyCoreConfig", "bytes", len(config), "gracePeriod", options.GracePeriod, "startTimeout", options.StartTimeout)
+
+	if err := checkConfigSyntax(config); err != nil {
+		return CoreApplyResult{}, errors.New(err.Message)
+	}
+
+	coreApplyMu.Lock()
+	defer coreApplyMu.Unlock()
+
+	configPath := GetPath(CoreConfigFilePath)
+	backupPath := GetPath(CoreConfigBackupFilePath)
+
+	previous, err := os.ReadFile(configPath)
+	hasPrevious := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return CoreApplyResult{}, err
+	}
+	if hasPrevious {
+		if err := WriteFileAtomic(backupPath, previous, 0644); err != nil {
+			return CoreApplyResult{}, err
+		}
+	}
+
+	if err := WriteFileAtomic(configPath, config, 0644); err != nil {
+		return CoreApplyResult{}, err
+	}
+
+	result := CoreApplyResult{}
+	pid, version, applyErr := a.restartAndWatch(config, options)
+	if applyErr == nil {
+		result.Applied = true
+		result.PID = pid
+		result.Version = version
+		a.emitCoreApply(result)
+		return result, nil
+	}
+
+	slog.Warn("ApplyCoreConfig failed, rolling back", "error", applyErr)
+	result.Error = applyErr.Error()
+
+	// With nothing to go back to, stop the core and remove the rejected config
+	if !hasPrevious {
+		if err := a.StopCore(defaultCoreStopTimeout); err != nil {
+			result.Error += "; stop failed: " + err.Error()
+		}
+		if err := os.Remove(configPath); err != nil {
+			result.Error += "; remove failed: " + err.Error()
+		} else {
+			result.RolledBack = true
+		}
+		a.emitCoreApply(result)
+		return result, nil
+	}
+
+	if err := WriteFileAtomic(configPath, previous, 0644); err != nil {
+		result.Error += "; restore failed: " + err.Error()
+		a.emitCoreApply(result)
+		return result, nil
+	}
+	result.RolledBack = true
+
+	pid, err = a.RestartCore(options.StartTimeout)
+	if err != nil {
+		result.Error += "; restart after rollback failed: " + err.Error()
+	}
+	result.PID = pid
+
+	a.emitCoreApply(result)
+	return result, nil
+}
+
+func (a *App) restartAndWatch(config []byte, options CoreApplyOptions) (int, string, error) {
+	pid, err := a.RestartCore(options.StartTimeout)
+	if err != nil {
+		return 0, "", err
+	}
+
+	api, apiErr := ResolveClashAPI(config)
+
+	grace := options.GracePeriod
+	if grace <= 0 {
+		grace = defaultCoreApplyGracePeriod
+	}
+	deadline := time.Now().Add(time.Duration(grace) * time.Second)
+
+	var (
+		version    string
+		versionErr error = errors.New("clash api did not respond")
+	)
+	for {
+		if status := a.CoreStatus(); !status.Running || status.PID != pid {
+			return 0, "", errors.New("the core exited during the grace period")
+		}
+		if apiErr == nil && version == "" {
+			version, versionErr = ClashAPIVersion(api, time.Second)
+		}
+		if time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(coreApplyPollInterval)
+	}
+
+	if apiErr == nil && version == "" {
+		return 0, "", versionErr
+	}
+
+	return pid, version, nil
+}
+
+func (a *App) emitCoreApply(result CoreApplyResult) {
+	if a.Bus != nil {
+		a.Bus.Emit("core::apply", result)
+	}
+}
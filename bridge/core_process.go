@@ -0,0 +1,309 @@
+package bridge
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	coreStartedKeyword = "sing-box started"
+	coreProcessName    = "sing-box"
+	coreMaxLineSize    = 1 << 20
+
+	defaultCoreStartTimeout = 30
+	defaultCoreStopTimeout  = 5
+)
+
+var defaultCoreArgs = []string{
+	"run",
+	"--disable-color",
+	"-c",
+	"$APP_BASE_PATH/$CORE_BASE_PATH/config.json",
+	"-D",
+	"$APP_BASE_PATH/$CORE_BASE_PATH",
+}
+
+var (
+	coreMu   sync.Mutex
+	coreProc *os.Process
+	coreDone chan struct{}
+
+	// coreStopping tells the wait goroutine that the exit was asked for, not a crash
+	coreStopping atomic.Bool
+
+	coreStarts  atomic.Uint64
+	coreCrashes atomic.Uint64
+)
+
+// CoreCrash is emitted as "core::crashed" when a managed core exits on its own.
+type CoreCrash struct {
+	PID    int    `json:"pid"`
+	Error  string `json:"error"`
+	Output string `json:"output"` // last line the core printed
+}
+
+type CoreStatus struct {
+	Running bool   `json:"running"`
+	PID     int    `json:"pid"`
+	Binary  string `json:"binary"`
+	Managed bool   `json:"managed"`
+}
+
+// CoreStatus reports the running core, whether it was started by the server or by the frontend.
+func (a *App) CoreStatus() CoreStatus {
+	coreMu.Lock()
+	defer coreMu.Unlock()
+
+	return currentCoreStatus()
+}
+
+func (a *App) StartCore(timeout int) (int, error) {
+	slog.Info("StartCore", "timeout", timeout)
+
+	coreMu.Lock()
+	defer coreMu.Unlock()
+
+	return a.startCore(timeout)
+}
+
+func (a *App) StopCore(timeout int) error {
+	slog.Info("StopCore", "timeout", timeout)
+
+	coreMu.Lock()
+	defer coreMu.Unlock()
+
+	return a.stopCore(timeout)
+}
+
+func (a *App) RestartCore(timeout int) (int, error) {
+	slog.Info("RestartCore", "timeout", timeout)
+
+	coreMu.Lock()
+	defer coreMu.Unlock()
+
+	if err := a.stopCore(defaultCoreStopTimeout); err != nil {
+		return 0, err
+	}
+	return a.startCore(timeout)
+}
+
+func currentCoreStatus() CoreStatus {
+	status := CoreStatus{Binary: CoreBinaryPath()}
+
+	if coreProc != nil {
+		select {
+		case <-coreDone:
+		default:
+			status.Running = true
+			status.PID = coreProc.Pid
+			status.Managed = true
+			return status
+		}
+	}
+
+	for _, pid := range []int{readCorePIDFile(CorePidFilePath), readCorePIDFile(CoreProcessCacheFilePath)} {
+		if isCorePIDAlive(pid) {
+			status.Running = true
+			status.PID = pid
+			return status
+		}
+	}
+
+	return status
+}
+
+// readCorePIDFile reads pid.txt ("<pid>") or the process cache ("<pid>,<path>").
+func readCorePIDFile(path string) int {
+	b, err := os.ReadFile(GetPath(path))
+	if err != nil {
+		return 0
+	}
+	value, _, _ := strings.Cut(strings.TrimSpace(string(b)), ",")
+	pid, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+	return pid
+}
+
+func isCorePIDAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return false
+	}
+	name, err := proc.Name()
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(name, coreProcessName)
+}
+
+func (a *App) startCore(timeout int) (int, error) {
+	if status := currentCoreStatus(); status.Running {
+		return 0, errors.New("the core is already running")
+	}
+
+	corePath := CoreBinaryPath()
+	if _, err := os.Stat(corePath); err != nil {
+		return 0, errors.New("core binary not found: " + corePath)
+	}
+
+	kernel := loadKernelConfig()
+	runtime := kernel.Main
+	if kernel.Branch == branchAlpha {
+		runtime = kernel.Alpha
+	}
+	args := runtime.Args
+	if len(args) == 0 {
+		args = defaultCoreArgs
+	}
+	args = slices.Clone(args)
+	for i, arg := range args {
+		args[i] = processMagicVariables(arg)
+	}
+
+	cmd := exec.Command(corePath, args...)
+	SetCmdWindowHidden(cmd)
+	cmd.Env = coreEnviron(runtime)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	cmd.Stderr = cmd.Stdout
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	started := make(chan struct{})
+	drained := make(chan struct{})
+	done := make(chan struct{})
+	var lastOutput string
+	var outputMu sync.Mutex
+
+	go func() {
+		defer close(drained)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), coreMaxLineSize)
+		notified := false
+		for scanner.Scan() {
+			text := scanner.Text()
+			outputMu.Lock()
+			lastOutput = text
+			outputMu.Unlock()
+			if a.Bus != nil {
+				a.Bus.Emit("core::output", text)
+			}
+			if !notified && strings.Contains(text, coreStartedKeyword) {
+				notified = true
+				close(started)
+			}
+		}
+		// Keep the pipe drained after an over-long line so the core never blocks on its log
+		_, _ = io.Copy(io.Discard, stdout)
+	}()
+
+	coreStopping.Store(false)
+	go func() {
+		// Wait closes the pipe, so read the last lines (usually the fatal error) first
+		<-drained
+		err := cmd.Wait()
+		close(done)
+		if a.Bus != nil {
+			a.Bus.Emit("core::stopped", cmd.Process.Pid)
+			if !coreStopping.Load() {
+				coreCrashes.Add(1)
+				crash := CoreCrash{PID: cmd.Process.Pid}
+				if err != nil {
+					crash.Error = err.Error()
+				}
+				outputMu.Lock()
+				crash.Output = lastOutput
+				outputMu.Unlock()
+				a.Bus.Emit("core::crashed", crash)
+			}
+		}
+	}()
+
+	coreProc = cmd.Process
+	coreDone = done
+
+	if timeout <= 0 {
+		timeout = defaultCoreStartTimeout
+	}
+
+	select {
+	case <-started:
+	case <-done:
+		outputMu.Lock()
+		defer outputMu.Unlock()
+		if lastOutput == "" {
+			lastOutput = "the core exited unexpectedly"
+		}
+		return 0, errors.New(lastOutput)
+	case <-time.After(time.Duration(timeout) * time.Second):
+		coreStopping.Store(true)
+		_ = cmd.Process.Kill()
+		<-done
+		return 0, errors.New("timed out waiting for the core to start")
+	}
+
+	pid := cmd.Process.Pid
+	_ = os.WriteFile(GetPath(CorePidFilePath), []byte(strconv.Itoa(pid)), 0644)
+	_ = os.WriteFile(GetPath(CoreProcessCacheFilePath), []byte(strconv.Itoa(pid)+","+corePath), 0644)
+	coreStarts.Add(1)
+
+	if a.Bus != nil {
+		a.Bus.Emit("core::started", pid)
+	}
+
+	return pid, nil
+}
+
+func (a *App) stopCore(timeout int) error {
+	status := currentCoreStatus()
+	if !status.Running {
+		return nil
+	}
+
+	proc, err := os.FindProcess(status.PID)
+	if err != nil {
+		return err
+	}
+
+	coreStopping.Store(true)
+	if err := SendExitSignal(proc); err != nil {
+		slog.Error("SendExitSignal", "error", err)
+	}
+
+	if timeout <= 0 {
+		timeout = defaultCoreStopTimeout
+	}
+	if err := waitForProcessExitWithTimeout(proc, timeout); err != nil {
+		return err
+	}
+
+	if status.Managed {
+		<-coreDone
+	}
+
+	_ = os.Remove(GetPath(CorePidFilePath))
+
+	return nil
+}
@@ -0,0 +1,536 @@
+package bridge
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	CoreVersionsDirectory = CoreWorkingDirectory + "/versions"
+	CoreVersionsFilePath  = CoreVersionsDirectory + "/versions.yaml"
+
+	defaultCoreAssetURL = "https://github.com/SagerNet/sing-box/releases/download/v{version}/{asset}"
+)
+
+var (
+	coreVersionsMu      sync.Mutex
+	coreVersionPattern  = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+-]*$`)
+	sha256DigestPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+)
+
+// CoreReleaseSource describes where core archives are downloaded from. Both URLs are
+// templates supporting {version}, {os}, {arch}, {ext} and {asset}.
+type CoreReleaseSource struct {
+	AssetURL    string `yaml:"assetURL" json:"assetURL"`
+	ChecksumURL string `yaml:"checksumURL" json:"checksumURL"`
+}
+
+type CoreVersionsState struct {
+	Source   CoreReleaseSource `yaml:"source" json:"source"`
+	Active   string            `yaml:"active" json:"active"`
+	Previous string            `yaml:"previous" json:"previous"`
+	Digests  map[string]string `yaml:"digests" json:"digests"`
+}
+
+type CoreVersion struct {
+	Version string `json:"version"`
+	Digest  string `json:"digest"`
+	Size    int64  `json:"size"`
+	Active  bool   `json:"active"`
+}
+
+type CoreVersionsResult struct {
+	Source   CoreReleaseSource `json:"source"`
+	Active   string            `json:"active"`
+	Previous string            `json:"previous"`
+	Versions []CoreVersion     `json:"versions"`
+}
+
+type CoreInstallOptions struct {
+	Version  string         `json:"version"`
+	Digest   string         `json:"digest"` // expected SHA-256 of the release archive
+	Event    string         `json:"event"`  // optional download progress event
+	Activate bool           `json:"activate"`
+	Request  RequestOptions `json:"options"`
+}
+
+func loadCoreVersionsState() CoreVersionsState {
+	state := CoreVersionsState{}
+	b, err := os.ReadFile(GetPath(CoreVersionsFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &state)
+	}
+	if state.Source.AssetURL == "" {
+		state.Source.AssetURL = defaultCoreAssetURL
+	}
+	if state.Digests == nil {
+		state.Digests = map[string]string{}
+	}
+	return state
+}
+
+func saveCoreVersionsState(state CoreVersionsState) error {
+	b, err := yaml.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(GetPath(CoreVersionsFilePath), b, 0644)
+}
+
+func coreAssetName(version string) string {
+	return "sing-box-" + version + "-" + Env.OS + "-" + Env.ARCH + coreAssetExt()
+}
+
+func coreAssetExt() string {
+	if Env.OS == "windows" {
+		return ".zip"
+	}
+	return ".tar.gz"
+}
+
+func expandCoreSourceURL(template string, version string) string {
+	return strings.NewReplacer(
+		"{version}", version,
+		"{os}", Env.OS,
+		"{arch}", Env.ARCH,
+		"{ext}", coreAssetExt(),
+		"{asset}", coreAssetName(version),
+	).Replace(template)
+}
+
+func coreVersionBinary(version string) string {
+	return GetPath(CoreVersionsDirectory + "/" + version + "/" + coreFileName(false))
+}
+
+func normalizeCoreVersion(version string) (string, error) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	if !coreVersionPattern.MatchString(version) {
+		return "", errors.New("invalid core version: " + version)
+	}
+	return version, nil
+}
+
+func (a *App) ListCoreVersions() (CoreVersionsResult, error) {
+	slog.Info("ListCoreVersions")
+
+	coreVersionsMu.Lock()
+	defer coreVersionsMu.Unlock()
+
+	state := loadCoreVersionsState()
+	result := CoreVersionsResult{
+		Source:   state.Source,
+		Active:   state.Active,
+		Previous: state.Previous,
+		Versions: []CoreVersion{},
+	}
+
+	entries, err := os.ReadDir(GetPath(CoreVersionsDirectory))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return result, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		info, err := os.Stat(coreVersionBinary(entry.Name()))
+		if err != nil {
+			continue
+		}
+		result.Versions = append(result.Versions, CoreVersion{
+			Version: entry.Name(),
+			Digest:  state.Digests[entry.Name()],
+			Size:    info.Size(),
+			Active:  entry.Name() == state.Active,
+		})
+	}
+
+	return result, nil
+}
+
+func (a *App) SetCoreReleaseSource(source CoreReleaseSource) error {
+	slog.Info("SetCoreReleaseSource", "source", source)
+
+	coreVersionsMu.Lock()
+	defer coreVersionsMu.Unlock()
+
+	state := loadCoreVersionsState()
+	state.Source = source
+	if state.Source.AssetURL == "" {
+		state.Source.AssetURL = defaultCoreAssetURL
+	}
+	return saveCoreVersionsState(state)
+}
+
+// InstallCoreVersion downloads a release archive, verifies its SHA-256 digest and extracts
+// the core binary into data/sing-box/versions/<version>/.
+func (a *App) InstallCoreVersion(options CoreInstallOptions) (CoreVersion, error) {
+	slog.Info("InstallCoreVersion", "version", options.Version)
+
+	version, err := normalizeCoreVersion(options.Version)
+	if err != nil {
+		return CoreVersion{}, err
+	}
+
+	coreVersionsMu.Lock()
+	state := loadCoreVersionsState()
+	coreVersionsMu.Unlock()
+
+	asset := coreAssetName(version)
+	expected := strings.ToLower(strings.TrimSpace(options.Digest))
+	if expected == "" && state.Source.ChecksumURL != "" {
+		expected, err = a.fetchCoreChecksum(expandCoreSourceURL(state.Source.ChecksumURL, version), asset, options.Request)
+		if err != nil {
+			return CoreVersion{}, err
+		}
+	}
+	if expected == "" {
+		return CoreVersion{}, errors.New("no SHA-256 digest supplied and no checksum source configured")
+	}
+	if !sha256DigestPattern.MatchString(expected) {
+		return CoreVersion{}, errors.New("invalid SHA-256 digest: " + expected)
+	}
+
+	archivePath := GetPath("data/.cache/" + asset)
+	defer os.Remove(archivePath)
+
+	digest, err := a.downloadCoreArchive(expandCoreSourceURL(state.Source.AssetURL, version), archivePath, options)
+	if err != nil {
+		return CoreVersion{}, err
+	}
+	if digest != expected {
+		return CoreVersion{}, errors.New("checksum mismatch: expected " + expected + ", got " + digest)
+	}
+
+	binaryPath := coreVersionBinary(version)
+	if err := extractCoreBinary(archivePath, binaryPath); err != nil {
+		return CoreVersion{}, err
+	}
+
+	info, err := os.Stat(binaryPath)
+	if err != nil {
+		return CoreVersion{}, err
+	}
+
+	coreVersionsMu.Lock()
+	state = loadCoreVersionsState()
+	state.Digests[version] = digest
+	err = saveCoreVersionsState(state)
+	coreVersionsMu.Unlock()
+	if err != nil {
+		return CoreVersion{}, err
+	}
+
+	result := CoreVersion{Version: version, Digest: digest, Size: info.Size()}
+
+	if options.Activate {
+		if err := a.ActivateCoreVersion(version, false); err != nil {
+			return result, err
+		}
+		result.Active = true
+	}
+
+	return result, nil
+}
+
+// ActivateCoreVersion atomically replaces the active core binary with an installed version.
+func (a *App) ActivateCoreVersion(version string, restart bool) error {
+	slog.Info("ActivateCoreVersion", "version", version, "restart", restart)
+
+	version, err := normalizeCoreVersion(version)
+	if err != nil {
+		return err
+	}
+
+	coreVersionsMu.Lock()
+	defer coreVersionsMu.Unlock()
+
+	state := loadCoreVersionsState()
+	if err := installCoreBinary(coreVersionBinary(version), CoreBinaryPath()); err != nil {
+		return err
+	}
+
+	if state.Active != version {
+		state.Previous = state.Active
+		state.Active = version
+	}
+	if err := saveCoreVersionsState(state); err != nil {
+		return err
+	}
+
+	if a.Bus != nil {
+		a.Bus.Emit("core::version", version)
+	}
+
+	if restart && a.CoreStatus().Running {
+		if _, err := a.RestartCore(0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RollbackCoreVersion re-activates the previously active version.
+func (a *App) RollbackCoreVersion(restart bool) (string, error) {
+	slog.Info("RollbackCoreVersion")
+
+	coreVersionsMu.Lock()
+	previous := loadCoreVersionsState().Previous
+	coreVersionsMu.Unlock()
+
+	if previous == "" {
+		return "", errors.New("no previous core version to roll back to")
+	}
+	return previous, a.ActivateCoreVersion(previous, restart)
+}
+
+func (a *App) RemoveCoreVersion(version string) error {
+	slog.Info("RemoveCoreVersion", "version", version)
+
+	version, err := normalizeCoreVersion(version)
+	if err != nil {
+		return err
+	}
+
+	coreVersionsMu.Lock()
+	defer coreVersionsMu.Unlock()
+
+	state := loadCoreVersionsState()
+	if state.Active == version {
+		return errors.New("cannot remove the active core version")
+	}
+	if err := os.RemoveAll(GetPath(CoreVersionsDirectory + "/" + version)); err != nil {
+		return err
+	}
+	delete(state.Digests, version)
+	if state.Previous == version {
+		state.Previous = ""
+	}
+	return saveCoreVersionsState(state)
+}
+
+func (a *App) fetchCoreChecksum(url string, asset string, options RequestOptions) (string, error) {
+	result := a.Requests(http.MethodGet, url, nil, "", withRedirect(options))
+	if !result.Flag {
+		return "", errors.New(result.Body)
+	}
+	if result.Status != http.StatusOK {
+		return "", errors.New("failed to fetch checksum file: status " + strconv.Itoa(result.Status))
+	}
+	return parseChecksumFile(result.Body, asset)
+}
+
+// parseChecksumFile understands sha256sum output ("<digest>  <file>") as well as a bare digest.
+func parseChecksumFile(content string, asset string) (string, error) {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) == 1 && sha256DigestPattern.MatchString(fields[0]):
+			return strings.ToLower(fields[0]), nil
+		case len(fields) >= 2 && strings.TrimPrefix(fields[len(fields)-1], "*") == asset:
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	return "", errors.New("checksum not found for " + asset)
+}
+
+func (a *App) downloadCoreArchive(url string, archivePath string, options CoreInstallOptions) (string, error) {
+	request := withRedirect(options.Request)
+	if request.Timeout <= 0 {
+		request.Timeout = 20 * 60
+	}
+	client, ctx, cancel := withRequestOptionsClient(request)
+	defer cancel()
+
+	if request.CancelId != "" && a.Bus != nil {
+		defer a.Bus.On(request.CancelId, func(_ []any) { cancel() })()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("failed to download core: " + resp.Status)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(archivePath), os.ModePerm); err != nil {
+		return "", err
+	}
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	reader := wrapWithProgress(resp.Body, resp.ContentLength, options.Event, a)
+	if _, err := io.Copy(io.MultiWriter(file, hash), reader); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func withRedirect(options RequestOptions) RequestOptions {
+	options.Redirect = true
+	return options
+}
+
+func extractCoreBinary(archivePath string, binaryPath string) error {
+	name := coreFileName(false)
+	tmpPath := binaryPath + ".tmp"
+
+	if err := os.MkdirAll(filepath.Dir(binaryPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	var err error
+	if strings.HasSuffix(archivePath, ".zip") {
+		err = extractFromZip(archivePath, name, tmpPath)
+	} else {
+		err = extractFromTarGZ(archivePath, name, tmpPath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, binaryPath)
+}
+
+func extractFromZip(archivePath string, name string, output string) error {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || path.Base(f.Name) != name {
+			continue
+		}
+		src, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		return writeExecutable(output, src)
+	}
+
+	return errors.New(name + " not found in archive")
+}
+
+func extractFromTarGZ(archivePath string, name string, output string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg || path.Base(header.Name) != name {
+			continue
+		}
+		return writeExecutable(output, tarReader)
+	}
+
+	return errors.New(name + " not found in archive")
+}
+
+func writeExecutable(output string, src io.Reader) error {
+	dst, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
+
+// installCoreBinary copies source next to target and renames it into place. A running
+// executable cannot be replaced on Windows, so the old binary is moved aside first.
+func installCoreBinary(source string, target string) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmpPath := target + ".new"
+	if err := writeExecutable(tmpPath, src); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, target); err == nil {
+		return nil
+	}
+
+	oldPath := target + ".old-" + time.Now().Format("20060102150405")
+	if err := os.Rename(target, oldPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, target); err != nil {
+		_ = os.Rename(oldPath, target)
+		return err
+	}
+	cleanupOldCoreBinaries(target, oldPath)
+
+	return nil
+}
+
+// cleanupOldCoreBinaries removes binaries moved aside by earlier switches that are no longer in use.
+func cleanupOldCoreBinaries(target string, keep string) {
+	matches, _ := filepath.Glob(target + ".old-*")
+	for _, match := range matches {
+		if match != keep {
+			_ = os.Remove(match)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"os"
 	"os/exec"
 	"strconv"
@@ -16,7 +17,25 @@ import (
 )
 
 func (a *App) Exec(path string, args []string, options ExecOptions) FlagResult {
-	log.Printf("Exec: %s %s %v", path, args, options)
+	out, err := a.execContext(context.Background(), path, args, options)
+	if err != nil {
+		return FlagResult{false, err.Error()}
+	}
+
+	var output string
+	if options.Convert {
+		output = ConvertByte2String(out)
+	} else {
+		output = string(out)
+	}
+
+	return FlagResult{true, output}
+}
+
+// execContext is Exec for server-side callers that need a deadline or the output of a
+// failed command, which is returned along with the error.
+func (a *App) execContext(ctx context.Context, path string, args []string, options ExecOptions) ([]byte, error) {
+	slog.Info("Exec", "path", path, "args", strings.Join(args, " "), "env", sortedKeys(options.Env))
 
 	exePath := GetPath(path)
 
@@ -24,7 +43,7 @@ func (a *App) Exec(path string, args []string, options ExecOptions) FlagResult {
 		exePath = path
 	}
 
-	cmd := exec.Command(exePath, args...)
+	cmd := exec.CommandContext(ctx, exePath, args...)
 	SetCmdWindowHidden(cmd)
 
 	cmd.Env = os.Environ()
@@ -33,23 +52,11 @@ func (a *App) Exec(path string, args []string, options ExecOptions) FlagResult {
 		cmd.Env = append(cmd.Env, key+"="+value)
 	}
 
-	out, err := cmd.CombinedOutput()
-	if err != nil {
-		return FlagResult{false, err.Error()}
-	}
-
-	var output string
-	if options.Convert {
-		output = ConvertByte2String(out)
-	} else {
-		output = string(out)
-	}
-
-	return FlagResult{true, output}
+	return cmd.CombinedOutput()
 }
 
 func (a *App) ExecBackground(path string, args []string, outEvent string, endEvent string, options ExecOptions) FlagResult {
-	log.Printf("ExecBackground: %s %s %s %s %v", path, args, outEvent, endEvent, options)
+	slog.Info("ExecBackground", "path", path, "args", strings.Join(args, " "), "outEvent", outEvent, "endEvent", endEvent, "env", sortedKeys(options.Env))
 
 	exePath := GetPath(path)
 	absPath := exePath
@@ -158,7 +165,7 @@ func (a *App) KillProcess(pid int, timeout int) FlagResult {
 	}
 
 	if err := SendExitSignal(process); err != nil {
-		log.Printf("SendExitSignal Err: %s", err.Error())
+		slog.Error("SendExitSignal", "pid", pid, "error", err)
 	}
 
 	if err := waitForProcessExitWithTimeout(process, timeout); err != nil {
@@ -0,0 +1,426 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FailoverSettingsFilePath = "data/failover.yaml"
+	FailoverStateFilePath    = "data/failover-state.json"
+)
+
+// FailoverPolicy moves a selector group off its current choice once the latency prober has seen
+// it fail FailureThreshold times in a row.
+type FailoverPolicy struct {
+	Group             string `yaml:"group" json:"group"`
+	Disabled          bool   `yaml:"disabled" json:"disabled"`
+	FailureThreshold  int    `yaml:"failureThreshold" json:"failureThreshold"`   // consecutive failures of the current choice
+	RecoveryThreshold int    `yaml:"recoveryThreshold" json:"recoveryThreshold"` // consecutive successes a candidate needs
+	HoldTime          int    `yaml:"holdTime" json:"holdTime"`                   // seconds after a switch before the next one, -1 for none
+	BlacklistWindow   int    `yaml:"blacklistWindow" json:"blacklistWindow"`     // seconds an abandoned outbound is skipped
+}
+
+type FailoverSettings struct {
+	Disabled   bool             `yaml:"disabled" json:"disabled"`
+	EventLimit int              `yaml:"eventLimit" json:"eventLimit"`
+	Policies   []FailoverPolicy `yaml:"policies" json:"policies"`
+}
+
+type FailoverPin struct {
+	Outbound string `json:"outbound"`
+	Time     int64  `json:"time"`
+}
+
+type FailoverEvent struct {
+	Time   int64  `json:"time"`
+	Group  string `json:"group"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Reason string `json:"reason"`
+	Error  string `json:"error,omitempty"`
+}
+
+type failoverState struct {
+	Pins       map[string]FailoverPin      `json:"pins"`
+	Blacklist  map[string]map[string]int64 `json:"blacklist"` // group -> outbound -> until
+	LastSwitch map[string]int64            `json:"lastSwitch"`
+	Stalled    map[string]bool             `json:"stalled"` // a failure (no healthy member, pin not restored) is already logged
+	Events     []FailoverEvent             `json:"events"`
+}
+
+type FailoverStatus struct {
+	Settings   FailoverSettings            `json:"settings"`
+	Pins       map[string]FailoverPin      `json:"pins"`
+	Blacklist  map[string]map[string]int64 `json:"blacklist"`
+	LastSwitch map[string]int64            `json:"lastSwitch"`
+}
+
+// Failover applies the failover policies after every latency probe round.
+type Failover struct {
+	app *App
+
+	mu     sync.Mutex
+	state  failoverState
+	evalMu sync.Mutex // one evaluation at a time, from a probe round or the API
+}
+
+func NewFailover(a *App) *Failover {
+	f := &Failover{app: a, state: newFailoverState()}
+	f.loadState()
+	return f
+}
+
+func newFailoverState() failoverState {
+	return failoverState{
+		Pins:       make(map[string]FailoverPin),
+		Blacklist:  make(map[string]map[string]int64),
+		LastSwitch: make(map[string]int64),
+		Stalled:    make(map[string]bool),
+		Events:     []FailoverEvent{},
+	}
+}
+
+func (f *Failover) Status() FailoverStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.expireBlacklist(time.Now().UnixMilli())
+	status := FailoverStatus{
+		Settings:   loadFailoverSettings(),
+		Pins:       maps.Clone(f.state.Pins),
+		Blacklist:  make(map[string]map[string]int64, len(f.state.Blacklist)),
+		LastSwitch: maps.Clone(f.state.LastSwitch),
+	}
+	for group, outbounds := range f.state.Blacklist {
+		status.Blacklist[group] = maps.Clone(outbounds)
+	}
+	return status
+}
+
+// Events returns the switch log, newest first.
+func (f *Failover) Events() []FailoverEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := slices.Clone(f.state.Events)
+	slices.Reverse(events)
+	return events
+}
+
+// Pin selects outbound in group and keeps the policy from switching it until Unpin.
+func (f *Failover) Pin(ctx context.Context, group, outbound string) error {
+	slog.Info("Failover Pin", "group", group, "outbound", outbound)
+
+	api, err := ActiveClashAPI()
+	if err != nil {
+		return err
+	}
+	selector, err := clashSelector(ctx, api, group)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(selector.All, outbound) {
+		return errors.New(outbound + " is not a member of " + group)
+	}
+	if err := selectClashProxy(ctx, api, group, outbound); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	now := time.Now().UnixMilli()
+	f.state.Pins[group] = FailoverPin{Outbound: outbound, Time: now}
+	delete(f.state.Stalled, group)
+	f.appendEvent(FailoverEvent{Time: now, Group: group, From: selector.Now, To: outbound, Reason: "pinned"}, loadFailoverSettings().EventLimit)
+	f.mu.Unlock()
+
+	f.saveState()
+	return nil
+}
+
+func (f *Failover) Unpin(group string) {
+	slog.Info("Failover Unpin", "group", group)
+
+	f.mu.Lock()
+	delete(f.state.Pins, group)
+	delete(f.state.Stalled, group)
+	f.mu.Unlock()
+
+	f.saveState()
+}
+
+// Evaluate applies the policies against the prober's current health records.
+func (f *Failover) Evaluate(ctx context.Context) ([]FailoverEvent, error) {
+	if !f.app.CoreStatus().Running {
+		return nil, errors.New("the core is not running")
+	}
+	api, err := ActiveClashAPI()
+	if err != nil {
+		return nil, err
+	}
+	return f.evaluate(ctx, api, f.app.Prober.snapshot()), nil
+}
+
+func (f *Failover) evaluate(ctx context.Context, api ClashAPI, health map[string]OutboundHealth) []FailoverEvent {
+	f.evalMu.Lock()
+	defer f.evalMu.Unlock()
+
+	settings := loadFailoverSettings()
+	if settings.Disabled {
+		return []FailoverEvent{}
+	}
+
+	events := []FailoverEvent{}
+	for _, policy := range settings.Policies {
+		if policy.Disabled || policy.Group == "" {
+			continue
+		}
+		if event, ok := f.evaluatePolicy(ctx, api, policy, health); ok {
+			events = append(events, event)
+		}
+	}
+
+	if len(events) > 0 {
+		f.mu.Lock()
+		for _, event := range events {
+			f.appendEvent(event, settings.EventLimit)
+		}
+		f.mu.Unlock()
+		f.saveState()
+
+		if f.app.Bus != nil {
+			for _, event := range events {
+				f.app.Bus.Emit("failover::switched", event)
+			}
+		}
+	}
+	return events
+}
+
+func (f *Failover) evaluatePolicy(ctx context.Context, api ClashAPI, policy FailoverPolicy, health map[string]OutboundHealth) (FailoverEvent, bool) {
+	now := time.Now().UnixMilli()
+	event := FailoverEvent{Time: now, Group: policy.Group}
+
+	selector, err := clashSelector(ctx, api, policy.Group)
+	if err != nil {
+		slog.Warn("Failover selector", "group", policy.Group, "error", err)
+		return event, false
+	}
+	event.From = selector.Now
+
+	f.mu.Lock()
+	f.expireBlacklist(now)
+	pin, pinned := f.state.Pins[policy.Group]
+	lastSwitch := f.state.LastSwitch[policy.Group]
+	blacklist := maps.Clone(f.state.Blacklist[policy.Group])
+	stalled := f.state.Stalled[policy.Group]
+	f.mu.Unlock()
+
+	// A pinned group is only ever put back on its pin, e.g. after the core restarted. A pin
+	// that keeps failing to apply is reported once, like a stalled policy.
+	if pinned {
+		if selector.Now == pin.Outbound {
+			if stalled {
+				f.setStalled(policy.Group, false)
+			}
+			return event, false
+		}
+		event.To, event.Reason = pin.Outbound, "pinned"
+		if err := selectClashProxy(ctx, api, policy.Group, pin.Outbound); err != nil {
+			if stalled {
+				return event, false
+			}
+			f.setStalled(policy.Group, true)
+			event.Error = err.Error()
+			return event, true
+		}
+		if stalled {
+			f.setStalled(policy.Group, false)
+		}
+		return event, true
+	}
+
+	current, ok := health[selector.Now]
+	if !ok || current.Failures < policy.FailureThreshold {
+		if stalled {
+			f.setStalled(policy.Group, false)
+		}
+		return event, false
+	}
+	if now-lastSwitch < int64(policy.HoldTime)*1000 {
+		return event, false
+	}
+
+	best, bestDelay := "", 0
+	for _, member := range selector.All {
+		h, ok := health[member]
+		if member == selector.Now || !ok || !h.Up || blacklist[member] > now {
+			continue
+		}
+		delay, healthy := recentDelay(h.History, policy.RecoveryThreshold)
+		if healthy && (best == "" || delay < bestDelay) {
+			best, bestDelay = member, delay
+		}
+	}
+	if best == "" {
+		if stalled {
+			return event, false
+		}
+		f.setStalled(policy.Group, true)
+		event.Reason, event.Error = "failed", "no healthy member to switch to"
+		return event, true
+	}
+
+	event.To = best
+	event.Reason = strconv.Itoa(current.Failures) + " consecutive failures"
+	if err := selectClashProxy(ctx, api, policy.Group, best); err != nil {
+		event.Error = err.Error()
+		return event, true
+	}
+
+	f.mu.Lock()
+	if f.state.Blacklist[policy.Group] == nil {
+		f.state.Blacklist[policy.Group] = make(map[string]int64)
+	}
+	f.state.Blacklist[policy.Group][selector.Now] = now + int64(policy.BlacklistWindow)*1000
+	f.state.LastSwitch[policy.Group] = now
+	delete(f.state.Stalled, policy.Group)
+	f.mu.Unlock()
+	return event, true
+}
+
+func (f *Failover) setStalled(group string, stalled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if stalled {
+		f.state.Stalled[group] = true
+	} else {
+		delete(f.state.Stalled, group)
+	}
+}
+
+// recentDelay averages the last n samples, and reports whether all of them succeeded.
+func recentDelay(history []LatencySample, n int) (int, bool) {
+	if len(history) < n {
+		return 0, false
+	}
+	total := 0
+	for _, sample := range history[len(history)-n:] {
+		if sample.Delay <= 0 {
+			return 0, false
+		}
+		total += sample.Delay
+	}
+	return total / n, true
+}
+
+type clashSelectorInfo struct {
+	Type string   `json:"type"`
+	Now  string   `json:"now"`
+	All  []string `json:"all"`
+}
+
+func clashSelector(ctx context.Context, api ClashAPI, group string) (clashSelectorInfo, error) {
+	var info clashSelectorInfo
+	if err := clashAPIRequest(ctx, api, "GET", "/proxies/"+url.PathEscape(group), nil, &info); err != nil {
+		return info, err
+	}
+	if info.Type != "Selector" {
+		return info, errors.New(group + " is not a selector group")
+	}
+	return info, nil
+}
+
+func selectClashProxy(ctx context.Context, api ClashAPI, group, outbound string) error {
+	return clashAPIRequest(ctx, api, "PUT", "/proxies/"+url.PathEscape(group), map[string]string{"name": outbound}, nil)
+}
+
+// appendEvent must be called with f.mu held.
+func (f *Failover) appendEvent(event FailoverEvent, limit int) {
+	f.state.Events = append(f.state.Events, event)
+	if overflow := len(f.state.Events) - limit; overflow > 0 {
+		f.state.Events = slices.Clone(f.state.Events[overflow:])
+	}
+}
+
+// expireBlacklist must be called with f.mu held.
+func (f *Failover) expireBlacklist(now int64) {
+	for group, outbounds := range f.state.Blacklist {
+		for outbound, until := range outbounds {
+			if until <= now {
+				delete(outbounds, outbound)
+			}
+		}
+		if len(outbounds) == 0 {
+			delete(f.state.Blacklist, group)
+		}
+	}
+}
+
+func (f *Failover) loadState() {
+	b, err := os.ReadFile(GetPath(FailoverStateFilePath))
+	if err != nil {
+		return
+	}
+	state := newFailoverState()
+	if err := json.Unmarshal(b, &state); err != nil {
+		slog.Error("Failover loadState", "error", err)
+		return
+	}
+	f.state = state
+}
+
+func (f *Failover) saveState() {
+	f.mu.Lock()
+	b, err := json.Marshal(f.state)
+	f.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(GetPath(FailoverStateFilePath), b, 0644); err != nil {
+		slog.Error("Failover saveState", "error", err)
+	}
+}
+
+func loadFailoverSettings() FailoverSettings {
+	settings := FailoverSettings{}
+	b, err := os.ReadFile(GetPath(FailoverSettingsFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	if settings.EventLimit <= 0 {
+		settings.EventLimit = 200
+	}
+	for i := range settings.Policies {
+		policy := &settings.Policies[i]
+		policy.Group = strings.TrimSpace(policy.Group)
+		if policy.FailureThreshold <= 0 {
+			policy.FailureThreshold = 3
+		}
+		if policy.RecoveryThreshold <= 0 {
+			policy.RecoveryThreshold = 2
+		}
+		if policy.HoldTime == 0 {
+			policy.HoldTime = 300
+		} else if policy.HoldTime < 0 {
+			policy.HoldTime = 0
+		}
+		if policy.BlacklistWindow <= 0 {
+			policy.BlacklistWindow = 900
+		}
+	}
+	return settings
+}
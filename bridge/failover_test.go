@@ -0,0 +1,63 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestFailoverPinFailureReportedOnce(t *testing.T) {
+	var mu sync.Mutex
+	now, accept := "A", false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == http.MethodPut {
+			if !accept {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(map[string]string{"message": "selector is busy"})
+				return
+			}
+			now = "B"
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(clashSelectorInfo{Type: "Selector", Now: now, All: []string{"A", "B"}})
+	}))
+	defer server.Close()
+
+	f := &Failover{state: newFailoverState()}
+	f.state.Pins["proxy"] = FailoverPin{Outbound: "B"}
+	api := ClashAPI{Base: server.URL}
+	policy := FailoverPolicy{Group: "proxy"}
+
+	evaluate := func() (FailoverEvent, bool) {
+		return f.evaluatePolicy(context.Background(), api, policy, map[string]OutboundHealth{})
+	}
+
+	event, ok := evaluate()
+	if !ok || event.Error != "selector is busy" {
+		t.Fatalf("first failure = %+v, %v; want a reported error", event, ok)
+	}
+	for i := 0; i < 3; i++ {
+		if event, ok := evaluate(); ok {
+			t.Fatalf("repeated failure reported again: %+v", event)
+		}
+	}
+
+	mu.Lock()
+	accept = true
+	mu.Unlock()
+	if event, ok := evaluate(); !ok || event.Error != "" || event.To != "B" {
+		t.Fatalf("restored pin = %+v, %v; want a successful switch", event, ok)
+	}
+	if f.state.Stalled["proxy"] {
+		t.Error("stalled flag kept after the pin was restored")
+	}
+	if event, ok := evaluate(); ok {
+		t.Errorf("pin already applied but got event %+v", event)
+	}
+}
@@ -0,0 +1,77 @@
+package bridge
+
+import (
+	"strconv"
+	"strings"
+)
+
+// fieldMap wraps a decoded YAML/JSON object (a Clash proxy, a sing-box outbound) with
+// typed accessors that tolerate the number and list encodings of both decoders.
+type fieldMap map[string]any
+
+func (p fieldMap) str(key string) string {
+	return anyToString(p[key])
+}
+
+func (p fieldMap) num(key string) int {
+	switch v := p[key].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case string:
+		n, _ := strconv.Atoi(leadingDigits(v))
+		return n
+	}
+	return 0
+}
+
+func (p fieldMap) boolean(key string) bool {
+	switch v := p[key].(type) {
+	case bool:
+		return v
+	case string:
+		return v == "true" || v == "1"
+	case int:
+		return v != 0
+	}
+	return false
+}
+
+func (p fieldMap) obj(key string) fieldMap {
+	if v, ok := p[key].(map[string]any); ok {
+		return fieldMap(v)
+	}
+	return fieldMap{}
+}
+
+func (p fieldMap) list(key string) []string {
+	switch v := p[key].(type) {
+	case []string:
+		return v
+	case []int:
+		list := []string{}
+		for _, item := range v {
+			list = append(list, strconv.Itoa(item))
+		}
+		return list
+	case []any:
+		list := []string{}
+		for _, item := range v {
+			list = append(list, anyToString(item))
+		}
+		return list
+	case string:
+		return splitList(v)
+	}
+	return nil
+}
+
+func leadingDigits(value string) string {
+	value = strings.TrimSpace(value)
+	end := 0
+	for end < len(value) && value[end] >= '0' && value[end] <= '9' {
+		end++
+	}
+	return value[:end]
+}
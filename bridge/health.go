@@ -0,0 +1,73 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"os"
+)
+
+const (
+	HealthOK      = "ok"
+	HealthFail    = "fail"
+	HealthSkipped = "skipped"
+)
+
+// HealthCheck is one entry of /readyz.
+type HealthCheck struct {
+	Status string         `json:"status"` // ok / fail / skipped
+	Error  string         `json:"error,omitempty"`
+	Detail map[string]any `json:"detail,omitempty"`
+}
+
+func healthFailed(err error) HealthCheck {
+	return HealthCheck{Status: HealthFail, Error: err.Error()}
+}
+
+// CheckDataDir creates and removes a file in data/, which every setting and cache needs.
+func (a *App) CheckDataDir() HealthCheck {
+	f, err := os.CreateTemp(GetPath("data"), ".readyz-*")
+	if err != nil {
+		return healthFailed(err)
+	}
+	name := f.Name()
+	_, err = f.Write([]byte("ok"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if removeErr := os.Remove(name); err == nil {
+		err = removeErr
+	}
+	if err != nil {
+		return healthFailed(err)
+	}
+	return HealthCheck{Status: HealthOK}
+}
+
+// CheckCore reports whether the core runs and, when its config enables the Clash API,
+// whether the API answers.
+func (a *App) CheckCore(ctx context.Context) HealthCheck {
+	status := a.CoreStatus()
+	if !status.Running {
+		return HealthCheck{Status: HealthFail, Error: "the core is not running"}
+	}
+	check := HealthCheck{
+		Status: HealthOK,
+		Detail: map[string]any{"pid": status.PID, "managed": status.Managed},
+	}
+
+	api, err := ActiveClashAPI()
+	if err != nil {
+		check.Detail["clashAPI"] = err.Error()
+		return check
+	}
+	var version struct {
+		Version string `json:"version"`
+	}
+	if err := clashAPIRequest(ctx, api, http.MethodGet, "/version", nil, &version); err != nil {
+		check.Status = HealthFail
+		check.Error = "clash api: " + err.Error()
+		return check
+	}
+	check.Detail["version"] = version.Version
+	return check
+}
@@ -0,0 +1,295 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	InboxFilePath = "data/notifications.json"
+
+	NotificationInfo    = "info"
+	NotificationWarning = "warning"
+	NotificationError   = "error"
+
+	inboxLimit     = 500
+	inboxQueueSize = 64
+)
+
+type Notification struct {
+	ID       string `json:"id"`
+	Time     int64  `json:"time"`
+	Title    string `json:"title"`
+	Message  string `json:"message"`
+	Severity string `json:"severity"` // info / warning / error
+	Source   string `json:"source"`   // app / scheduler / subscription / ruleset / core / failover
+	Read     bool   `json:"read"`
+}
+
+type InboxQuery struct {
+	Unread bool
+	Source string
+	Limit  int // every item when zero
+}
+
+type InboxList struct {
+	Items  []Notification `json:"items"`
+	Total  int            `json:"total"`
+	Unread int            `json:"unread"`
+}
+
+// Inbox keeps the most recent notifications on disk so a panel opened later still sees
+// what happened while nobody was watching.
+type Inbox struct {
+	app *App
+
+	mu    sync.Mutex
+	items []Notification // oldest first
+
+	saveMu sync.Mutex // keeps concurrent saves from writing an older list last
+
+	unobserve func()
+	queue     chan inboxEvent
+	stop      chan struct{}
+	done      chan struct{}
+	once      sync.Once
+}
+
+type inboxEvent struct {
+	name    string
+	payload any
+}
+
+func NewInbox(a *App) *Inbox {
+	inbox := &Inbox{
+		app:   a,
+		queue: make(chan inboxEvent, inboxQueueSize),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	inbox.load()
+	return inbox
+}
+
+// Start records failures reported on the bus by the server's background work.
+func (i *Inbox) Start() {
+	if i.app.Bus != nil {
+		i.unobserve = i.app.Bus.Observe(i.observe)
+	}
+	go i.loop()
+}
+
+func (i *Inbox) Stop() {
+	if i.unobserve != nil {
+		i.unobserve()
+	}
+	i.once.Do(func() {
+		close(i.stop)
+	})
+	<-i.done
+}
+
+func (i *Inbox) Add(title, message, severity, source string) Notification {
+	switch severity {
+	case NotificationInfo, NotificationWarning, NotificationError:
+	default:
+		severity = NotificationInfo
+	}
+	if source == "" {
+		source = "app"
+	}
+	item := Notification{
+		ID:       sampleID(),
+		Time:     time.Now().UnixMilli(),
+		Title:    title,
+		Message:  message,
+		Severity: severity,
+		Source:   source,
+	}
+
+	i.mu.Lock()
+	i.items = append(i.items, item)
+	if overflow := len(i.items) - inboxLimit; overflow > 0 {
+		i.items = slices.Clone(i.items[overflow:])
+	}
+	i.mu.Unlock()
+	i.save()
+
+	if i.app.Bus != nil {
+		i.app.Bus.Emit("notification::new", item)
+	}
+	return item
+}
+
+// List returns matching notifications, newest first.
+func (i *Inbox) List(query InboxQuery) InboxList {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	list := InboxList{Items: []Notification{}, Total: len(i.items)}
+	for idx := len(i.items) - 1; idx >= 0; idx-- {
+		item := i.items[idx]
+		if !item.Read {
+			list.Unread++
+		}
+		if (query.Unread && item.Read) || (query.Source != "" && item.Source != query.Source) {
+			continue
+		}
+		if query.Limit <= 0 || len(list.Items) < query.Limit {
+			list.Items = append(list.Items, item)
+		}
+	}
+	return list
+}
+
+// MarkRead marks the given notifications read, or all of them when ids is empty.
+func (i *Inbox) MarkRead(ids []string) int {
+	slog.Info("Inbox MarkRead", "count", len(ids))
+
+	i.mu.Lock()
+	changed := 0
+	for idx := range i.items {
+		if !i.items[idx].Read && (len(ids) == 0 || slices.Contains(ids, i.items[idx].ID)) {
+			i.items[idx].Read = true
+			changed++
+		}
+	}
+	i.mu.Unlock()
+
+	if changed > 0 {
+		i.save()
+	}
+	return changed
+}
+
+// Clear removes the given notifications, every read one when readOnly is set, or all of
+// them when neither is given.
+func (i *Inbox) Clear(ids []string, readOnly bool) int {
+	slog.Info("Inbox Clear", "count", len(ids), "readOnly", readOnly)
+
+	i.mu.Lock()
+	before := len(i.items)
+	i.items = slices.DeleteFunc(i.items, func(item Notification) bool {
+		if len(ids) > 0 && !slices.Contains(ids, item.ID) {
+			return false
+		}
+		return !readOnly || item.Read
+	})
+	removed := before - len(i.items)
+	i.mu.Unlock()
+
+	if removed > 0 {
+		i.save()
+	}
+	return removed
+}
+
+// observe runs on the emitting goroutine, so it only queues the events the inbox records
+// and leaves the disk write to loop.
+func (i *Inbox) observe(event string, payload []any) {
+	switch event {
+	case "subscription::failed", "ruleset::failed", "core::crashed", "subscription::quota", "subscription::expiring", "failover::switched":
+	default:
+		return
+	}
+	if len(payload) == 0 {
+		return
+	}
+	select {
+	case i.queue <- inboxEvent{event, payload[0]}:
+	default:
+		slog.Warn("Inbox: queue full, dropping", "event", event)
+	}
+}
+
+func (i *Inbox) loop() {
+	defer close(i.done)
+
+	for {
+		select {
+		case <-i.stop:
+			return
+		case event := <-i.queue:
+			i.record(event.name, event.payload)
+		}
+	}
+}
+
+func (i *Inbox) record(event string, payload any) {
+	switch data := payload.(type) {
+	case UpdateFailure:
+		switch event {
+		case "subscription::failed":
+			i.Add("Subscription update failed", data.Name+": "+data.Error, NotificationError, "subscription")
+		case "ruleset::failed":
+			i.Add("Ruleset update failed", data.Name+": "+data.Error, NotificationError, "ruleset")
+		}
+	case CoreCrash:
+		if event == "core::crashed" {
+			message := fmt.Sprintf("PID %d exited unexpectedly", data.PID)
+			if data.Error != "" {
+				message += " (" + data.Error + ")"
+			}
+			if data.Output != "" {
+				message += "\n" + data.Output
+			}
+			i.Add("Core crashed", message, NotificationError, "core")
+		}
+	case QuotaAlert:
+		switch event {
+		case "subscription::quota":
+			message := fmt.Sprintf("%s has used %.0f%% of its traffic (%s left)", data.Name, data.Percent, formatBytes(data.Remaining))
+			i.Add("Subscription quota", message, NotificationWarning, "subscription")
+		case "subscription::expiring":
+			message := fmt.Sprintf("%s expires in %d days (%s)", data.Name, max(data.DaysLeft, 0), time.UnixMilli(data.Expire).Format(time.DateOnly))
+			if data.Expired {
+				message = fmt.Sprintf("%s expired on %s", data.Name, time.UnixMilli(data.Expire).Format(time.DateOnly))
+			}
+			i.Add("Subscription expiring", message, NotificationWarning, "subscription")
+		}
+	case FailoverEvent:
+		if event == "failover::switched" {
+			message := fmt.Sprintf("%s: %s -> %s, %s", data.Group, data.From, data.To, data.Reason)
+			if data.Error != "" {
+				i.Add("Outbound switch failed", message+"\n"+data.Error, NotificationError, "failover")
+			} else {
+				i.Add("Outbound switched", message, NotificationWarning, "failover")
+			}
+		}
+	}
+}
+
+func (i *Inbox) load() {
+	b, err := os.ReadFile(GetPath(InboxFilePath))
+	if err != nil {
+		return
+	}
+	var items []Notification
+	if err := json.Unmarshal(b, &items); err != nil {
+		slog.Error("Inbox load", "error", err)
+		return
+	}
+	i.mu.Lock()
+	i.items = items
+	i.mu.Unlock()
+}
+
+func (i *Inbox) save() {
+	i.saveMu.Lock()
+	defer i.saveMu.Unlock()
+
+	i.mu.Lock()
+	b, err := json.Marshal(i.items)
+	i.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(GetPath(InboxFilePath), b, 0644); err != nil {
+		slog.Error("Inbox save", "error", err)
+	}
+}
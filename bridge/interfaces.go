@@ -0,0 +1,120 @@
+package bridge
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+)
+
+type InterfaceAddress struct {
+	IP     string `json:"ip"`
+	Prefix int    `json:"prefix"`
+	CIDR   string `json:"cidr"`
+}
+
+type InterfaceInfo struct {
+	Index        int                `json:"index"`
+	Name         string             `json:"name"`
+	MTU          int                `json:"mtu"`
+	HardwareAddr string             `json:"hardwareAddr"`
+	Flags        []string           `json:"flags"`
+	Up           bool               `json:"up"`
+	Loopback     bool               `json:"loopback"`
+	Tunnel       bool               `json:"tunnel"`
+	Default      bool               `json:"default"`
+	IPv4         []InterfaceAddress `json:"ipv4"`
+	IPv6         []InterfaceAddress `json:"ipv6"`
+}
+
+type DefaultRoute struct {
+	Interface string `json:"interface"`
+	Gateway   string `json:"gateway"`
+}
+
+type InterfacesResult struct {
+	Interfaces   []InterfaceInfo `json:"interfaces"`
+	DefaultRoute *DefaultRoute   `json:"defaultRoute"`
+}
+
+// Name prefixes used by common tunnel drivers (tun/tap, WireGuard, utun on darwin, VPN clients).
+var tunnelInterfacePrefixes = []string{"tun", "tap", "utun", "wg", "ppp", "ipsec", "gif", "stf", "tailscale", "zt", "singbox", "sing-box", "clash", "mihomo"}
+
+func (a *App) GetInterfaceDetails() (InterfacesResult, error) {
+	slog.Info("GetInterfaceDetails")
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return InterfacesResult{}, err
+	}
+
+	route, err := readDefaultRoute()
+	if err != nil {
+		slog.Warn("readDefaultRoute", "error", err)
+	}
+
+	result := InterfacesResult{
+		Interfaces:   make([]InterfaceInfo, 0, len(interfaces)),
+		DefaultRoute: route,
+	}
+
+	for _, inter := range interfaces {
+		info := InterfaceInfo{
+			Index:        inter.Index,
+			Name:         inter.Name,
+			MTU:          inter.MTU,
+			HardwareAddr: inter.HardwareAddr.String(),
+			Flags:        strings.Split(inter.Flags.String(), "|"),
+			Up:           inter.Flags&net.FlagUp != 0,
+			Loopback:     inter.Flags&net.FlagLoopback != 0,
+			Tunnel:       isTunnelInterface(inter),
+			Default:      route != nil && route.Interface == inter.Name,
+			IPv4:         []InterfaceAddress{},
+			IPv6:         []InterfaceAddress{},
+		}
+		if inter.Flags == 0 {
+			info.Flags = []string{}
+		}
+
+		addrs, err := inter.Addrs()
+		if err != nil {
+			slog.Warn("Addrs", "interface", inter.Name, "error", err)
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			prefix, _ := ipNet.Mask.Size()
+			item := InterfaceAddress{
+				IP:     ipNet.IP.String(),
+				Prefix: prefix,
+				CIDR:   ipNet.String(),
+			}
+			if ipNet.IP.To4() != nil {
+				info.IPv4 = append(info.IPv4, item)
+			} else {
+				info.IPv6 = append(info.IPv6, item)
+			}
+		}
+
+		result.Interfaces = append(result.Interfaces, info)
+	}
+
+	return result, nil
+}
+
+func isTunnelInterface(inter net.Interface) bool {
+	if inter.Flags&net.FlagLoopback != 0 {
+		return false
+	}
+	if inter.Flags&net.FlagPointToPoint != 0 {
+		return true
+	}
+	name := strings.ToLower(inter.Name)
+	for _, prefix := range tunnelInterfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,228 @@
+package bridge
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"strings"
+)
+
+const (
+	KeyTypeReality     = "reality"
+	KeyTypeWireGuard   = "wireguard"
+	KeyTypeUUID        = "uuid"
+	KeyTypeShadowsocks = "shadowsocks"
+	KeyTypePassword    = "password"
+
+	maxGenerateCount      = 100
+	defaultShortIDLength  = 8
+	defaultPasswordLength = 16
+
+	passwordAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// shadowsocks2022KeySizes lists the pre-shared key length in bytes of each 2022 method.
+var shadowsocks2022KeySizes = map[string]int{
+	"2022-blake3-aes-128-gcm":       16,
+	"2022-blake3-aes-256-gcm":       32,
+	"2022-blake3-chacha20-poly1305": 32,
+}
+
+type KeyGenerateOptions struct {
+	Type   string `json:"type"`
+	Count  int    `json:"count"`
+	Method string `json:"method"` // shadowsocks method
+	Length int    `json:"length"` // short ID bytes (reality) or characters (password)
+}
+
+type GeneratedKey struct {
+	PrivateKey   string `json:"privateKey,omitempty"`
+	PublicKey    string `json:"publicKey,omitempty"`
+	ShortID      string `json:"shortId,omitempty"`
+	PreSharedKey string `json:"preSharedKey,omitempty"`
+	UUID         string `json:"uuid,omitempty"`
+	Key          string `json:"key,omitempty"`
+	Password     string `json:"password,omitempty"`
+}
+
+type KeyGenerateResult struct {
+	Type string         `json:"type"`
+	Keys []GeneratedKey `json:"keys"`
+}
+
+// GenerateKeys produces credentials for sing-box inbounds, in the encodings the core expects.
+func (a *App) GenerateKeys(options KeyGenerateOptions) (KeyGenerateResult, error) {
+	slog.Info("GenerateKeys", "type", options.Type, "count", options.Count)
+
+	count := options.Count
+	if count <= 0 {
+		count = 1
+	}
+	if count > maxGenerateCount {
+		return KeyGenerateResult{}, fmt.Errorf("count must not exceed %d", maxGenerateCount)
+	}
+
+	var generate func() (GeneratedKey, error)
+	switch options.Type {
+	case KeyTypeReality:
+		length := options.Length
+		if length <= 0 {
+			length = defaultShortIDLength
+		}
+		if length > 8 {
+			return KeyGenerateResult{}, errors.New("reality short IDs are at most 8 bytes")
+		}
+		generate = func() (GeneratedKey, error) { return generateRealityKey(length) }
+	case KeyTypeWireGuard:
+		generate = generateWireGuardKey
+	case KeyTypeUUID:
+		generate = func() (GeneratedKey, error) {
+			id, err := generateUUID()
+			return GeneratedKey{UUID: id}, err
+		}
+	case KeyTypeShadowsocks:
+		size, ok := shadowsocks2022KeySizes[options.Method]
+		if !ok {
+			if strings.HasPrefix(options.Method, "2022-") || options.Method == "" {
+				return KeyGenerateResult{}, errors.New("unsupported shadowsocks method: " + options.Method)
+			}
+			// Legacy AEAD methods take an arbitrary password
+			generate = func() (GeneratedKey, error) {
+				password, err := generatePassword(options.Length)
+				return GeneratedKey{Key: password}, err
+			}
+			break
+		}
+		generate = func() (GeneratedKey, error) {
+			key, err := randomBytes(size)
+			return GeneratedKey{Key: base64.StdEncoding.EncodeToString(key)}, err
+		}
+	case KeyTypePassword:
+		generate = func() (GeneratedKey, error) {
+			password, err := generatePassword(options.Length)
+			return GeneratedKey{Password: password}, err
+		}
+	default:
+		return KeyGenerateResult{}, errors.New("unknown key type: " + options.Type)
+	}
+
+	result := KeyGenerateResult{Type: options.Type, Keys: make([]GeneratedKey, 0, count)}
+	for range count {
+		key, err := generate()
+		if err != nil {
+			return KeyGenerateResult{}, err
+		}
+		result.Keys = append(result.Keys, key)
+	}
+	return result, nil
+}
+
+// RealityPublicKey derives the public key of a reality private key given in any common encoding.
+func RealityPublicKey(privateKey string) (string, error) {
+	privateKeyBytes, err := decodeRealityPrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+	key, err := ecdh.X25519().NewPrivateKey(privateKeyBytes)
+	if err != nil {
+		return "", err
+	}
+	// sing-box decodes reality keys with the unpadded URL alphabet
+	return base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()), nil
+}
+
+func decodeRealityPrivateKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, errors.New("empty private key")
+	}
+	if decoded, err := decodeBase64String(value); err == nil {
+		return decoded, nil
+	}
+	value = strings.TrimPrefix(value, "0x")
+	if decoded, err := hex.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return nil, errors.New("invalid private key encoding")
+}
+
+func generateRealityKey(shortIDLength int) (GeneratedKey, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return GeneratedKey{}, err
+	}
+	shortID, err := randomBytes(shortIDLength)
+	if err != nil {
+		return GeneratedKey{}, err
+	}
+	return GeneratedKey{
+		PrivateKey: base64.RawURLEncoding.EncodeToString(key.Bytes()),
+		PublicKey:  base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		ShortID:    hex.EncodeToString(shortID),
+	}, nil
+}
+
+// generateWireGuardKey matches `wg genkey`, `wg pubkey` and `wg genpsk`.
+func generateWireGuardKey() (GeneratedKey, error) {
+	private, err := randomBytes(32)
+	if err != nil {
+		return GeneratedKey{}, err
+	}
+	private[0] &= 248
+	private[31] = (private[31] & 127) | 64
+
+	key, err := ecdh.X25519().NewPrivateKey(private)
+	if err != nil {
+		return GeneratedKey{}, err
+	}
+	psk, err := randomBytes(32)
+	if err != nil {
+		return GeneratedKey{}, err
+	}
+	return GeneratedKey{
+		PrivateKey:   base64.StdEncoding.EncodeToString(private),
+		PublicKey:    base64.StdEncoding.EncodeToString(key.PublicKey().Bytes()),
+		PreSharedKey: base64.StdEncoding.EncodeToString(psk),
+	}, nil
+}
+
+func generateUUID() (string, error) {
+	b, err := randomBytes(16)
+	if err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+func generatePassword(length int) (string, error) {
+	if length <= 0 {
+		length = defaultPasswordLength
+	}
+	if length > 256 {
+		return "", errors.New("password length must not exceed 256")
+	}
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(passwordAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordAlphabet[n.Int64()]
+	}
+	return string(password), nil
+}
+
+func randomBytes(size int) ([]byte, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
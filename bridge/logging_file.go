@@ -0,0 +1,207 @@
+package bridge
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultLogQueryLimit = 200
+	maxLogQueryLimit     = 5000
+)
+
+// rotatingFile appends to path and, once a write would take it past maxSize, shifts
+// path.1 … path.N up by one and starts over, keeping at most maxFiles rotated files.
+type rotatingFile struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+	file     *os.File
+	size     int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxFiles int) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return nil, err
+	}
+	r := &rotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	_ = os.Remove(rotatedLogPath(r.path, r.maxFiles))
+	for i := r.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(rotatedLogPath(r.path, i), rotatedLogPath(r.path, i+1))
+	}
+	if err := os.Rename(r.path, rotatedLogPath(r.path, 1)); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func rotatedLogPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+type LogQuery struct {
+	Limit  int    // newest entries returned, 200 when zero
+	Level  string // minimum level
+	Search string // case-insensitive substring of the raw line
+	Since  int64  // unix milliseconds, exclusive
+}
+
+type LogEntry struct {
+	Time    int64          `json:"time"`
+	Level   string         `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+}
+
+// QueryLogs reads the log file and its rotations, oldest first, and returns the newest
+// matching entries in order.
+func (a *App) QueryLogs(query LogQuery) ([]LogEntry, error) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultLogQueryLimit
+	}
+	limit = min(limit, maxLogQueryLimit)
+	minLevel := slog.LevelDebug
+	if query.Level != "" {
+		if err := minLevel.UnmarshalText([]byte(query.Level)); err != nil {
+			return nil, fmt.Errorf("invalid level: %s", query.Level)
+		}
+	}
+	search := strings.ToLower(query.Search)
+
+	path := GetPath(LogsDirectory + "/" + LogFileName)
+	paths := []string{path}
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotatedLogPath(path, i)); err != nil {
+			break
+		}
+		paths = append([]string{rotatedLogPath(path, i)}, paths...)
+	}
+
+	// A ring of the newest limit entries
+	entries := make([]LogEntry, 0, limit)
+	next := 0
+	for _, p := range paths {
+		err := scanLogFile(p, func(line []byte) {
+			if search != "" && !strings.Contains(strings.ToLower(string(line)), search) {
+				return
+			}
+			entry, level, ok := parseLogLine(line)
+			if !ok || level < minLevel || entry.Time <= query.Since {
+				return
+			}
+			if len(entries) < limit {
+				entries = append(entries, entry)
+			} else {
+				entries[next] = entry
+				next = (next + 1) % limit
+			}
+		})
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	return append(entries[next:], entries[:next]...), nil
+}
+
+func scanLogFile(path string, fn func(line []byte)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		fn(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+func parseLogLine(line []byte) (LogEntry, slog.Level, bool) {
+	var fields map[string]any
+	if err := json.Unmarshal(line, &fields); err != nil {
+		return LogEntry{}, 0, false
+	}
+	entry := LogEntry{}
+	if s, ok := fields[slog.TimeKey].(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			entry.Time = t.UnixMilli()
+		}
+	}
+	var level slog.Level
+	if s, ok := fields[slog.LevelKey].(string); ok {
+		_ = level.UnmarshalText([]byte(s))
+		entry.Level = s
+	}
+	entry.Message, _ = fields[slog.MessageKey].(string)
+	delete(fields, slog.TimeKey)
+	delete(fields, slog.LevelKey)
+	delete(fields, slog.MessageKey)
+	if len(fields) > 0 {
+		entry.Attrs = fields
+	}
+	return entry, level, true
+}
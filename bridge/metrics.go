@@ -0,0 +1,111 @@
+package bridge
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"guiforcores/pkg/metrics"
+)
+
+// WriteMetrics writes the core process, traffic, outbound latency and subscription quota
+// families of /metrics.
+func (a *App) WriteMetrics(w *metrics.Writer) {
+	status := a.CoreStatus()
+
+	w.Gauge("guiforcores_core_up", "Whether the core process is running.", boolMetric(status.Running))
+	w.Gauge("guiforcores_core_managed", "Whether the running core was started by the server.", boolMetric(status.Managed))
+	w.Family("guiforcores_core_starts_total", "Core processes started by the server.", "counter")
+	w.Sample("guiforcores_core_starts_total", float64(coreStarts.Load()))
+	w.Family("guiforcores_core_crashes_total", "Managed core processes that exited without being stopped.", "counter")
+	w.Sample("guiforcores_core_crashes_total", float64(coreCrashes.Load()))
+	if status.Running {
+		if proc, err := process.NewProcess(int32(status.PID)); err == nil {
+			if memory, err := proc.MemoryInfo(); err == nil {
+				w.Gauge("guiforcores_core_resident_memory_bytes", "Resident memory of the core process.", float64(memory.RSS))
+			}
+			if times, err := proc.Times(); err == nil {
+				w.Family("guiforcores_core_cpu_seconds_total", "CPU time of the core process.", "counter")
+				w.Sample("guiforcores_core_cpu_seconds_total", times.User+times.System)
+			}
+			if created, err := proc.CreateTime(); err == nil {
+				w.Gauge("guiforcores_core_start_time_seconds", "Start time of the core process since the unix epoch.", float64(created)/1000)
+			}
+		}
+	}
+
+	if a.Stats != nil {
+		totals := a.Stats.Totals()
+		w.Gauge("guiforcores_core_api_connected", "Whether the server follows the core's Clash API.", boolMetric(totals.Recording))
+		w.Family("guiforcores_core_traffic_bytes_total", "Bytes reported by the core's /traffic since the server started.", "counter")
+		w.Sample("guiforcores_core_traffic_bytes_total", float64(totals.Up), "direction", "up")
+		w.Sample("guiforcores_core_traffic_bytes_total", float64(totals.Down), "direction", "down")
+		w.Gauge("guiforcores_core_connections", "Connections open in the core.", float64(totals.Connections))
+		writeTrafficCounters(w, "guiforcores_outbound_traffic_bytes_total", "Connection bytes by first outbound of the chain since the server started.", "outbound", totals.Outbounds)
+		writeTrafficCounters(w, "guiforcores_rule_traffic_bytes_total", "Connection bytes by matched rule since the server started.", "rule", totals.Rules)
+	}
+
+	if a.Prober != nil {
+		outbounds := a.Prober.Status().Outbounds
+		w.Family("guiforcores_outbound_up", "Whether the outbound passed its latency probes.", "gauge")
+		for _, health := range outbounds {
+			w.Sample("guiforcores_outbound_up", boolMetric(health.Up), "outbound", health.Name)
+		}
+		w.Family("guiforcores_outbound_latency_seconds", "Latency of the last successful probe.", "gauge")
+		for _, health := range outbounds {
+			if health.Delay > 0 {
+				w.Sample("guiforcores_outbound_latency_seconds", float64(health.Delay)/1000, "outbound", health.Name)
+			}
+		}
+		w.Family("guiforcores_outbound_probe_success_ratio", "Share of successful probes over the kept history.", "gauge")
+		for _, health := range outbounds {
+			w.Sample("guiforcores_outbound_probe_success_ratio", health.SuccessRate, "outbound", health.Name)
+		}
+	}
+
+	if a.Quota != nil {
+		quotas, err := listQuotas()
+		if err != nil {
+			return
+		}
+		w.Family("guiforcores_subscription_used_bytes", "Traffic used as reported by the provider.", "gauge")
+		for _, quota := range quotas {
+			w.Sample("guiforcores_subscription_used_bytes", float64(quota.Used), "id", quota.ID, "name", quota.Name)
+		}
+		w.Family("guiforcores_subscription_total_bytes", "Traffic quota as reported by the provider.", "gauge")
+		for _, quota := range quotas {
+			if quota.Total > 0 {
+				w.Sample("guiforcores_subscription_total_bytes", float64(quota.Total), "id", quota.ID, "name", quota.Name)
+			}
+		}
+		w.Family("guiforcores_subscription_expire_time_seconds", "Expiry of the subscription since the unix epoch.", "gauge")
+		for _, quota := range quotas {
+			if quota.Expire > 0 {
+				w.Sample("guiforcores_subscription_expire_time_seconds", float64(quota.Expire)/1000, "id", quota.ID, "name", quota.Name)
+			}
+		}
+		w.Family("guiforcores_subscription_update_time_seconds", "Last fetch of the subscription since the unix epoch.", "gauge")
+		for _, quota := range quotas {
+			w.Sample("guiforcores_subscription_update_time_seconds", float64(quota.UpdateTime)/1000, "id", quota.ID, "name", quota.Name)
+		}
+	}
+}
+
+func writeTrafficCounters(w *metrics.Writer, name, help, label string, counters []TrafficCounter) {
+	slices.SortFunc(counters, func(a, b TrafficCounter) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	w.Family(name, help, "counter")
+	for _, counter := range counters {
+		w.Sample(name, float64(counter.Up), label, counter.Name, "direction", "up")
+		w.Sample(name, float64(counter.Down), label, counter.Name, "direction", "down")
+	}
+}
+
+func boolMetric(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
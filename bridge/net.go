@@ -5,7 +5,7 @@ import (
 	"context"
 	"crypto/tls"
 	"io"
-	"log"
+	"log/slog"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -14,7 +14,7 @@ import (
 )
 
 func (a *App) Requests(method string, url string, headers map[string]string, body string, options RequestOptions) HTTPResult {
-	log.Printf("Requests: %v %v %v %v %v", method, url, headers, body, options)
+	slog.Info("Requests", "method", method, "url", redactURL(url), "headers", sortedKeys(headers), "bodySize", len(body), "proxy", redactURL(options.Proxy), "timeout", options.Timeout)
 
 	client, ctx, cancel := withRequestOptionsClient(options)
 
@@ -28,7 +28,7 @@ func (a *App) Requests(method string, url string, headers map[string]string, bod
 	var unsubscribe func()
 	if options.CancelId != "" && a.Bus != nil {
 		unsubscribe = a.Bus.On(options.CancelId, func(_ []any) {
-			log.Printf("Requests canceled: %v %v", method, url)
+			slog.Info("Requests canceled", "method", method, "url", redactURL(url))
 			cancel()
 		})
 	}
@@ -51,7 +51,7 @@ func (a *App) Requests(method string, url string, headers map[string]string, bod
 }
 
 func (a *App) Download(method string, url string, path string, headers map[string]string, event string, options RequestOptions) HTTPResult {
-	log.Printf("Download: %s %s %s %v %s %v", method, url, path, headers, event, options)
+	slog.Info("Download", "method", method, "url", redactURL(url), "path", path, "headers", sortedKeys(headers), "event", event, "proxy", redactURL(options.Proxy))
 
 	client, ctx, cancel := withRequestOptionsClient(options)
 
@@ -65,7 +65,7 @@ func (a *App) Download(method string, url string, path string, headers map[strin
 	var unsubscribe func()
 	if options.CancelId != "" && a.Bus != nil {
 		unsubscribe = a.Bus.On(options.CancelId, func(_ []any) {
-			log.Printf("Download canceled: %v %v", url, path)
+			slog.Info("Download canceled", "url", redactURL(url), "path", path)
 			cancel()
 		})
 	}
@@ -103,7 +103,7 @@ func (a *App) Download(method string, url string, path string, headers map[strin
 }
 
 func (a *App) Upload(method string, url string, path string, headers map[string]string, event string, options RequestOptions) HTTPResult {
-	log.Printf("Upload: %s %s %s %v %s %v", method, url, path, headers, event, options)
+	slog.Info("Upload", "method", method, "url", redactURL(url), "path", path, "headers", sortedKeys(headers), "event", event, "proxy", redactURL(options.Proxy))
 
 	path = GetPath(path)
 
@@ -143,7 +143,7 @@ func (a *App) Upload(method string, url string, path string, headers map[string]
 	var unsubscribe func()
 	if options.CancelId != "" && a.Bus != nil {
 		unsubscribe = a.Bus.On(options.CancelId, func(_ []any) {
-			log.Printf("Upload canceled: %v %v", url, path)
+			slog.Info("Upload canceled", "url", redactURL(url), "path", path)
 			cancel()
 		})
 	}
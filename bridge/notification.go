@@ -2,7 +2,14 @@ package bridge
 
 import "log"
 
-func (a *App) Notify(title string, message string, _ string, _ NotifyOptions) FlagResult {
+func (a *App) Notify(title string, message string, _ string, options NotifyOptions) FlagResult {
 	log.Printf("Notify: %s - %s", title, message)
+	if a.Inbox != nil {
+		a.Inbox.Add(title, message, options.Severity, options.Source)
+	}
+	// Alert rules listening on "notify" forward it to webhook, mail or chat channels
+	if a.Bus != nil {
+		a.Bus.Emit("notify", map[string]string{"title": title, "message": message})
+	}
 	return FlagResult{true, "Success"}
 }
@@ -0,0 +1,448 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ProberSettingsFilePath = "data/prober.yaml"
+	ProberHistoryFilePath  = "data/latency-history.json"
+
+	proberReloadInterval = 30 * time.Second
+	proberRecentSamples  = 30
+)
+
+// clashGroupTypes are the Clash API proxy types whose members are probed when no groups are configured.
+var clashGroupTypes = []string{"Selector", "URLTest", "Fallback", "LoadBalance"}
+
+type ProberSettings struct {
+	Disabled         bool     `yaml:"disabled" json:"disabled"`
+	Interval         int      `yaml:"interval" json:"interval"`                 // seconds between rounds
+	Concurrency      int      `yaml:"concurrency" json:"concurrency"`           // delay tests in flight
+	Timeout          int      `yaml:"timeout" json:"timeout"`                   // milliseconds per test
+	URL              string   `yaml:"url" json:"url"`                           // test target
+	Groups           []string `yaml:"groups" json:"groups"`                     // groups whose members are probed, all when empty
+	FailureThreshold int      `yaml:"failureThreshold" json:"failureThreshold"` // consecutive failures before an outbound is down
+	HistoryLimit     int      `yaml:"historyLimit" json:"historyLimit"`         // samples kept per outbound
+}
+
+type LatencySample struct {
+	Time  int64  `json:"time"`
+	Delay int    `json:"delay"` // milliseconds, 0 when the test failed
+	Error string `json:"error,omitempty"`
+}
+
+type OutboundHealth struct {
+	Name        string          `json:"name"`
+	Groups      []string        `json:"groups"`
+	Up          bool            `json:"up"`
+	Delay       int             `json:"delay"`
+	SuccessRate float64         `json:"successRate"` // over the kept history
+	Failures    int             `json:"failures"`    // consecutive
+	LastCheck   int64           `json:"lastCheck"`
+	LastChange  int64           `json:"lastChange"`
+	History     []LatencySample `json:"history"`
+}
+
+type ProberStatus struct {
+	Settings  ProberSettings   `json:"settings"`
+	Running   bool             `json:"running"`
+	LastRound int64            `json:"lastRound"`
+	Error     string           `json:"error"`
+	Outbounds []OutboundHealth `json:"outbounds"`
+}
+
+// LatencyTransition is emitted as "latency::down" and "latency::up".
+type LatencyTransition struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups"`
+	Delay  int      `json:"delay"`
+	Error  string   `json:"error"`
+}
+
+// LatencyProber periodically delay-tests the members of the core's proxy groups through the
+// Clash API and keeps a short latency history per outbound.
+type LatencyProber struct {
+	app *App
+
+	mu        sync.Mutex
+	settings  ProberSettings
+	outbounds map[string]*OutboundHealth
+	running   bool
+	lastRound int64
+	lastError string
+	roundMu   sync.Mutex
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func NewLatencyProber(a *App) *LatencyProber {
+	return &LatencyProber{
+		app:       a,
+		settings:  loadProberSettings(),
+		outbounds: make(map[string]*OutboundHealth),
+		wake:      make(chan struct{}, 1),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+func (p *LatencyProber) Start() {
+	p.loadHistory()
+	go p.loop()
+}
+
+func (p *LatencyProber) Stop() {
+	p.once.Do(func() {
+		close(p.stop)
+	})
+	<-p.done
+}
+
+// Reload re-reads prober.yaml and starts a round immediately.
+func (p *LatencyProber) Reload() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Status reports every probed outbound with its most recent samples.
+func (p *LatencyProber) Status() ProberStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := ProberStatus{
+		Settings:  p.settings,
+		Running:   p.running,
+		LastRound: p.lastRound,
+		Error:     p.lastError,
+		Outbounds: []OutboundHealth{},
+	}
+	for _, health := range p.outbounds {
+		summary := *health
+		summary.History = slices.Clone(health.History[max(0, len(health.History)-proberRecentSamples):])
+		status.Outbounds = append(status.Outbounds, summary)
+	}
+	slices.SortFunc(status.Outbounds, func(a, b OutboundHealth) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return status
+}
+
+// History returns every kept sample of one outbound.
+func (p *LatencyProber) History(name string) []LatencySample {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	health, ok := p.outbounds[name]
+	if !ok {
+		return []LatencySample{}
+	}
+	return slices.Clone(health.History)
+}
+
+func (p *LatencyProber) loop() {
+	defer close(p.done)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-p.stop
+		cancel()
+	}()
+
+	var next time.Time
+	for {
+		settings := loadProberSettings()
+		p.mu.Lock()
+		p.settings = settings
+		p.mu.Unlock()
+
+		if !settings.Disabled && !time.Now().Before(next) {
+			if _, err := p.Probe(ctx); err != nil {
+				p.setError(err)
+			}
+			next = time.Now().Add(time.Duration(settings.Interval) * time.Second)
+		}
+
+		wait := min(proberReloadInterval, time.Until(next))
+		if settings.Disabled {
+			wait = proberReloadInterval
+		}
+		select {
+		case <-p.stop:
+			return
+		case <-p.wake:
+			next = time.Time{}
+		case <-time.After(max(wait, time.Second)):
+		}
+	}
+}
+
+// Probe runs one round against the running core and returns the updated outbounds.
+func (p *LatencyProber) Probe(ctx context.Context) ([]OutboundHealth, error) {
+	p.roundMu.Lock()
+	defer p.roundMu.Unlock()
+
+	if !p.app.CoreStatus().Running {
+		return nil, errors.New("the core is not running")
+	}
+	api, err := ActiveClashAPI()
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	settings := p.settings
+	p.running = true
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running = false
+		p.mu.Unlock()
+	}()
+
+	targets, err := proberTargets(ctx, api, settings.Groups)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		name   string
+		sample LatencySample
+	}
+	results := make(chan result, len(targets))
+	sem := make(chan struct{}, settings.Concurrency)
+	var wg sync.WaitGroup
+	for name := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results <- result{name, testDelay(ctx, api, name, settings)}
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	type transition struct {
+		event string
+		data  LatencyTransition
+	}
+	var transitions []transition
+	p.mu.Lock()
+	for r := range results {
+		health, ok := p.outbounds[r.name]
+		if !ok {
+			health = &OutboundHealth{Name: r.name, Up: true}
+			p.outbounds[r.name] = health
+		}
+		health.Groups = targets[r.name]
+		health.LastCheck = r.sample.Time
+		health.Delay = r.sample.Delay
+		health.History = append(health.History, r.sample)
+		if len(health.History) > settings.HistoryLimit {
+			health.History = slices.Clone(health.History[len(health.History)-settings.HistoryLimit:])
+		}
+		succeeded := 0
+		for _, sample := range health.History {
+			if sample.Delay > 0 {
+				succeeded++
+			}
+		}
+		health.SuccessRate = float64(succeeded) / float64(len(health.History))
+
+		event := ""
+		if r.sample.Delay > 0 {
+			health.Failures = 0
+			if !health.Up {
+				event = "latency::up"
+			}
+		} else {
+			health.Failures++
+			if health.Up && health.Failures >= settings.FailureThreshold {
+				event = "latency::down"
+			}
+		}
+		if event != "" {
+			health.Up = event == "latency::up"
+			health.LastChange = r.sample.Time
+			transitions = append(transitions, transition{event, LatencyTransition{
+				Name:   r.name,
+				Groups: health.Groups,
+				Delay:  r.sample.Delay,
+				Error:  r.sample.Error,
+			}})
+		}
+	}
+	lastRound := time.Now().UnixMilli()
+	p.lastRound = lastRound
+	p.lastError = ""
+	updated := make([]OutboundHealth, 0, len(targets))
+	for name := range targets {
+		updated = append(updated, *p.outbounds[name])
+	}
+	p.mu.Unlock()
+
+	p.saveHistory()
+	if p.app.Bus != nil {
+		for _, t := range transitions {
+			p.app.Bus.Emit(t.event, t.data)
+		}
+		p.app.Bus.Emit("latency::updated", lastRound)
+	}
+	if p.app.Failover != nil {
+		p.app.Failover.evaluate(ctx, api, p.snapshot())
+	}
+	return updated, nil
+}
+
+// snapshot copies the health record of every outbound.
+func (p *LatencyProber) snapshot() map[string]OutboundHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	health := make(map[string]OutboundHealth, len(p.outbounds))
+	for name, h := range p.outbounds {
+		record := *h
+		record.History = slices.Clone(h.History)
+		health[name] = record
+	}
+	return health
+}
+
+// proberTargets maps every outbound to probe to the groups it belongs to.
+func proberTargets(ctx context.Context, api ClashAPI, groups []string) (map[string][]string, error) {
+	var body struct {
+		Proxies map[string]struct {
+			Type string   `json:"type"`
+			All  []string `json:"all"`
+		} `json:"proxies"`
+	}
+	if err := clashAPIRequest(ctx, api, "GET", "/proxies", nil, &body); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string][]string)
+	for name, proxy := range body.Proxies {
+		if len(groups) > 0 && !slices.Contains(groups, name) {
+			continue
+		}
+		if len(groups) == 0 && !slices.Contains(clashGroupTypes, proxy.Type) {
+			continue
+		}
+		for _, member := range proxy.All {
+			// Nested groups are probed through their own members
+			if m, ok := body.Proxies[member]; ok && slices.Contains(clashGroupTypes, m.Type) {
+				continue
+			}
+			targets[member] = append(targets[member], name)
+		}
+	}
+	for name := range targets {
+		slices.Sort(targets[name])
+	}
+	return targets, nil
+}
+
+func testDelay(ctx context.Context, api ClashAPI, name string, settings ProberSettings) LatencySample {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(settings.Timeout)*time.Millisecond+5*time.Second)
+	defer cancel()
+
+	query := url.Values{}
+	query.Set("url", settings.URL)
+	query.Set("timeout", strconv.Itoa(settings.Timeout))
+	var body struct {
+		Delay int `json:"delay"`
+	}
+	sample := LatencySample{Time: time.Now().UnixMilli()}
+	err := clashAPIRequest(ctx, api, "GET", "/proxies/"+url.PathEscape(name)+"/delay?"+query.Encode(), nil, &body)
+	if err != nil {
+		sample.Error = err.Error()
+		return sample
+	}
+	// A delay of zero means the test failed without an error body
+	sample.Delay = body.Delay
+	if sample.Delay <= 0 {
+		sample.Error = "no response"
+	}
+	return sample
+}
+
+func (p *LatencyProber) setError(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.lastError = err.Error()
+}
+
+func (p *LatencyProber) loadHistory() {
+	b, err := os.ReadFile(GetPath(ProberHistoryFilePath))
+	if err != nil {
+		return
+	}
+	outbounds := make(map[string]*OutboundHealth)
+	if err := json.Unmarshal(b, &outbounds); err != nil {
+		slog.Error("LatencyProber loadHistory", "error", err)
+		return
+	}
+	p.mu.Lock()
+	p.outbounds = outbounds
+	p.mu.Unlock()
+}
+
+func (p *LatencyProber) saveHistory() {
+	p.mu.Lock()
+	b, err := json.Marshal(p.outbounds)
+	p.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(GetPath(ProberHistoryFilePath), b, 0644); err != nil {
+		slog.Error("LatencyProber saveHistory", "error", err)
+	}
+}
+
+func loadProberSettings() ProberSettings {
+	settings := ProberSettings{}
+	b, err := os.ReadFile(GetPath(ProberSettingsFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	if settings.Interval <= 0 {
+		settings.Interval = 300
+	}
+	if settings.Concurrency <= 0 {
+		settings.Concurrency = 4
+	}
+	if settings.Timeout <= 0 {
+		settings.Timeout = 5000
+	}
+	if settings.URL == "" {
+		settings.URL = "https://www.gstatic.com/generate_204"
+	}
+	if settings.FailureThreshold <= 0 {
+		settings.FailureThreshold = 2
+	}
+	if settings.HistoryLimit <= 0 {
+		settings.HistoryLimit = 288
+	}
+	return settings
+}
@@ -0,0 +1,110 @@
+package bridge
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	QRCodeFormatPNG = "png"
+	QRCodeFormatSVG = "svg"
+
+	defaultQRCodeSize = 256
+	maxQRCodeSize     = 2048
+)
+
+type QRCodeOptions struct {
+	Format string `json:"format"` // png / svg
+	Size   int    `json:"size"`   // edge length in pixels
+}
+
+type ShareQRCode struct {
+	Tag   string `json:"tag"`
+	Link  string `json:"link"`
+	Image string `json:"image"` // data URL
+	Error string `json:"error"`
+}
+
+// RenderQRCode encodes content as a QR code image and returns it with its content type.
+func RenderQRCode(content string, options QRCodeOptions) ([]byte, string, error) {
+	if content == "" {
+		return nil, "", errors.New("empty content")
+	}
+	size := options.Size
+	if size <= 0 {
+		size = defaultQRCodeSize
+	}
+	if size > maxQRCodeSize {
+		return nil, "", fmt.Errorf("size must not exceed %d", maxQRCodeSize)
+	}
+
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch options.Format {
+	case "", QRCodeFormatPNG:
+		b, err := qr.PNG(size)
+		return b, "image/png", err
+	case QRCodeFormatSVG:
+		return renderQRCodeSVG(qr.Bitmap(), size), "image/svg+xml", nil
+	}
+	return nil, "", errors.New("unknown qrcode format: " + options.Format)
+}
+
+// ShareQRCodes renders one QR code per outbound of a share selection, for scanning the
+// nodes into a phone one by one.
+func (a *App) ShareQRCodes(selection ShareSelection, options QRCodeOptions) ([]ShareQRCode, error) {
+	slog.Info("ShareQRCodes", "profile", selection.Profile, "subscriptions", selection.Subscriptions)
+
+	outbounds, err := resolveShareOutbounds(selection)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := make([]ShareQRCode, 0, len(outbounds))
+	for _, outbound := range outbounds {
+		code := ShareQRCode{Tag: anyToString(outbound["tag"])}
+		link, err := ExportShareLink(outbound)
+		if err == nil {
+			var image []byte
+			var contentType string
+			image, contentType, err = RenderQRCode(link, options)
+			if err == nil {
+				code.Link = link
+				code.Image = "data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(image)
+			}
+		}
+		if err != nil {
+			code.Error = err.Error()
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+// renderQRCodeSVG draws the dark modules as a single path scaled to size pixels.
+func renderQRCodeSVG(bitmap [][]bool, size int) []byte {
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	n := len(bitmap)
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="#fff"/>`, n, n)
+	fmt.Fprintf(&b, `<path fill="#000" d="%s"/>`, path.String())
+	b.WriteString(`</svg>`)
+	return []byte(b.String())
+}
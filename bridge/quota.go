@@ -0,0 +1,354 @@
+package bridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	QuotaSettingsFilePath = "data/quota.yaml"
+	QuotaHistoryFilePath  = "data/subscription-quota.json"
+
+	quotaCheckInterval = time.Minute
+)
+
+type QuotaSettings struct {
+	UsagePercent []int `yaml:"usagePercent" json:"usagePercent"` // notify when used traffic reaches each percentage
+	ExpiryDays   []int `yaml:"expiryDays" json:"expiryDays"`     // notify when this many days are left
+	HistoryLimit int   `yaml:"historyLimit" json:"historyLimit"` // samples kept per subscription
+}
+
+// QuotaSample is the subscription-userinfo of one fetch.
+type QuotaSample struct {
+	Time     int64 `json:"time"`
+	Upload   int64 `json:"upload"`
+	Download int64 `json:"download"`
+	Total    int64 `json:"total"`
+	Expire   int64 `json:"expire"`
+}
+
+type SubscriptionQuota struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Upload     int64   `json:"upload"`
+	Download   int64   `json:"download"`
+	Total      int64   `json:"total"` // zero when the provider reports no quota
+	Used       int64   `json:"used"`
+	Remaining  int64   `json:"remaining"`
+	Percent    float64 `json:"percent"`
+	Expire     int64   `json:"expire"`   // zero when the provider reports no expiry
+	DaysLeft   int     `json:"daysLeft"` // negative once expired
+	Expired    bool    `json:"expired"`
+	UpdateTime int64   `json:"updateTime"`
+}
+
+// QuotaAlert is emitted as "subscription::quota" and "subscription::expiring" when a
+// subscription crosses one of the configured thresholds.
+type QuotaAlert struct {
+	SubscriptionQuota
+	Threshold int `json:"threshold"` // percent or days
+}
+
+type quotaState struct {
+	Samples []QuotaSample `json:"samples"`
+	// Thresholds already notified, so each is raised once per billing period
+	UsageNotified  int `json:"usageNotified"`
+	ExpiryNotified int `json:"expiryNotified"`
+}
+
+// QuotaTracker samples the traffic and expiry that providers report for each subscription,
+// whether the server or the panel fetched it, and raises notifications at thresholds.
+type QuotaTracker struct {
+	app *App
+
+	mu    sync.Mutex
+	state map[string]*quotaState
+
+	trigger chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+
+	unobserve func()
+}
+
+func NewQuotaTracker(a *App) *QuotaTracker {
+	q := &QuotaTracker{
+		app:     a,
+		state:   make(map[string]*quotaState),
+		trigger: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	q.load()
+	return q
+}
+
+func (q *QuotaTracker) Start() {
+	if q.app.Bus != nil {
+		q.unobserve = q.app.Bus.Observe(func(event string, _ []any) {
+			if event == "subscription::updated" {
+				select {
+				case q.trigger <- struct{}{}:
+				default:
+				}
+			}
+		})
+	}
+	go q.loop()
+}
+
+func (q *QuotaTracker) Stop() {
+	q.once.Do(func() {
+		if q.unobserve != nil {
+			q.unobserve()
+		}
+		close(q.stop)
+	})
+	<-q.done
+}
+
+func (q *QuotaTracker) loop() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(quotaCheckInterval)
+	defer ticker.Stop()
+
+	q.check()
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-q.trigger:
+			q.check()
+		case <-ticker.C:
+			// Subscriptions updated by the panel only show up in subscribes.yaml
+			q.check()
+		}
+	}
+}
+
+// List reports the current quota and expiry of every subscription that has either.
+func (q *QuotaTracker) List() ([]SubscriptionQuota, error) {
+	slog.Info("QuotaTracker List")
+
+	return listQuotas()
+}
+
+func listQuotas() ([]SubscriptionQuota, error) {
+	subs, err := readSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	quotas := []SubscriptionQuota{}
+	for _, sub := range subs {
+		if sub.Total > 0 || sub.Expire > 0 {
+			quotas = append(quotas, subscriptionQuota(sub, time.Now()))
+		}
+	}
+	return quotas, nil
+}
+
+// History returns the recorded samples of one subscription, oldest first.
+func (q *QuotaTracker) History(id string) ([]QuotaSample, error) {
+	slog.Info("QuotaTracker History", "id", id)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	state, ok := q.state[id]
+	if !ok {
+		return nil, errors.New("no quota history for subscription: " + id)
+	}
+	return slices.Clone(state.Samples), nil
+}
+
+func (q *QuotaTracker) check() {
+	subs, err := readSubscriptions()
+	if err != nil {
+		slog.Error("QuotaTracker check", "error", err)
+		return
+	}
+	settings := loadQuotaSettings()
+	now := time.Now()
+
+	type quotaEvent struct {
+		event string
+		data  QuotaAlert
+	}
+	var alerts []quotaEvent
+	changed := false
+
+	q.mu.Lock()
+	seen := make(map[string]bool, len(subs))
+	for _, sub := range subs {
+		seen[sub.ID] = true
+		if sub.UpdateTime == 0 || (sub.Total == 0 && sub.Expire == 0) {
+			continue
+		}
+		state, ok := q.state[sub.ID]
+		if !ok {
+			state = &quotaState{}
+			q.state[sub.ID] = state
+		}
+		if n := len(state.Samples); n == 0 || state.Samples[n-1].Time != sub.UpdateTime {
+			state.Samples = append(state.Samples, QuotaSample{
+				Time:     sub.UpdateTime,
+				Upload:   sub.Upload,
+				Download: sub.Download,
+				Total:    sub.Total,
+				Expire:   sub.Expire,
+			})
+			if overflow := len(state.Samples) - settings.HistoryLimit; overflow > 0 {
+				state.Samples = slices.Clone(state.Samples[overflow:])
+			}
+			changed = true
+		}
+
+		quota := subscriptionQuota(sub, now)
+
+		// The highest usage threshold reached; a lower one means a new billing period
+		level := 0
+		if quota.Total > 0 {
+			for _, percent := range settings.UsagePercent {
+				if quota.Percent >= float64(percent) && percent > level {
+					level = percent
+				}
+			}
+		}
+		if level > state.UsageNotified {
+			alerts = append(alerts, quotaEvent{"subscription::quota", QuotaAlert{quota, level}})
+		}
+		if level != state.UsageNotified {
+			state.UsageNotified = level
+			changed = true
+		}
+
+		// The lowest expiry threshold reached; a higher one means the subscription was renewed
+		level = 0
+		if quota.Expire > 0 {
+			left := time.UnixMilli(quota.Expire).Sub(now).Hours() / 24
+			for _, days := range settings.ExpiryDays {
+				if left <= float64(days) && (level == 0 || days < level) {
+					level = days
+				}
+			}
+		}
+		if level > 0 && (state.ExpiryNotified == 0 || level < state.ExpiryNotified) {
+			alerts = append(alerts, quotaEvent{"subscription::expiring", QuotaAlert{quota, level}})
+		}
+		if level != state.ExpiryNotified {
+			state.ExpiryNotified = level
+			changed = true
+		}
+	}
+	for id := range q.state {
+		if !seen[id] {
+			delete(q.state, id)
+			changed = true
+		}
+	}
+	q.mu.Unlock()
+
+	if changed {
+		q.save()
+	}
+	if q.app.Bus != nil {
+		for _, alert := range alerts {
+			q.app.Bus.Emit(alert.event, alert.data)
+		}
+	}
+}
+
+func subscriptionQuota(sub Subscription, now time.Time) SubscriptionQuota {
+	quota := SubscriptionQuota{
+		ID:         sub.ID,
+		Name:       sub.Name,
+		Upload:     sub.Upload,
+		Download:   sub.Download,
+		Total:      sub.Total,
+		Used:       sub.Upload + sub.Download,
+		Expire:     sub.Expire,
+		UpdateTime: sub.UpdateTime,
+	}
+	if quota.Total > 0 {
+		quota.Remaining = max(quota.Total-quota.Used, 0)
+		quota.Percent = math.Round(float64(quota.Used)*10000/float64(quota.Total)) / 100
+	}
+	if quota.Expire > 0 {
+		left := time.UnixMilli(quota.Expire).Sub(now)
+		quota.DaysLeft = int(math.Floor(left.Hours() / 24))
+		quota.Expired = left <= 0
+	}
+	return quota
+}
+
+func loadQuotaSettings() QuotaSettings {
+	settings := QuotaSettings{}
+	b, err := os.ReadFile(GetPath(QuotaSettingsFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	if settings.UsagePercent == nil {
+		settings.UsagePercent = []int{80, 95}
+	}
+	if settings.ExpiryDays == nil {
+		settings.ExpiryDays = []int{3, 1}
+	}
+	settings.UsagePercent = slices.DeleteFunc(settings.UsagePercent, func(v int) bool { return v <= 0 })
+	settings.ExpiryDays = slices.DeleteFunc(settings.ExpiryDays, func(v int) bool { return v <= 0 })
+	if settings.HistoryLimit <= 0 {
+		settings.HistoryLimit = 365
+	}
+	return settings
+}
+
+func (q *QuotaTracker) load() {
+	b, err := os.ReadFile(GetPath(QuotaHistoryFilePath))
+	if err != nil {
+		return
+	}
+	state := make(map[string]*quotaState)
+	if err := json.Unmarshal(b, &state); err != nil {
+		slog.Error("QuotaTracker load", "error", err)
+		return
+	}
+	q.mu.Lock()
+	q.state = state
+	q.mu.Unlock()
+}
+
+func (q *QuotaTracker) save() {
+	q.mu.Lock()
+	b, err := json.Marshal(q.state)
+	q.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(GetPath(QuotaHistoryFilePath), b, 0644); err != nil {
+		slog.Error("QuotaTracker save", "error", err)
+	}
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
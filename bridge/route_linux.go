@@ -0,0 +1,68 @@
+//go:build linux
+
+package bridge
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const rtfUp = 0x1
+
+// readDefaultRoute picks the IPv4 default route with the lowest metric from /proc/net/route.
+func readDefaultRoute() (*DefaultRoute, error) {
+	file, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var (
+		route      *DefaultRoute
+		bestMetric = -1
+	)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags RefCnt Use Metric Mask MTU Window IRTT
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 8 {
+			continue
+		}
+		if fields[1] != "00000000" || fields[7] != "00000000" {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&rtfUp == 0 {
+			continue
+		}
+		metric, _ := strconv.Atoi(fields[6])
+		if bestMetric != -1 && metric >= bestMetric {
+			continue
+		}
+		bestMetric = metric
+		route = &DefaultRoute{
+			Interface: fields[0],
+			Gateway:   parseProcRouteIP(fields[2]),
+		}
+	}
+
+	return route, scanner.Err()
+}
+
+// parseProcRouteIP decodes the little-endian hex address used by /proc/net/route.
+func parseProcRouteIP(value string) string {
+	raw, err := hex.DecodeString(value)
+	if err != nil || len(raw) != 4 {
+		return ""
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(raw))
+	return ip.String()
+}
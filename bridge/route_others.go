@@ -0,0 +1,7 @@
+//go:build !linux
+
+package bridge
+
+func readDefaultRoute() (*DefaultRoute, error) {
+	return nil, nil
+}
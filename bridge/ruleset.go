@@ -0,0 +1,397 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RulesetsFilePath     = "data/rulesets.yaml"
+	RulesetCacheFilePath = "data/.cache/rulesets-cache.json"
+
+	RulesetFormatSource = "source"
+	RulesetFormatBinary = "binary"
+
+	maxRulesetSize         = 64 << 20
+	rulesetDownloadTimeout = 5 * time.Minute // covers reading a body of maxRulesetSize
+	rulesetCompileTimeout  = time.Minute
+)
+
+var (
+	rulesetsFileMu sync.Mutex
+	rulesetCacheMu sync.Mutex
+
+	rulesetUpdatingMu sync.Mutex
+	rulesetUpdating   = make(map[string]bool)
+
+	// srsMagic starts every binary rule-set written by `rule-set compile`
+	srsMagic = []byte("SRS")
+)
+
+// Ruleset mirrors an entry of data/rulesets.yaml.
+type Ruleset struct {
+	ID         string `yaml:"id" json:"id"`
+	Tag        string `yaml:"tag" json:"tag"`
+	UpdateTime int64  `yaml:"updateTime" json:"updateTime"`
+	Disabled   bool   `yaml:"disabled" json:"disabled"`
+	Type       string `yaml:"type" json:"type"` // Http / File / Manual
+	Format     string `yaml:"format" json:"format"`
+	Path       string `yaml:"path" json:"path"`
+	URL        string `yaml:"url" json:"url"`
+	Count      int    `yaml:"count" json:"count"`
+}
+
+type RulesetUpdateResult struct {
+	ID           string `json:"id"`
+	Tag          string `json:"tag"`
+	Format       string `json:"format"`
+	Count        int    `json:"count"`
+	NotModified  bool   `json:"notModified"`
+	Compiled     string `json:"compiled"` // path of the .srs built from a source rule-set
+	CompileError string `json:"compileError"`
+	UpdateTime   int64  `json:"updateTime"`
+}
+
+// rulesetCacheEntry keeps the validators of the last download of a remote rule-set.
+type rulesetCacheEntry struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag"`
+	LastModified string `json:"lastModified"`
+}
+
+func (a *App) ListRulesets() ([]Ruleset, error) {
+	slog.Info("ListRulesets")
+
+	return readRulesets()
+}
+
+// UpdateRuleset downloads, copies or re-reads one rule-set, validates it and, for source
+// rule-sets, compiles a binary .srs next to it with the installed core.
+func (a *App) UpdateRuleset(id string) (RulesetUpdateResult, error) {
+	slog.Info("UpdateRuleset", "id", id)
+
+	rulesets, err := readRulesets()
+	if err != nil {
+		return RulesetUpdateResult{}, err
+	}
+	var ruleset *Ruleset
+	for i := range rulesets {
+		if rulesets[i].ID == id {
+			ruleset = &rulesets[i]
+			break
+		}
+	}
+	if ruleset == nil {
+		return RulesetUpdateResult{}, errors.New(id + " Not Found")
+	}
+	if ruleset.Disabled {
+		return RulesetUpdateResult{}, errors.New(ruleset.Tag + " Disabled")
+	}
+
+	rulesetUpdatingMu.Lock()
+	if rulesetUpdating[id] {
+		rulesetUpdatingMu.Unlock()
+		return RulesetUpdateResult{}, errors.New(ruleset.Tag + " is already updating")
+	}
+	rulesetUpdating[id] = true
+	rulesetUpdatingMu.Unlock()
+	defer func() {
+		rulesetUpdatingMu.Lock()
+		delete(rulesetUpdating, id)
+		rulesetUpdatingMu.Unlock()
+	}()
+
+	result, err := a.updateRuleset(ruleset)
+	if err != nil {
+		if a.Bus != nil {
+			a.Bus.Emit("ruleset::failed", UpdateFailure{ID: ruleset.ID, Name: ruleset.Tag, Error: err.Error()})
+		}
+		return result, err
+	}
+
+	if a.Bus != nil {
+		a.Bus.Emit("ruleset::updated", result)
+	}
+
+	return result, nil
+}
+
+// CompileRuleset runs `rule-set compile` of the installed core on a source rule-set and
+// returns the path of the .srs written next to it.
+func (a *App) CompileRuleset(path string) (string, error) {
+	slog.Info("CompileRuleset", "path", path)
+
+	corePath := CoreBinaryPath()
+	if _, err := os.Stat(corePath); err != nil {
+		return "", errors.New("core binary not found: " + corePath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rulesetCompileTimeout)
+	defer cancel()
+
+	output := strings.TrimSuffix(path, filepath.Ext(path)) + ".srs"
+	out, err := a.execContext(ctx, corePath, []string{"rule-set", "compile", "--disable-color", "--output", GetPath(output), GetPath(path)}, ExecOptions{})
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", errors.New("rule-set compile timed out")
+	}
+	if err != nil {
+		message := strings.TrimSpace(ansiEscapePattern.ReplaceAllString(string(out), ""))
+		if message == "" {
+			message = err.Error()
+		}
+		return "", errors.New(message)
+	}
+	return output, nil
+}
+
+func (a *App) updateRuleset(ruleset *Ruleset) (RulesetUpdateResult, error) {
+	result := RulesetUpdateResult{
+		ID:     ruleset.ID,
+		Tag:    ruleset.Tag,
+		Format: ruleset.Format,
+		Count:  ruleset.Count,
+	}
+
+	var body []byte
+	var validators rulesetCacheEntry
+	var err error
+
+	switch ruleset.Type {
+	case "Http":
+		body, validators, err = fetchRuleset(ruleset)
+		if errors.Is(err, errRulesetNotModified) {
+			result.NotModified = true
+			err = nil
+		}
+	case "File":
+		body, err = os.ReadFile(GetPath(ruleset.URL))
+	case "Manual":
+		body, err = os.ReadFile(GetPath(ruleset.Path))
+		if os.IsNotExist(err) && ruleset.Format == RulesetFormatSource {
+			body, err = []byte("{\n  \"version\": 1,\n  \"rules\": []\n}"), nil
+		}
+	default:
+		err = errors.New("unknown ruleset type: " + ruleset.Type)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	if !result.NotModified {
+		switch ruleset.Format {
+		case RulesetFormatSource:
+			if result.Count, err = countRulesetRules(body); err != nil {
+				return result, err
+			}
+		case RulesetFormatBinary:
+			if !bytes.HasPrefix(body, srsMagic) {
+				return result, errors.New("Not a valid binary ruleset")
+			}
+		default:
+			return result, errors.New("unknown ruleset format: " + ruleset.Format)
+		}
+
+		if _, err := os.Stat(GetPath(ruleset.Path)); ruleset.Type != "Manual" || err != nil {
+			if err := WriteFileAtomic(GetPath(ruleset.Path), body, 0644); err != nil {
+				return result, err
+			}
+		}
+		if ruleset.Type == "Http" {
+			writeRulesetCache(ruleset.ID, validators)
+		}
+	}
+
+	if ruleset.Format == RulesetFormatSource {
+		compiled, err := a.CompileRuleset(ruleset.Path)
+		if err != nil {
+			result.CompileError = err.Error()
+		} else {
+			result.Compiled = compiled
+		}
+	}
+
+	result.UpdateTime = time.Now().UnixMilli()
+	if err := updateRulesetEntry(ruleset.ID, result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+var errRulesetNotModified = errors.New("ruleset not modified")
+
+// fetchRuleset downloads a remote rule-set, sending the validators of the previous download
+// so an unchanged file costs a 304 instead of a full transfer.
+func fetchRuleset(ruleset *Ruleset) ([]byte, rulesetCacheEntry, error) {
+	client, ctx, cancel := withRequestOptionsClient(RequestOptions{
+		Proxy:    loadDownloadProxy(),
+		Redirect: true,
+		Timeout:  int(rulesetDownloadTimeout / time.Second),
+	})
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ruleset.URL, nil)
+	if err != nil {
+		return nil, rulesetCacheEntry{}, err
+	}
+
+	rulesetCacheMu.Lock()
+	entry, cached := loadRulesetCache()[ruleset.ID]
+	rulesetCacheMu.Unlock()
+	if _, err := os.Stat(GetPath(ruleset.Path)); cached && entry.URL == ruleset.URL && err == nil {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, rulesetCacheEntry{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, rulesetCacheEntry{}, errRulesetNotModified
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, rulesetCacheEntry{}, errors.New("ruleset server responded " + resp.Status)
+	}
+
+	body, err := readAllLimited(resp.Body, maxRulesetSize)
+	if err != nil {
+		return nil, rulesetCacheEntry{}, err
+	}
+
+	return body, rulesetCacheEntry{
+		URL:          ruleset.URL,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
+
+// countRulesetRules validates a source rule-set and counts its items the way the panel
+// does: every list value counts its length, every other value counts one.
+func countRulesetRules(body []byte) (int, error) {
+	var ruleset struct {
+		Version int              `json:"version"`
+		Rules   []map[string]any `json:"rules"`
+	}
+	if err := json.Unmarshal(body, &ruleset); err != nil || ruleset.Rules == nil {
+		return 0, errors.New("Not a valid ruleset data")
+	}
+
+	count := 0
+	for _, rule := range ruleset.Rules {
+		for _, value := range rule {
+			if list, ok := value.([]any); ok {
+				count += len(list)
+			} else {
+				count++
+			}
+		}
+	}
+	return count, nil
+}
+
+func readRulesets() ([]Ruleset, error) {
+	b, err := os.ReadFile(GetPath(RulesetsFilePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Ruleset{}, nil
+		}
+		return nil, err
+	}
+	rulesets := []Ruleset{}
+	if err := yaml.Unmarshal(b, &rulesets); err != nil {
+		return nil, err
+	}
+	return rulesets, nil
+}
+
+func updateRulesetEntry(id string, result RulesetUpdateResult) error {
+	rulesetsFileMu.Lock()
+	defer rulesetsFileMu.Unlock()
+
+	path := GetPath(RulesetsFilePath)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return errors.New("unexpected rulesets format")
+	}
+
+	for _, item := range doc.Content[0].Content {
+		if item.Kind != yaml.MappingNode || yamlMappingValue(item, "id") != id {
+			continue
+		}
+		setYAMLMappingNode(item, "updateTime", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(result.UpdateTime, 10)})
+		setYAMLMappingNode(item, "count", &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(result.Count)})
+		return writeYAMLNode(path, &doc)
+	}
+
+	return errors.New(id + " Not Found")
+}
+
+func loadRulesetCache() map[string]rulesetCacheEntry {
+	cache := map[string]rulesetCacheEntry{}
+	if b, err := os.ReadFile(GetPath(RulesetCacheFilePath)); err == nil {
+		_ = json.Unmarshal(b, &cache)
+	}
+	return cache
+}
+
+func writeRulesetCache(id string, entry rulesetCacheEntry) {
+	rulesetCacheMu.Lock()
+	defer rulesetCacheMu.Unlock()
+
+	cache := loadRulesetCache()
+	cache[id] = entry
+	b, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(GetPath(RulesetCacheFilePath), b, 0644); err != nil {
+		slog.Error("writeRulesetCache", "error", err)
+	}
+}
+
+func (a *App) rulesetTaskRunner(_ context.Context, task ScheduledTask) ([]string, error) {
+	output := []string{}
+	var errs []error
+	for _, id := range task.Rulesets {
+		result, err := a.UpdateRuleset(id)
+		if err != nil {
+			output = append(output, err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		line := "Ruleset [" + result.Tag + "] updated successfully."
+		if result.CompileError != "" {
+			line += " Compile failed: " + result.CompileError
+			errs = append(errs, errors.New(result.Tag+": "+result.CompileError))
+		}
+		output = append(output, line)
+	}
+	return output, errors.Join(errs...)
+}
@@ -0,0 +1,741 @@
+package bridge
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const RulesetsDirectory = "data/rulesets"
+
+// Item types of the binary rule-set format, in the order sing-box numbers them.
+const (
+	srsItemQueryType = iota
+	srsItemNetwork
+	srsItemDomain
+	srsItemDomainKeyword
+	srsItemDomainRegex
+	srsItemSourceIPCIDR
+	srsItemIPCIDR
+	srsItemSourcePort
+	srsItemSourcePortRange
+	srsItemPort
+	srsItemPortRange
+	srsItemProcessName
+	srsItemProcessPath
+	srsItemPackageName
+	srsItemWIFISSID
+	srsItemWIFIBSSID
+	srsItemAdGuardDomain
+	srsItemProcessPathRegex
+	srsItemNetworkType
+	srsItemNetworkIsExpensive
+	srsItemNetworkIsConstrained
+	srsItemFinal = 0xFF
+)
+
+var srsItemNames = map[byte]string{
+	srsItemQueryType:            "query_type",
+	srsItemNetwork:              "network",
+	srsItemSourceIPCIDR:         "source_ip_cidr",
+	srsItemSourcePort:           "source_port",
+	srsItemSourcePortRange:      "source_port_range",
+	srsItemPort:                 "port",
+	srsItemPortRange:            "port_range",
+	srsItemProcessName:          "process_name",
+	srsItemProcessPath:          "process_path",
+	srsItemPackageName:          "package_name",
+	srsItemWIFISSID:             "wifi_ssid",
+	srsItemWIFIBSSID:            "wifi_bssid",
+	srsItemAdGuardDomain:        "adguard_domain",
+	srsItemProcessPathRegex:     "process_path_regex",
+	srsItemNetworkType:          "network_type",
+	srsItemNetworkIsExpensive:   "network_is_expensive",
+	srsItemNetworkIsConstrained: "network_is_constrained",
+}
+
+var (
+	rulesetMatchCacheMu sync.Mutex
+	rulesetMatchCache   = make(map[string]*loadedRuleset)
+)
+
+type RulesetMatchOptions struct {
+	Value string   `json:"value"` // domain or IP address
+	Tags  []string `json:"tags"`  // limit the lookup to these rule-sets
+}
+
+type RulesetMatch struct {
+	Tag        string   `json:"tag"`
+	Path       string   `json:"path"`
+	Format     string   `json:"format"`
+	Rule       int      `json:"rule"`       // index in rules
+	Item       string   `json:"item"`       // domain / domain_suffix / ... / logical
+	Pattern    string   `json:"pattern"`    // the matching entry
+	Conditions []string `json:"conditions"` // other items of the rule that were not evaluated
+}
+
+type RulesetMatchResult struct {
+	Value   string         `json:"value"`
+	Kind    string         `json:"kind"` // domain / ip
+	Checked int            `json:"checked"`
+	Matches []RulesetMatch `json:"matches"`
+	Errors  []string       `json:"errors"`
+}
+
+type loadedRuleset struct {
+	modTime time.Time
+	size    int64
+	format  string
+	rules   []headlessRule
+}
+
+// headlessRule holds the parts of a rule-set rule the inspector can evaluate against a
+// single domain or IP; everything else is kept by name in other.
+type headlessRule struct {
+	mode          string // and / or for logical rules
+	rules         []headlessRule
+	domain        []string
+	domainSuffix  []string
+	domainKeyword []string
+	domainRegex   []*regexp.Regexp
+	ipCIDR        []ipRange
+	other         []string
+	invert        bool
+}
+
+type ipRange struct {
+	from, to netip.Addr
+	text     string
+}
+
+type matchQuery struct {
+	domain string
+	ip     netip.Addr
+}
+
+// MatchRulesets reports which local rule-sets in data/rulesets, source or binary, match a
+// domain or an IP address, and by which rule.
+func (a *App) MatchRulesets(options RulesetMatchOptions) (RulesetMatchResult, error) {
+	slog.Info("MatchRulesets", "value", options.Value, "tags", options.Tags)
+
+	value := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(options.Value)), ".")
+	if value == "" {
+		return RulesetMatchResult{}, errors.New("missing value")
+	}
+	result := RulesetMatchResult{Value: value, Kind: "domain", Matches: []RulesetMatch{}, Errors: []string{}}
+	query := matchQuery{domain: value}
+	if ip, err := netip.ParseAddr(strings.Trim(value, "[]")); err == nil {
+		query = matchQuery{ip: ip.Unmap()}
+		result.Kind = "ip"
+	}
+
+	files, err := localRulesetFiles()
+	if err != nil {
+		return result, err
+	}
+	for _, file := range files {
+		if len(options.Tags) > 0 && !slices.Contains(options.Tags, file.tag) {
+			continue
+		}
+		ruleset, err := loadRuleset(file.path)
+		if err != nil {
+			result.Errors = append(result.Errors, file.path+": "+err.Error())
+			continue
+		}
+		result.Checked++
+		for i, rule := range ruleset.rules {
+			matched, item, pattern := rule.match(query)
+			if !matched {
+				continue
+			}
+			result.Matches = append(result.Matches, RulesetMatch{
+				Tag:        file.tag,
+				Path:       file.path,
+				Format:     ruleset.format,
+				Rule:       i,
+				Item:       item,
+				Pattern:    pattern,
+				Conditions: rule.other,
+			})
+		}
+	}
+	return result, nil
+}
+
+type rulesetFile struct {
+	tag  string
+	path string
+}
+
+// localRulesetFiles lists data/rulesets, naming each file after its rulesets.yaml entry. A
+// .srs compiled from a source rule-set is skipped in favour of the source.
+func localRulesetFiles() ([]rulesetFile, error) {
+	entries, err := os.ReadDir(GetPath(RulesetsDirectory))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []rulesetFile{}, nil
+		}
+		return nil, err
+	}
+	tags := map[string]string{}
+	if rulesets, err := readRulesets(); err == nil {
+		for _, ruleset := range rulesets {
+			tags[filepath.ToSlash(filepath.Clean(ruleset.Path))] = ruleset.Tag
+		}
+	}
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+
+	files := []rulesetFile{}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != ".json" && ext != ".srs") {
+			continue
+		}
+		base := strings.TrimSuffix(name, ext)
+		if ext == ".srs" && names[base+".json"] {
+			continue
+		}
+		path := RulesetsDirectory + "/" + name
+		tag := tags[path]
+		if tag == "" {
+			tag = tags[RulesetsDirectory+"/"+base+".json"]
+		}
+		files = append(files, rulesetFile{tag: firstNonEmpty(tag, base), path: path})
+	}
+	return files, nil
+}
+
+func loadRuleset(path string) (*loadedRuleset, error) {
+	info, err := os.Stat(GetPath(path))
+	if err != nil {
+		return nil, err
+	}
+
+	rulesetMatchCacheMu.Lock()
+	cached := rulesetMatchCache[path]
+	rulesetMatchCacheMu.Unlock()
+	if cached != nil && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached, nil
+	}
+
+	b, err := os.ReadFile(GetPath(path))
+	if err != nil {
+		return nil, err
+	}
+	ruleset := &loadedRuleset{modTime: info.ModTime(), size: info.Size()}
+	if bytes.HasPrefix(b, srsMagic) {
+		ruleset.format = RulesetFormatBinary
+		ruleset.rules, err = readBinaryRuleset(b)
+	} else {
+		ruleset.format = RulesetFormatSource
+		ruleset.rules, err = readSourceRuleset(b)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rulesetMatchCacheMu.Lock()
+	rulesetMatchCache[path] = ruleset
+	rulesetMatchCacheMu.Unlock()
+	return ruleset, nil
+}
+
+// match returns whether the rule matches, with the item and entry that decided it.
+func (r *headlessRule) match(q matchQuery) (bool, string, string) {
+	if r.mode != "" {
+		matched := r.mode == "and"
+		for i := range r.rules {
+			sub, _, _ := r.rules[i].match(q)
+			if r.mode == "and" && !sub {
+				matched = false
+				break
+			}
+			if r.mode == "or" && sub {
+				matched = true
+				break
+			}
+		}
+		if matched != r.invert {
+			return true, "logical", r.mode
+		}
+		return false, "", ""
+	}
+
+	matched, item, pattern := r.matchItems(q)
+	if r.invert {
+		if matched {
+			return false, "", ""
+		}
+		return true, "invert", ""
+	}
+	return matched, item, pattern
+}
+
+func (r *headlessRule) matchItems(q matchQuery) (bool, string, string) {
+	if q.ip.IsValid() {
+		for _, cidr := range r.ipCIDR {
+			if cidr.from.BitLen() == q.ip.BitLen() && cidr.from.Compare(q.ip) <= 0 && q.ip.Compare(cidr.to) <= 0 {
+				return true, "ip_cidr", cidr.text
+			}
+		}
+		return false, "", ""
+	}
+
+	for _, domain := range r.domain {
+		if q.domain == domain {
+			return true, "domain", domain
+		}
+	}
+	for _, suffix := range r.domainSuffix {
+		if strings.HasSuffix(q.domain, "."+strings.TrimPrefix(suffix, ".")) || (len(suffix) > 0 && suffix[0] != '.' && q.domain == suffix) {
+			return true, "domain_suffix", suffix
+		}
+	}
+	for _, keyword := range r.domainKeyword {
+		if strings.Contains(q.domain, keyword) {
+			return true, "domain_keyword", keyword
+		}
+	}
+	for _, re := range r.domainRegex {
+		if re.MatchString(q.domain) {
+			return true, "domain_regex", re.String()
+		}
+	}
+	return false, "", ""
+}
+
+func readSourceRuleset(b []byte) ([]headlessRule, error) {
+	var ruleset struct {
+		Rules []map[string]any `json:"rules"`
+	}
+	if err := json.Unmarshal(b, &ruleset); err != nil || ruleset.Rules == nil {
+		return nil, errors.New("Not a valid ruleset data")
+	}
+	rules := make([]headlessRule, 0, len(ruleset.Rules))
+	for i, raw := range ruleset.Rules {
+		rule, err := parseSourceRule(raw)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseSourceRule(raw map[string]any) (headlessRule, error) {
+	m := fieldMap(raw)
+	rule := headlessRule{invert: m.boolean("invert")}
+
+	if m.str("type") == "logical" {
+		rule.mode = m.str("mode")
+		if rule.mode != "and" && rule.mode != "or" {
+			return rule, errors.New("unknown logical mode: " + rule.mode)
+		}
+		subRules, _ := raw["rules"].([]any)
+		for _, item := range subRules {
+			sub, ok := item.(map[string]any)
+			if !ok {
+				return rule, errors.New("invalid logical rule")
+			}
+			parsed, err := parseSourceRule(sub)
+			if err != nil {
+				return rule, err
+			}
+			rule.rules = append(rule.rules, parsed)
+		}
+		return rule, nil
+	}
+
+	for key := range raw {
+		switch key {
+		case "type", "invert":
+		case "domain":
+			rule.domain = lowerList(m.list(key))
+		case "domain_suffix":
+			rule.domainSuffix = lowerList(m.list(key))
+		case "domain_keyword":
+			rule.domainKeyword = lowerList(m.list(key))
+		case "domain_regex":
+			for _, pattern := range m.list(key) {
+				re, err := regexp.Compile(pattern)
+				if err != nil {
+					return rule, err
+				}
+				rule.domainRegex = append(rule.domainRegex, re)
+			}
+		case "ip_cidr":
+			for _, cidr := range m.list(key) {
+				prefix, err := netip.ParsePrefix(cidr)
+				if err != nil {
+					addr, addrErr := netip.ParseAddr(cidr)
+					if addrErr != nil {
+						return rule, err
+					}
+					prefix = netip.PrefixFrom(addr, addr.BitLen())
+				}
+				rule.ipCIDR = append(rule.ipCIDR, prefixRange(prefix, cidr))
+			}
+		default:
+			rule.other = append(rule.other, key)
+		}
+	}
+	sort.Strings(rule.other)
+	return rule, nil
+}
+
+func prefixRange(prefix netip.Prefix, text string) ipRange {
+	prefix = prefix.Masked()
+	from := prefix.Addr().Unmap()
+	last := from.AsSlice()
+	for bit := prefix.Bits(); bit < len(last)*8; bit++ {
+		last[bit/8] |= 0x80 >> (bit % 8)
+	}
+	to, _ := netip.AddrFromSlice(last)
+	return ipRange{from: from, to: to, text: text}
+}
+
+// rangeOf describes an address range as a CIDR when it is exactly one prefix.
+func rangeOf(from, to netip.Addr) ipRange {
+	for bits := from.BitLen(); bits >= 0; bits-- {
+		prefix := netip.PrefixFrom(from, bits)
+		if prefix.Masked().Addr() != from {
+			break
+		}
+		if r := prefixRange(prefix, ""); r.to == to {
+			return ipRange{from: from, to: to, text: prefix.String()}
+		}
+	}
+	return ipRange{from: from, to: to, text: from.String() + "-" + to.String()}
+}
+
+func lowerList(list []string) []string {
+	for i := range list {
+		list[i] = strings.ToLower(list[i])
+	}
+	return list
+}
+
+// readBinaryRuleset decodes a .srs file: "SRS", a version byte, then a zlib stream with
+// the rule count and the rules.
+func readBinaryRuleset(b []byte) ([]headlessRule, error) {
+	if len(b) < 4 {
+		return nil, errors.New("Not a valid binary ruleset")
+	}
+	compressed, err := zlib.NewReader(bytes.NewReader(b[4:]))
+	if err != nil {
+		return nil, err
+	}
+	defer compressed.Close()
+	reader := bufio.NewReader(compressed)
+
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]headlessRule, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		rule, err := readBinaryRule(reader)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func readBinaryRule(reader *bufio.Reader) (headlessRule, error) {
+	ruleType, err := reader.ReadByte()
+	if err != nil {
+		return headlessRule{}, err
+	}
+	switch ruleType {
+	case 0:
+		return readBinaryDefaultRule(reader)
+	case 1:
+		return readBinaryLogicalRule(reader)
+	}
+	return headlessRule{}, fmt.Errorf("unknown rule type: %d", ruleType)
+}
+
+func readBinaryLogicalRule(reader *bufio.Reader) (headlessRule, error) {
+	rule := headlessRule{}
+	mode, err := reader.ReadByte()
+	if err != nil {
+		return rule, err
+	}
+	switch mode {
+	case 0:
+		rule.mode = "and"
+	case 1:
+		rule.mode = "or"
+	default:
+		return rule, fmt.Errorf("unknown logical mode: %d", mode)
+	}
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return rule, err
+	}
+	for i := uint64(0); i < count; i++ {
+		sub, err := readBinaryRule(reader)
+		if err != nil {
+			return rule, err
+		}
+		rule.rules = append(rule.rules, sub)
+	}
+	invert, err := reader.ReadByte()
+	rule.invert = invert != 0
+	return rule, err
+}
+
+func readBinaryDefaultRule(reader *bufio.Reader) (headlessRule, error) {
+	rule := headlessRule{}
+	for {
+		itemType, err := reader.ReadByte()
+		if err != nil {
+			return rule, err
+		}
+		switch itemType {
+		case srsItemDomain:
+			keys, err := readSuccinctKeys(reader)
+			if err != nil {
+				return rule, err
+			}
+			domains, suffixes := dumpDomainKeys(keys)
+			rule.domain, rule.domainSuffix = lowerList(domains), lowerList(suffixes)
+		case srsItemDomainKeyword:
+			var keywords []string
+			keywords, err = readSRSStrings(reader)
+			rule.domainKeyword = lowerList(keywords)
+		case srsItemDomainRegex:
+			var patterns []string
+			if patterns, err = readSRSStrings(reader); err == nil {
+				for _, pattern := range patterns {
+					re, compileErr := regexp.Compile(pattern)
+					if compileErr != nil {
+						return rule, compileErr
+					}
+					rule.domainRegex = append(rule.domainRegex, re)
+				}
+			}
+		case srsItemIPCIDR:
+			rule.ipCIDR, err = readSRSIPSet(reader)
+		case srsItemSourceIPCIDR:
+			_, err = readSRSIPSet(reader)
+		case srsItemAdGuardDomain:
+			_, err = readSuccinctKeys(reader)
+		case srsItemQueryType, srsItemSourcePort, srsItemPort:
+			err = skipSRSSlice(reader, 2)
+		case srsItemNetworkType:
+			err = skipSRSSlice(reader, 1)
+		case srsItemNetworkIsExpensive, srsItemNetworkIsConstrained:
+		case srsItemNetwork, srsItemSourcePortRange, srsItemPortRange, srsItemProcessName, srsItemProcessPath,
+			srsItemPackageName, srsItemWIFISSID, srsItemWIFIBSSID, srsItemProcessPathRegex:
+			_, err = readSRSStrings(reader)
+		case srsItemFinal:
+			invert, err := reader.ReadByte()
+			rule.invert = invert != 0
+			sort.Strings(rule.other)
+			return rule, err
+		default:
+			return rule, fmt.Errorf("unknown rule item type: %d", itemType)
+		}
+		if err != nil {
+			return rule, err
+		}
+		if name, ok := srsItemNames[itemType]; ok {
+			rule.other = append(rule.other, name)
+		}
+	}
+}
+
+func readSRSStrings(reader *bufio.Reader) ([]string, error) {
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]string, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		b, err := readSRSBytes(reader)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, string(b))
+	}
+	return list, nil
+}
+
+func readSRSBytes(reader *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if length > maxRulesetSize {
+		return nil, errors.New("invalid length")
+	}
+	b := make([]byte, length)
+	_, err = io.ReadFull(reader, b)
+	return b, err
+}
+
+func skipSRSSlice(reader *bufio.Reader, itemSize int) error {
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return err
+	}
+	_, err = reader.Discard(int(count) * itemSize)
+	return err
+}
+
+func readSRSUint64s(reader *bufio.Reader) ([]uint64, error) {
+	count, err := binary.ReadUvarint(reader)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxRulesetSize/8 {
+		return nil, errors.New("invalid length")
+	}
+	words := make([]uint64, count)
+	err = binary.Read(reader, binary.BigEndian, words)
+	return words, err
+}
+
+// readSRSIPSet reads the address ranges of an IP set (version byte, uint64 count, then
+// from/to address pairs).
+func readSRSIPSet(reader *bufio.Reader) ([]ipRange, error) {
+	version, err := reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if version != 1 {
+		return nil, fmt.Errorf("unknown ip set version: %d", version)
+	}
+	var count uint64
+	if err := binary.Read(reader, binary.BigEndian, &count); err != nil {
+		return nil, err
+	}
+	ranges := make([]ipRange, 0, min(count, 1<<16))
+	for i := uint64(0); i < count; i++ {
+		fromBytes, err := readSRSBytes(reader)
+		if err != nil {
+			return nil, err
+		}
+		toBytes, err := readSRSBytes(reader)
+		if err != nil {
+			return nil, err
+		}
+		from, ok1 := netip.AddrFromSlice(fromBytes)
+		to, ok2 := netip.AddrFromSlice(toBytes)
+		if !ok1 || !ok2 {
+			return nil, errors.New("invalid ip range")
+		}
+		ranges = append(ranges, rangeOf(from.Unmap(), to.Unmap()))
+	}
+	return ranges, nil
+}
+
+// readSuccinctKeys decodes the LOUDS-encoded trie of a domain matcher and returns its keys.
+// Node children are runs of 0 bits ending in a 1 bit, in breadth-first order, so the n-th
+// 0 bit is the edge leading to node n.
+func readSuccinctKeys(reader *bufio.Reader) ([]string, error) {
+	if _, err := reader.ReadByte(); err != nil { // reserved
+		return nil, err
+	}
+	leaves, err := readSRSUint64s(reader)
+	if err != nil {
+		return nil, err
+	}
+	bitmap, err := readSRSUint64s(reader)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := readSRSBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	bit := func(words []uint64, i int) bool {
+		return i>>6 < len(words) && words[i>>6]&(1<<uint(i&63)) != 0
+	}
+
+	prefixes := []string{""}
+	keys := []string{}
+	node, edges := 0, 0
+	for i := 0; i < len(bitmap)*64 && node < len(prefixes); i++ {
+		if bit(bitmap, i) {
+			if bit(leaves, node) {
+				keys = append(keys, prefixes[node])
+			}
+			node++
+			continue
+		}
+		if edges >= len(labels) {
+			return nil, errors.New("invalid domain matcher")
+		}
+		prefixes = append(prefixes, prefixes[node]+string(labels[edges]))
+		edges++
+	}
+	return keys, nil
+}
+
+// dumpDomainKeys turns matcher keys (reversed domains, marked with '\r' for a suffix that
+// needs a dot and '\n' for a suffix that also matches the domain itself) back into lists.
+func dumpDomainKeys(keys []string) ([]string, []string) {
+	domains := map[string]bool{}
+	prefixes := map[string]bool{}
+	suffixes := []string{}
+	for _, key := range keys {
+		key = reverseString(key)
+		if key == "" {
+			continue
+		}
+		switch key[0] {
+		case '\r':
+			prefixes[key[1:]] = true
+		case '\n':
+			suffixes = append(suffixes, key[1:])
+		default:
+			domains[key] = true
+		}
+	}
+	for prefix := range prefixes {
+		if root, ok := strings.CutPrefix(prefix, "."); ok && domains[root] {
+			delete(domains, root)
+			suffixes = append(suffixes, root)
+			continue
+		}
+		suffixes = append(suffixes, prefix)
+	}
+	domainList := make([]string, 0, len(domains))
+	for domain := range domains {
+		domainList = append(domainList, domain)
+	}
+	sort.Strings(domainList)
+	sort.Strings(suffixes)
+	return domainList, suffixes
+}
+
+func reverseString(value string) string {
+	runes := []rune(value)
+	slices.Reverse(runes)
+	return string(runes)
+}
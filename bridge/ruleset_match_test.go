@@ -0,0 +1,23 @@
+package bridge
+
+import "testing"
+
+func TestMatchEmptyDomainSuffix(t *testing.T) {
+	rules, err := readSourceRuleset([]byte(`{"rules":[{"domain_suffix":["","example.com"]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A binary rule-set yields an empty suffix for the key "\n"
+	domains, suffixes := dumpDomainKeys([]string{"\n", reverseString("\nexample.com")})
+	rules = append(rules, headlessRule{domain: domains, domainSuffix: suffixes})
+
+	for i, rule := range rules {
+		matched, item, pattern := rule.match(matchQuery{domain: "www.example.com"})
+		if !matched || item != "domain_suffix" || pattern != "example.com" {
+			t.Errorf("rules[%d] www.example.com: got %v %q %q", i, matched, item, pattern)
+		}
+		if matched, _, _ := rule.match(matchQuery{domain: "example.org"}); matched {
+			t.Errorf("rules[%d] example.org: matched", i)
+		}
+	}
+}
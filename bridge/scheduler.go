@@ -0,0 +1,624 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ScheduledTasksFilePath    = "data/scheduledtasks.yaml"
+	SchedulerSettingsFilePath = "data/scheduler.yaml"
+	SchedulerHistoryFilePath  = "data/scheduledtasks-history.json"
+	SchedulerLastTimeFilePath = "data/scheduledtasks-lasttime.json"
+
+	TaskUpdateSubscription = "update::subscription"
+	TaskUpdateRuleset      = "update::ruleset"
+	TaskUpdatePlugin       = "update::plugin"
+	TaskRunPlugin          = "run::plugin"
+	TaskRunScript          = "run::script"
+	TaskRestartCore        = "restart::core"
+
+	MissedRunSkip = "skip"
+	MissedRunOnce = "run-once"
+
+	// SchedulerDispatchEvent asks a connected panel to run a task that has no server-side runner.
+	SchedulerDispatchEvent = "scheduledtask::dispatch"
+
+	schedulerReloadInterval = 30 * time.Second
+)
+
+// cronParser accepts the 5 and 6 field (leading seconds) expressions understood by croner.
+var cronParser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+var dispatchCounter uint64
+
+type ScheduledTask struct {
+	ID            string   `yaml:"id" json:"id"`
+	Name          string   `yaml:"name" json:"name"`
+	Type          string   `yaml:"type" json:"type"`
+	Subscriptions []string `yaml:"subscriptions" json:"subscriptions"`
+	Rulesets      []string `yaml:"rulesets" json:"rulesets"`
+	Plugins       []string `yaml:"plugins" json:"plugins"`
+	Script        string   `yaml:"script" json:"script"`
+	Cron          string   `yaml:"cron" json:"cron"`
+	Notification  bool     `yaml:"notification" json:"notification"`
+	Disabled      bool     `yaml:"disabled" json:"disabled"`
+	LastTime      int64    `yaml:"lastTime" json:"lastTime"`
+}
+
+type SchedulerSettings struct {
+	Disabled        bool   `yaml:"disabled" json:"disabled"`
+	MissedRunPolicy string `yaml:"missedRunPolicy" json:"missedRunPolicy"` // skip / run-once
+	HistoryLimit    int    `yaml:"historyLimit" json:"historyLimit"`       // runs kept per task
+	DispatchTimeout int    `yaml:"dispatchTimeout" json:"dispatchTimeout"` // seconds
+}
+
+type TaskRun struct {
+	TaskID    string   `json:"taskId"`
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Trigger   string   `json:"trigger"` // schedule / missed / manual
+	StartTime int64    `json:"startTime"`
+	EndTime   int64    `json:"endTime"`
+	Success   bool     `json:"success"`
+	Result    []string `json:"result"`
+	Error     string   `json:"error"`
+}
+
+type TaskState struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Cron     string   `json:"cron"`
+	Disabled bool     `json:"disabled"`
+	Next     int64    `json:"next"`
+	Running  bool     `json:"running"`
+	Error    string   `json:"error"`
+	LastTime int64    `json:"lastTime"`
+	LastRun  *TaskRun `json:"lastRun"`
+}
+
+type SchedulerStatus struct {
+	Settings SchedulerSettings `json:"settings"`
+	Tasks    []TaskState       `json:"tasks"`
+}
+
+// TaskRunner executes one scheduled task and returns one line of output per item.
+type TaskRunner func(ctx context.Context, task ScheduledTask) ([]string, error)
+
+// Scheduler runs scheduledtasks.yaml server-side so tasks fire without an open browser tab.
+type Scheduler struct {
+	app *App
+
+	mu          sync.Mutex
+	runners     map[string]TaskRunner
+	headless    map[string]TaskRunner
+	settings    SchedulerSettings
+	tasks       []ScheduledTask
+	schedules   map[string]cron.Schedule
+	parseErrors map[string]string
+	next        map[string]time.Time
+	running     map[string]bool
+	history     map[string][]TaskRun
+	lastTime    map[string]int64
+	modTime     time.Time
+
+	wake chan struct{}
+	stop chan struct{}
+	once sync.Once
+}
+
+func NewScheduler(a *App) *Scheduler {
+	s := &Scheduler{
+		app:         a,
+		runners:     make(map[string]TaskRunner),
+		headless:    make(map[string]TaskRunner),
+		schedules:   make(map[string]cron.Schedule),
+		parseErrors: make(map[string]string),
+		next:        make(map[string]time.Time),
+		running:     make(map[string]bool),
+		history:     make(map[string][]TaskRun),
+		lastTime:    make(map[string]int64),
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+	}
+
+	s.RegisterRunner(TaskRestartCore, func(_ context.Context, _ ScheduledTask) ([]string, error) {
+		pid, err := a.RestartCore(0)
+		if err != nil {
+			return nil, err
+		}
+		return []string{"Core restarted, pid " + strconv.Itoa(pid)}, nil
+	})
+	s.RegisterHeadlessRunner(TaskUpdateSubscription, a.subscriptionTaskRunner)
+	s.RegisterHeadlessRunner(TaskUpdateRuleset, a.rulesetTaskRunner)
+
+	return s
+}
+
+// RegisterRunner installs a server-side implementation for a task type. Types without a
+// runner are dispatched to a connected panel.
+func (s *Scheduler) RegisterRunner(taskType string, runner TaskRunner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runners[taskType] = runner
+}
+
+// RegisterHeadlessRunner installs a server-side fallback that is only used while no panel is
+// connected, so panel-side plugins and scripts still take part whenever a panel is open.
+func (s *Scheduler) RegisterHeadlessRunner(taskType string, runner TaskRunner) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.headless[taskType] = runner
+}
+
+func (s *Scheduler) Start() {
+	s.loadHistory()
+	s.loadLastTime()
+	go s.loop()
+}
+
+func (s *Scheduler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// Reload re-reads scheduledtasks.yaml and scheduler.yaml immediately.
+func (s *Scheduler) Reload() error {
+	if err := s.reload(false); err != nil {
+		return err
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *Scheduler) Status() SchedulerStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := SchedulerStatus{Settings: s.settings, Tasks: []TaskState{}}
+	for _, task := range s.tasks {
+		state := TaskState{
+			ID:       task.ID,
+			Name:     task.Name,
+			Type:     task.Type,
+			Cron:     task.Cron,
+			Disabled: task.Disabled,
+			Running:  s.running[task.ID],
+			Error:    s.parseErrors[task.ID],
+			LastTime: s.taskLastTime(task),
+		}
+		if next, ok := s.next[task.ID]; ok {
+			state.Next = next.UnixMilli()
+		}
+		if runs := s.history[task.ID]; len(runs) > 0 {
+			last := runs[len(runs)-1]
+			state.LastRun = &last
+		}
+		status.Tasks = append(status.Tasks, state)
+	}
+	return status
+}
+
+func (s *Scheduler) History(id string) []TaskRun {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id != "" {
+		return append([]TaskRun{}, s.history[id]...)
+	}
+	result := []TaskRun{}
+	for _, runs := range s.history {
+		result = append(result, runs...)
+	}
+	return result
+}
+
+// RunTask runs a task immediately and waits for its result.
+func (s *Scheduler) RunTask(id string) (TaskRun, error) {
+	s.mu.Lock()
+	var task *ScheduledTask
+	for i := range s.tasks {
+		if s.tasks[i].ID == id {
+			task = &s.tasks[i]
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if task == nil {
+		return TaskRun{}, errors.New(id + " Not Found")
+	}
+	return s.run(*task, "manual")
+}
+
+func (s *Scheduler) loop() {
+	if err := s.reload(true); err != nil {
+		slog.Error("Scheduler reload", "error", err)
+	}
+
+	for {
+		wait := schedulerReloadInterval
+		now := time.Now()
+		s.mu.Lock()
+		for _, next := range s.next {
+			if d := next.Sub(now); d < wait {
+				wait = d
+			}
+		}
+		s.mu.Unlock()
+
+		timer := time.NewTimer(max(wait, 0))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-s.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+
+		if err := s.reloadIfChanged(); err != nil {
+			slog.Error("Scheduler reload", "error", err)
+		}
+
+		now = time.Now()
+		var due []ScheduledTask
+		s.mu.Lock()
+		for _, task := range s.tasks {
+			next, ok := s.next[task.ID]
+			if !ok || next.After(now) {
+				continue
+			}
+			s.next[task.ID] = s.schedules[task.ID].Next(now)
+			if !s.settings.Disabled {
+				due = append(due, task)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, task := range due {
+			go s.run(task, "schedule")
+		}
+	}
+}
+
+func (s *Scheduler) reloadIfChanged() error {
+	info, err := os.Stat(GetPath(ScheduledTasksFilePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	s.mu.Lock()
+	changed := !info.ModTime().Equal(s.modTime)
+	s.mu.Unlock()
+	if !changed {
+		return nil
+	}
+	return s.reload(false)
+}
+
+func (s *Scheduler) reload(startup bool) error {
+	settings := loadSchedulerSettings()
+
+	var tasks []ScheduledTask
+	var modTime time.Time
+	path := GetPath(ScheduledTasksFilePath)
+	if info, err := os.Stat(path); err == nil {
+		modTime = info.ModTime()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if err := yaml.Unmarshal(b, &tasks); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	var missed []ScheduledTask
+
+	s.mu.Lock()
+	previousCron := make(map[string]string, len(s.tasks))
+	for _, task := range s.tasks {
+		previousCron[task.ID] = task.Cron
+	}
+	previousNext := s.next
+
+	s.settings = settings
+	s.tasks = tasks
+	s.modTime = modTime
+	s.schedules = make(map[string]cron.Schedule)
+	s.parseErrors = make(map[string]string)
+	s.next = make(map[string]time.Time)
+	for _, task := range tasks {
+		schedule, err := cronParser.Parse(strings.TrimSpace(task.Cron))
+		if err != nil {
+			s.parseErrors[task.ID] = err.Error()
+			continue
+		}
+		if task.Disabled {
+			continue
+		}
+		s.schedules[task.ID] = schedule
+		// Keep a pending fire time across reloads so a due run is not skipped.
+		if next, ok := previousNext[task.ID]; ok && previousCron[task.ID] == task.Cron {
+			s.next[task.ID] = next
+		} else {
+			s.next[task.ID] = schedule.Next(now)
+		}
+
+		if lastTime := s.taskLastTime(task); startup && lastTime > 0 && schedule.Next(time.UnixMilli(lastTime)).Before(now) {
+			missed = append(missed, task)
+		}
+	}
+	s.mu.Unlock()
+
+	if !settings.Disabled && settings.MissedRunPolicy == MissedRunOnce {
+		for _, task := range missed {
+			go s.run(task, "missed")
+		}
+	}
+
+	if s.app.Bus != nil {
+		s.app.Bus.Emit("scheduledtasks::reloaded")
+	}
+
+	return nil
+}
+
+func (s *Scheduler) run(task ScheduledTask, trigger string) (TaskRun, error) {
+	s.mu.Lock()
+	if s.running[task.ID] {
+		s.mu.Unlock()
+		return TaskRun{}, errors.New(task.Name + " is already running")
+	}
+	s.running[task.ID] = true
+	runner, ok := s.runners[task.Type]
+	headless, hasHeadless := s.headless[task.Type]
+	timeout := s.settings.DispatchTimeout
+	s.mu.Unlock()
+
+	if !ok && hasHeadless && (s.app.Bus == nil || !s.app.Bus.HasSubscribers(SchedulerDispatchEvent)) {
+		runner, ok = headless, true
+	}
+	if !ok {
+		runner = func(ctx context.Context, task ScheduledTask) ([]string, error) {
+			return s.dispatchToClient(ctx, task, timeout)
+		}
+	}
+
+	slog.Info("Scheduler run", "trigger", trigger, "name", task.Name, "type", task.Type)
+
+	run := TaskRun{
+		TaskID:    task.ID,
+		Name:      task.Name,
+		Type:      task.Type,
+		Trigger:   trigger,
+		StartTime: time.Now().UnixMilli(),
+	}
+
+	s.mu.Lock()
+	s.lastTime[task.ID] = run.StartTime
+	s.mu.Unlock()
+	s.saveLastTime()
+
+	if s.app.Bus != nil {
+		s.app.Bus.Emit("scheduledtask::started", run)
+	}
+
+	result, err := runner(context.Background(), task)
+	run.EndTime = time.Now().UnixMilli()
+	run.Result = result
+	if run.Result == nil {
+		run.Result = []string{}
+	}
+	run.Success = err == nil
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	s.mu.Lock()
+	s.running[task.ID] = false
+	limit := s.settings.HistoryLimit
+	runs := append(s.history[task.ID], run)
+	if len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	s.history[task.ID] = runs
+	s.mu.Unlock()
+
+	s.saveHistory()
+
+	if s.app.Bus != nil {
+		s.app.Bus.Emit("scheduledtask::finished", run)
+	}
+
+	if task.Notification {
+		message := strings.Join(run.Result, "\n")
+		options := NotifyOptions{Severity: NotificationInfo, Source: "scheduler"}
+		if run.Error != "" {
+			message = strings.TrimSpace(message + "\n" + run.Error)
+			options.Severity = NotificationError
+		}
+		s.app.Notify(task.Name, message, "", options)
+	}
+
+	return run, err
+}
+
+// dispatchToClient hands the task to a connected panel and waits for the reply, following
+// the request/response pattern used by handleHttpRequest.
+func (s *Scheduler) dispatchToClient(ctx context.Context, task ScheduledTask, timeout int) ([]string, error) {
+	bus := s.app.Bus
+	if bus == nil || !bus.HasSubscribers(SchedulerDispatchEvent) {
+		return nil, errors.New("task type " + task.Type + " requires an open panel")
+	}
+
+	if timeout <= 0 {
+		timeout = 300
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	requestID := SchedulerDispatchEvent + "::" + strconv.FormatUint(atomic.AddUint64(&dispatchCounter, 1), 10)
+	respChan := make(chan []any, 1)
+	unsubscribe := bus.On(requestID, func(data []any) {
+		select {
+		case respChan <- data:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	// Every open panel receives the dispatch and claims it. Each claim is answered with
+	// the first claimant, so exactly one panel runs the task and the others drop it. The
+	// answer is emitted here rather than in the handler, which runs while the bus is
+	// delivering the claim.
+	claimChan := make(chan string, 16)
+	unclaim := bus.On(requestID+"::claim", func(data []any) {
+		if len(data) == 0 {
+			return
+		}
+		if clientID, ok := data[0].(string); ok {
+			select {
+			case claimChan <- clientID:
+			default:
+			}
+		}
+	})
+	defer unclaim()
+
+	bus.Emit(SchedulerDispatchEvent, requestID, task.ID)
+
+	winner := ""
+	for {
+		select {
+		case clientID := <-claimChan:
+			if winner == "" {
+				winner = clientID
+			}
+			bus.Emit(requestID+"::claimed", winner)
+		case data := <-respChan:
+			return parseDispatchReply(data)
+		case <-ctx.Done():
+			return nil, errors.New("timed out waiting for the panel to run the task")
+		}
+	}
+}
+
+func parseDispatchReply(data []any) ([]string, error) {
+	var result []string
+	if len(data) > 0 {
+		if items, ok := data[0].([]any); ok {
+			for _, item := range items {
+				if text, ok := item.(string); ok {
+					result = append(result, text)
+				}
+			}
+		}
+	}
+	if len(data) > 1 {
+		if message, ok := data[1].(string); ok && message != "" {
+			return result, errors.New(message)
+		}
+	}
+	return result, nil
+}
+
+func loadSchedulerSettings() SchedulerSettings {
+	settings := SchedulerSettings{}
+	b, err := os.ReadFile(GetPath(SchedulerSettingsFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	if settings.MissedRunPolicy == "" {
+		settings.MissedRunPolicy = MissedRunSkip
+	}
+	if settings.HistoryLimit <= 0 {
+		settings.HistoryLimit = 50
+	}
+	return settings
+}
+
+func (s *Scheduler) loadHistory() {
+	b, err := os.ReadFile(GetPath(SchedulerHistoryFilePath))
+	if err != nil {
+		return
+	}
+	history := make(map[string][]TaskRun)
+	if err := json.Unmarshal(b, &history); err != nil {
+		slog.Error("Scheduler loadHistory", "error", err)
+		return
+	}
+	s.mu.Lock()
+	s.history = history
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) saveHistory() {
+	s.mu.Lock()
+	b, err := json.Marshal(s.history)
+	s.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(GetPath(SchedulerHistoryFilePath), b, 0644); err != nil {
+		slog.Error("Scheduler saveHistory", "error", err)
+	}
+}
+
+// taskLastTime prefers the server-owned record and falls back to a lastTime still present
+// in scheduledtasks.yaml from older versions. s.mu must be held.
+func (s *Scheduler) taskLastTime(task ScheduledTask) int64 {
+	if lastTime, ok := s.lastTime[task.ID]; ok {
+		return lastTime
+	}
+	return task.LastTime
+}
+
+func (s *Scheduler) loadLastTime() {
+	b, err := os.ReadFile(GetPath(SchedulerLastTimeFilePath))
+	if err != nil {
+		return
+	}
+	lastTime := make(map[string]int64)
+	if err := json.Unmarshal(b, &lastTime); err != nil {
+		slog.Error("Scheduler loadLastTime", "error", err)
+		return
+	}
+	s.mu.Lock()
+	s.lastTime = lastTime
+	s.mu.Unlock()
+}
+
+func (s *Scheduler) saveLastTime() {
+	s.mu.Lock()
+	b, err := json.Marshal(s.lastTime)
+	s.mu.Unlock()
+	if err != nil {
+		return
+	}
+	if err := WriteFileAtomic(GetPath(SchedulerLastTimeFilePath), b, 0644); err != nil {
+		slog.Error("Scheduler saveLastTime", "error", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"io"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
@@ -83,7 +84,7 @@ func (a *App) StartServer(address string, serverID string, options ServerOptions
 
 	go func() {
 		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
-			log.Printf("Server error on %s: %v", address, err)
+			slog.Error("Server error", "address", address, "error", err)
 		}
 	}()
 
@@ -0,0 +1,451 @@
+package bridge
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SharesFilePath      = "data/shares.yaml"
+	ShareAccessFilePath = "data/share-access.json"
+	ProfilesFilePath    = "data/profiles.yaml"
+
+	ShareFormatBase64  = "base64"
+	ShareFormatClash   = "clash"
+	ShareFormatSingBox = "sing-box"
+)
+
+var (
+	sharesFileMu      sync.Mutex
+	shareAccessFileMu sync.Mutex
+
+	ErrShareTokenNotFound = errors.New("share token not found")
+)
+
+// ShareSelection picks outbounds from a profile and/or whole subscriptions; Tags, when
+// set, narrows the result down to the listed outbounds.
+type ShareSelection struct {
+	Profile       string   `yaml:"profile" json:"profile"`
+	Subscriptions []string `yaml:"subscriptions" json:"subscriptions"`
+	Tags          []string `yaml:"tags" json:"tags"`
+}
+
+type ShareTokenOptions struct {
+	ShareSelection `yaml:",inline"`
+	Name           string `yaml:"name" json:"name"`
+	Format         string `yaml:"format" json:"format"` // base64 / clash / sing-box
+}
+
+type ShareToken struct {
+	ShareTokenOptions `yaml:",inline"`
+	ID                string `yaml:"id" json:"id"`
+	Token             string `yaml:"token" json:"token"`
+	Revoked           bool   `yaml:"revoked" json:"revoked"`
+	Hits              int64  `yaml:"-" json:"hits"`       // from share-access.json
+	LastAccess        int64  `yaml:"-" json:"lastAccess"` // from share-access.json
+	CreateTime        int64  `yaml:"createTime" json:"createTime"`
+}
+
+// shareAccess counts hits per token id apart from shares.yaml, so serving a subscription
+// does not rewrite the file that holds the secrets.
+type shareAccess struct {
+	Hits       int64 `json:"hits"`
+	LastAccess int64 `json:"lastAccess"`
+}
+
+type ShareContent struct {
+	Name        string
+	ContentType string
+	Body        []byte
+}
+
+type profileOutbound struct {
+	Type      string              `yaml:"type"`
+	Include   string              `yaml:"include"`
+	Exclude   string              `yaml:"exclude"`
+	Outbounds []SubscriptionProxy `yaml:"outbounds"`
+}
+
+type profileEntry struct {
+	ID        string            `yaml:"id"`
+	Outbounds []profileOutbound `yaml:"outbounds"`
+}
+
+func (a *App) ListShareTokens() ([]ShareToken, error) {
+	slog.Info("ListShareTokens")
+
+	sharesFileMu.Lock()
+	tokens, err := readShareTokens()
+	sharesFileMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	shareAccessFileMu.Lock()
+	access := readShareAccess()
+	shareAccessFileMu.Unlock()
+	for i := range tokens {
+		tokens[i].Hits = access[tokens[i].ID].Hits
+		tokens[i].LastAccess = access[tokens[i].ID].LastAccess
+	}
+	return tokens, nil
+}
+
+// CreateShareToken stores a new read-only token for GET /sub/{token}.
+func (a *App) CreateShareToken(options ShareTokenOptions) (ShareToken, error) {
+	slog.Info("CreateShareToken", "name", options.Name, "format", options.Format)
+
+	switch options.Format {
+	case "":
+		options.Format = ShareFormatBase64
+	case ShareFormatBase64, ShareFormatClash, ShareFormatSingBox:
+	default:
+		return ShareToken{}, errors.New("unknown share format: " + options.Format)
+	}
+	if options.Profile == "" && len(options.Subscriptions) == 0 {
+		return ShareToken{}, errors.New("missing profile or subscriptions")
+	}
+
+	secret, err := randomBytes(24)
+	if err != nil {
+		return ShareToken{}, err
+	}
+	token := ShareToken{
+		ShareTokenOptions: options,
+		ID:                sampleID(),
+		Token:             base64.RawURLEncoding.EncodeToString(secret),
+		CreateTime:        time.Now().UnixMilli(),
+	}
+
+	sharesFileMu.Lock()
+	defer sharesFileMu.Unlock()
+
+	tokens, err := readShareTokens()
+	if err != nil {
+		return ShareToken{}, err
+	}
+	if err := writeShareTokens(append(tokens, token)); err != nil {
+		return ShareToken{}, err
+	}
+	return token, nil
+}
+
+func (a *App) RevokeShareToken(id string) error {
+	slog.Info("RevokeShareToken", "id", id)
+
+	return editShareTokens(func(tokens []ShareToken) ([]ShareToken, error) {
+		i := slices.IndexFunc(tokens, func(t ShareToken) bool { return t.ID == id })
+		if i < 0 {
+			return nil, errors.New(id + " Not Found")
+		}
+		tokens[i].Revoked = true
+		return tokens, nil
+	})
+}
+
+func (a *App) RemoveShareToken(id string) error {
+	slog.Info("RemoveShareToken", "id", id)
+
+	err := editShareTokens(func(tokens []ShareToken) ([]ShareToken, error) {
+		i := slices.IndexFunc(tokens, func(t ShareToken) bool { return t.ID == id })
+		if i < 0 {
+			return nil, errors.New(id + " Not Found")
+		}
+		return slices.Delete(tokens, i, i+1), nil
+	})
+	if err != nil {
+		return err
+	}
+	return editShareAccess(func(access map[string]shareAccess) {
+		delete(access, id)
+	})
+}
+
+// ServeShareToken renders the subscription of token and counts the access. Unknown and
+// revoked tokens both return ErrShareTokenNotFound.
+func (a *App) ServeShareToken(secret string) (ShareContent, error) {
+	sharesFileMu.Lock()
+	tokens, err := readShareTokens()
+	sharesFileMu.Unlock()
+	if err != nil {
+		return ShareContent{}, err
+	}
+	i := slices.IndexFunc(tokens, func(t ShareToken) bool {
+		return subtle.ConstantTimeCompare([]byte(t.Token), []byte(secret)) == 1
+	})
+	if i < 0 || tokens[i].Revoked {
+		return ShareContent{}, ErrShareTokenNotFound
+	}
+	token := tokens[i]
+
+	outbounds, err := resolveShareOutbounds(token.ShareSelection)
+	if err != nil {
+		return ShareContent{}, err
+	}
+	content, err := RenderShareSubscription(outbounds, token.Format)
+	if err != nil {
+		return ShareContent{}, err
+	}
+	content.Name = firstNonEmpty(token.Name, token.ID)
+
+	var hits int64
+	err = editShareAccess(func(access map[string]shareAccess) {
+		entry := access[token.ID]
+		entry.Hits++
+		entry.LastAccess = time.Now().UnixMilli()
+		access[token.ID] = entry
+		hits = entry.Hits
+	})
+	if err != nil {
+		slog.Error("ServeShareToken editShareAccess", "id", token.ID, "error", err)
+	}
+
+	slog.Info("ServeShareToken", "id", token.ID, "hits", hits)
+
+	return content, nil
+}
+
+// RenderShareSubscription serializes outbounds in a subscription format. Outbounds that
+// the format cannot express are left out.
+func RenderShareSubscription(outbounds []map[string]any, format string) (ShareContent, error) {
+	switch format {
+	case "", ShareFormatBase64:
+		links := []string{}
+		for _, outbound := range outbounds {
+			if link, err := ExportShareLink(outbound); err == nil {
+				links = append(links, link)
+			}
+		}
+		body := base64.StdEncoding.EncodeToString([]byte(strings.Join(links, "\n")))
+		return ShareContent{ContentType: "text/plain; charset=utf-8", Body: []byte(body)}, nil
+	case ShareFormatClash:
+		type proxyGroup struct {
+			Name    string   `yaml:"name"`
+			Type    string   `yaml:"type"`
+			Proxies []string `yaml:"proxies"`
+		}
+		config := struct {
+			Proxies     []map[string]any `yaml:"proxies"`
+			ProxyGroups []proxyGroup     `yaml:"proxy-groups"`
+			Rules       []string         `yaml:"rules"`
+		}{
+			Proxies: []map[string]any{},
+			Rules:   []string{"MATCH,PROXY"},
+		}
+		group := proxyGroup{Name: "PROXY", Type: "select", Proxies: []string{}}
+		for _, outbound := range outbounds {
+			if proxy, err := ExportClashProxy(outbound); err == nil {
+				config.Proxies = append(config.Proxies, proxy)
+				group.Proxies = append(group.Proxies, anyToString(proxy["name"]))
+			}
+		}
+		if len(group.Proxies) == 0 {
+			group.Proxies = append(group.Proxies, "DIRECT")
+		}
+		config.ProxyGroups = []proxyGroup{group}
+		body, err := yaml.Marshal(config)
+		if err != nil {
+			return ShareContent{}, err
+		}
+		return ShareContent{ContentType: "text/yaml; charset=utf-8", Body: body}, nil
+	case ShareFormatSingBox:
+		body, err := json.MarshalIndent(map[string]any{"outbounds": outbounds}, "", "  ")
+		if err != nil {
+			return ShareContent{}, err
+		}
+		return ShareContent{ContentType: "application/json; charset=utf-8", Body: body}, nil
+	}
+	return ShareContent{}, errors.New("unknown share format: " + format)
+}
+
+// resolveShareOutbounds collects the proxy outbounds of a selection the way the profile
+// generator does: a "Subscription" entry brings in the whole subscription filtered by the
+// group's include/exclude, any other non built-in entry one proxy of subscription <type>.
+func resolveShareOutbounds(selection ShareSelection) ([]map[string]any, error) {
+	subs, err := readSubscriptions()
+	if err != nil {
+		return nil, err
+	}
+	cache := map[string][]map[string]any{}
+	load := func(id string) ([]map[string]any, error) {
+		if outbounds, ok := cache[id]; ok {
+			return outbounds, nil
+		}
+		i := slices.IndexFunc(subs, func(s Subscription) bool { return s.ID == id })
+		if i < 0 {
+			return nil, errors.New("subscription " + id + " Not Found")
+		}
+		b, err := os.ReadFile(GetPath(subs[i].Path))
+		if err != nil {
+			return nil, err
+		}
+		outbounds := []map[string]any{}
+		if err := json.Unmarshal(b, &outbounds); err != nil {
+			return nil, err
+		}
+		cache[id] = outbounds
+		return outbounds, nil
+	}
+
+	result := []map[string]any{}
+	seen := map[string]bool{}
+	add := func(outbound map[string]any) {
+		tag := anyToString(outbound["tag"])
+		if seen[tag] || (len(selection.Tags) > 0 && !slices.Contains(selection.Tags, tag)) {
+			return
+		}
+		seen[tag] = true
+		result = append(result, outbound)
+	}
+
+	if selection.Profile != "" {
+		profile, err := readProfile(selection.Profile)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range profile.Outbounds {
+			match, err := tagMatcher(group.Include, group.Exclude)
+			if err != nil {
+				return nil, err
+			}
+			for _, proxy := range group.Outbounds {
+				if proxy.Type == "Built-in" {
+					continue
+				}
+				subID := proxy.Type
+				if proxy.Type == "Subscription" {
+					subID = proxy.ID
+				}
+				outbounds, err := load(subID)
+				if err != nil {
+					return nil, err
+				}
+				for _, outbound := range outbounds {
+					tag := anyToString(outbound["tag"])
+					if (proxy.Type == "Subscription" || tag == proxy.Tag) && match(tag) {
+						add(outbound)
+					}
+				}
+			}
+		}
+	}
+
+	for _, id := range selection.Subscriptions {
+		outbounds, err := load(id)
+		if err != nil {
+			return nil, err
+		}
+		for _, outbound := range outbounds {
+			add(outbound)
+		}
+	}
+
+	return result, nil
+}
+
+func readProfile(id string) (profileEntry, error) {
+	b, err := os.ReadFile(GetPath(ProfilesFilePath))
+	if err != nil {
+		return profileEntry{}, err
+	}
+	profiles := []profileEntry{}
+	if err := yaml.Unmarshal(b, &profiles); err != nil {
+		return profileEntry{}, err
+	}
+	for _, profile := range profiles {
+		if profile.ID == id {
+			return profile, nil
+		}
+	}
+	return profileEntry{}, errors.New("profile " + id + " Not Found")
+}
+
+func tagMatcher(include, exclude string) (func(string) bool, error) {
+	var includeRe, excludeRe *regexp.Regexp
+	var err error
+	if include != "" {
+		if includeRe, err = regexp.Compile(include); err != nil {
+			return nil, errors.New("invalid filter " + strconv.Quote(include) + ": " + err.Error())
+		}
+	}
+	if exclude != "" {
+		if excludeRe, err = regexp.Compile(exclude); err != nil {
+			return nil, errors.New("invalid filter " + strconv.Quote(exclude) + ": " + err.Error())
+		}
+	}
+	return func(tag string) bool {
+		return (includeRe == nil || includeRe.MatchString(tag)) && (excludeRe == nil || !excludeRe.MatchString(tag))
+	}, nil
+}
+
+func editShareTokens(edit func([]ShareToken) ([]ShareToken, error)) error {
+	sharesFileMu.Lock()
+	defer sharesFileMu.Unlock()
+
+	tokens, err := readShareTokens()
+	if err != nil {
+		return err
+	}
+	tokens, err = edit(tokens)
+	if err != nil {
+		return err
+	}
+	return writeShareTokens(tokens)
+}
+
+func readShareTokens() ([]ShareToken, error) {
+	b, err := os.ReadFile(GetPath(SharesFilePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ShareToken{}, nil
+		}
+		return nil, err
+	}
+	tokens := []ShareToken{}
+	if err := yaml.Unmarshal(b, &tokens); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func writeShareTokens(tokens []ShareToken) error {
+	b, err := yaml.Marshal(tokens)
+	if err != nil {
+		return err
+	}
+	// The file holds the token secrets
+	return WriteFileAtomic(GetPath(SharesFilePath), b, 0600)
+}
+
+func readShareAccess() map[string]shareAccess {
+	access := map[string]shareAccess{}
+	if b, err := os.ReadFile(GetPath(ShareAccessFilePath)); err == nil {
+		_ = json.Unmarshal(b, &access)
+	}
+	return access
+}
+
+func editShareAccess(edit func(map[string]shareAccess)) error {
+	shareAccessFileMu.Lock()
+	defer shareAccessFileMu.Unlock()
+
+	access := readShareAccess()
+	edit(access)
+	b, err := json.Marshal(access)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(GetPath(ShareAccessFilePath), b, 0644)
+}
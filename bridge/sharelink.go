@@ -0,0 +1,547 @@
+package bridge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ParseShareLink converts one share link (vmess://, vless://, ss:// ...) into a sing-box outbound.
+func ParseShareLink(link string) (map[string]any, error) {
+	link = strings.TrimSpace(link)
+	scheme, _, ok := strings.Cut(link, "://")
+	if !ok {
+		return nil, errors.New("not a share link")
+	}
+
+	switch strings.ToLower(scheme) {
+	case "vmess":
+		return parseVMessLink(link)
+	case "vless":
+		return parseVLESSLink(link)
+	case "trojan":
+		return parseTrojanLink(link)
+	case "ss":
+		return parseShadowsocksLink(link)
+	case "hysteria2", "hy2":
+		return parseHysteria2Link(link)
+	case "tuic":
+		return parseTUICLink(link)
+	case "wireguard", "wg":
+		return parseWireGuardLink(link)
+	}
+
+	return nil, errors.New("unsupported share link scheme: " + scheme)
+}
+
+type ShareLinkResult struct {
+	Link     string         `json:"link"`
+	Outbound map[string]any `json:"outbound"`
+	Error    string         `json:"error"`
+}
+
+// ConvertShareLinks parses each link independently so one bad link does not fail the batch.
+func (a *App) ConvertShareLinks(links []string) []ShareLinkResult {
+	slog.Info("ConvertShareLinks", "links", len(links))
+
+	results := make([]ShareLinkResult, 0, len(links))
+	for _, link := range links {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			continue
+		}
+		result := ShareLinkResult{Link: link}
+		outbound, err := ParseShareLink(link)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Outbound = outbound
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ParseShareLinks parses one link per line, skipping blank lines and collecting per-line errors.
+// Errors name the line and scheme only, since the start of a link is usually its credentials.
+func ParseShareLinks(text string) ([]map[string]any, []string) {
+	outbounds := []map[string]any{}
+	errs := []string{}
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		outbound, err := ParseShareLink(line)
+		if err != nil {
+			scheme, _, ok := strings.Cut(line, "://")
+			if !ok || len(scheme) > 16 {
+				scheme = "unknown"
+			}
+			errs = append(errs, "line "+strconv.Itoa(i+1)+" ("+scheme+"): "+err.Error())
+			continue
+		}
+		outbounds = append(outbounds, outbound)
+	}
+	return outbounds, errs
+}
+
+func parseVMessLink(link string) (map[string]any, error) {
+	payload := strings.TrimPrefix(link[strings.Index(link, "://")+3:], "/")
+	decoded, err := decodeBase64String(payload)
+	if err != nil {
+		return nil, errors.New("invalid vmess payload")
+	}
+
+	var v map[string]any
+	if err := json.Unmarshal(decoded, &v); err != nil {
+		return nil, errors.New("invalid vmess payload")
+	}
+	str := func(key string) string { return anyToString(v[key]) }
+
+	port, err := strconv.Atoi(str("port"))
+	if err != nil {
+		return nil, errors.New("invalid vmess port")
+	}
+	alterID, _ := strconv.Atoi(str("aid"))
+	security := str("scy")
+	if security == "" {
+		security = "auto"
+	}
+
+	outbound := map[string]any{
+		"type":        "vmess",
+		"tag":         linkTag(str("ps"), str("add"), port),
+		"server":      str("add"),
+		"server_port": port,
+		"uuid":        str("id"),
+		"security":    security,
+		"alter_id":    alterID,
+	}
+
+	if tlsMode := str("tls"); tlsMode == "tls" {
+		outbound["tls"] = buildTLS(firstNonEmpty(str("sni"), str("host")), false, splitList(str("alpn")), str("fp"), "", "")
+	}
+	network := str("net")
+	if network == "tcp" && str("type") == "http" {
+		network = "http"
+	}
+	if transport := buildTransport(network, str("host"), str("path"), str("path")); transport != nil {
+		outbound["transport"] = transport
+	}
+
+	return outbound, nil
+}
+
+func parseVLESSLink(link string) (map[string]any, error) {
+	u, port, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+
+	outbound := map[string]any{
+		"type":        "vless",
+		"tag":         linkTag(u.Fragment, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"uuid":        u.User.Username(),
+	}
+	if flow := q.Get("flow"); flow != "" {
+		outbound["flow"] = flow
+	}
+	if encoding := q.Get("packetEncoding"); encoding != "" {
+		outbound["packet_encoding"] = encoding
+	}
+	applyLinkTLS(outbound, q, q.Get("security"))
+	if transport := linkTransport(q); transport != nil {
+		outbound["transport"] = transport
+	}
+
+	return outbound, nil
+}
+
+func parseTrojanLink(link string) (map[string]any, error) {
+	u, port, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+
+	outbound := map[string]any{
+		"type":        "trojan",
+		"tag":         linkTag(u.Fragment, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"password":    u.User.Username(),
+	}
+	security := q.Get("security")
+	if security == "" {
+		security = "tls"
+	}
+	applyLinkTLS(outbound, q, security)
+	if transport := linkTransport(q); transport != nil {
+		outbound["transport"] = transport
+	}
+
+	return outbound, nil
+}
+
+// parseShadowsocksLink accepts SIP002 (ss://base64(method:password)@host:port) and the legacy
+// form where the whole authority is base64 encoded.
+func parseShadowsocksLink(link string) (map[string]any, error) {
+	body := link[strings.Index(link, "://")+3:]
+	body, fragment, _ := strings.Cut(body, "#")
+	body, query, _ := strings.Cut(body, "?")
+	body = strings.TrimSuffix(body, "/")
+
+	if !strings.Contains(body, "@") {
+		decoded, err := decodeBase64String(body)
+		if err != nil {
+			return nil, errors.New("invalid shadowsocks payload")
+		}
+		body = string(decoded)
+	}
+
+	idx := strings.LastIndex(body, "@")
+	if idx == -1 {
+		return nil, errors.New("invalid shadowsocks payload")
+	}
+	userinfo, hostport := body[:idx], body[idx+1:]
+	if unescaped, err := url.PathUnescape(userinfo); err == nil {
+		userinfo = unescaped
+	}
+	if !strings.Contains(userinfo, ":") {
+		decoded, err := decodeBase64String(userinfo)
+		if err != nil {
+			return nil, errors.New("invalid shadowsocks userinfo")
+		}
+		userinfo = string(decoded)
+	}
+	method, password, ok := strings.Cut(userinfo, ":")
+	if !ok {
+		return nil, errors.New("invalid shadowsocks userinfo")
+	}
+
+	host, portText, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return nil, errors.New("invalid shadowsocks server")
+	}
+	port, err := strconv.Atoi(portText)
+	if err != nil {
+		return nil, errors.New("invalid shadowsocks port")
+	}
+	name, _ := url.PathUnescape(fragment)
+
+	outbound := map[string]any{
+		"type":        "shadowsocks",
+		"tag":         linkTag(name, host, port),
+		"server":      host,
+		"server_port": port,
+		"method":      method,
+		"password":    password,
+	}
+
+	values, _ := url.ParseQuery(query)
+	if plugin := values.Get("plugin"); plugin != "" {
+		name, opts, _ := strings.Cut(plugin, ";")
+		if name == "simple-obfs" {
+			name = "obfs-local"
+		}
+		outbound["plugin"] = name
+		if opts != "" {
+			outbound["plugin_opts"] = opts
+		}
+	}
+
+	return outbound, nil
+}
+
+func parseHysteria2Link(link string) (map[string]any, error) {
+	u, port, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+
+	password := u.User.Username()
+	if secret, ok := u.User.Password(); ok {
+		password += ":" + secret
+	}
+
+	outbound := map[string]any{
+		"type":        "hysteria2",
+		"tag":         linkTag(u.Fragment, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"password":    password,
+	}
+	if ports := q.Get("mport"); ports != "" {
+		outbound["server_ports"] = splitPortRanges(ports)
+	}
+	if obfs := q.Get("obfs"); obfs != "" && obfs != "none" {
+		outbound["obfs"] = map[string]any{
+			"type":     obfs,
+			"password": q.Get("obfs-password"),
+		}
+	}
+	outbound["tls"] = buildTLS(q.Get("sni"), linkBool(q, "insecure"), splitList(q.Get("alpn")), "", "", "")
+
+	return outbound, nil
+}
+
+func parseTUICLink(link string) (map[string]any, error) {
+	u, port, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	password, _ := u.User.Password()
+
+	outbound := map[string]any{
+		"type":        "tuic",
+		"tag":         linkTag(u.Fragment, u.Hostname(), port),
+		"server":      u.Hostname(),
+		"server_port": port,
+		"uuid":        u.User.Username(),
+		"password":    password,
+	}
+	if cc := q.Get("congestion_control"); cc != "" {
+		outbound["congestion_control"] = cc
+	}
+	if mode := q.Get("udp_relay_mode"); mode != "" {
+		outbound["udp_relay_mode"] = mode
+	}
+	insecure := linkBool(q, "allow_insecure") || linkBool(q, "insecure")
+	outbound["tls"] = buildTLS(q.Get("sni"), insecure, splitList(q.Get("alpn")), "", "", "")
+
+	return outbound, nil
+}
+
+// parseWireGuardLink reads wireguard://<private key>@host:port?publickey=&address=&reserved=
+// into a legacy wireguard outbound.
+func parseWireGuardLink(link string) (map[string]any, error) {
+	u, port, err := parseLinkURL(link)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+
+	privateKey := u.User.Username()
+	publicKey := firstNonEmpty(q.Get("publickey"), q.Get("publicKey"), q.Get("public_key"))
+	if publicKey == "" {
+		return nil, errors.New("missing wireguard peer public key")
+	}
+
+	outbound := map[string]any{
+		"type":            "wireguard",
+		"tag":             linkTag(u.Fragment, u.Hostname(), port),
+		"server":          u.Hostname(),
+		"server_port":     port,
+		"private_key":     privateKey,
+		"peer_public_key": publicKey,
+	}
+	address := splitList(firstNonEmpty(q.Get("address"), q.Get("ip")))
+	for i, prefix := range address {
+		if !strings.Contains(prefix, "/") {
+			if strings.Contains(prefix, ":") {
+				address[i] = prefix + "/128"
+			} else {
+				address[i] = prefix + "/32"
+			}
+		}
+	}
+	if len(address) > 0 {
+		outbound["local_address"] = address
+	}
+	if psk := firstNonEmpty(q.Get("presharedkey"), q.Get("preSharedKey"), q.Get("pre_shared_key")); psk != "" {
+		outbound["pre_shared_key"] = psk
+	}
+	if reserved := splitList(q.Get("reserved")); len(reserved) > 0 {
+		values := []int{}
+		for _, item := range reserved {
+			n, err := strconv.Atoi(item)
+			if err != nil || n < 0 || n > 255 {
+				return nil, errors.New("invalid wireguard reserved bytes")
+			}
+			values = append(values, n)
+		}
+		outbound["reserved"] = values
+	}
+	if mtu, err := strconv.Atoi(q.Get("mtu")); err == nil && mtu > 0 {
+		outbound["mtu"] = mtu
+	}
+
+	return outbound, nil
+}
+
+func parseLinkURL(link string) (*url.URL, int, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		// *url.Error repeats the whole link, credentials included
+		return nil, 0, withoutURL(err)
+	}
+	if u.Hostname() == "" {
+		return nil, 0, errors.New("missing server address")
+	}
+	port, err := strconv.Atoi(u.Port())
+	if err != nil {
+		return nil, 0, errors.New("invalid port")
+	}
+	if u.User == nil {
+		return nil, 0, errors.New("missing credentials")
+	}
+	return u, port, nil
+}
+
+func applyLinkTLS(outbound map[string]any, q url.Values, security string) {
+	switch security {
+	case "tls", "xtls":
+		outbound["tls"] = buildTLS(q.Get("sni"), linkBool(q, "allowInsecure") || linkBool(q, "insecure"), splitList(q.Get("alpn")), q.Get("fp"), "", "")
+	case "reality":
+		outbound["tls"] = buildTLS(q.Get("sni"), false, splitList(q.Get("alpn")), firstNonEmpty(q.Get("fp"), "chrome"), q.Get("pbk"), q.Get("sid"))
+	}
+}
+
+func linkTransport(q url.Values) map[string]any {
+	network := q.Get("type")
+	if network == "tcp" && q.Get("headerType") == "http" {
+		network = "http"
+	}
+	return buildTransport(network, q.Get("host"), q.Get("path"), q.Get("serviceName"))
+}
+
+// buildTLS assembles a sing-box tls object; a non-empty publicKey turns on reality.
+func buildTLS(serverName string, insecure bool, alpn []string, fingerprint, publicKey, shortID string) map[string]any {
+	tls := map[string]any{"enabled": true}
+	if serverName != "" {
+		tls["server_name"] = serverName
+	}
+	if insecure {
+		tls["insecure"] = true
+	}
+	if len(alpn) > 0 {
+		tls["alpn"] = alpn
+	}
+	if fingerprint != "" {
+		tls["utls"] = map[string]any{"enabled": true, "fingerprint": fingerprint}
+	}
+	if publicKey != "" {
+		tls["reality"] = map[string]any{"enabled": true, "public_key": publicKey, "short_id": shortID}
+	}
+	return tls
+}
+
+// buildTransport maps a v2ray network name to a sing-box transport; tcp yields nil.
+func buildTransport(network, host, path, serviceName string) map[string]any {
+	switch network {
+	case "ws":
+		transport := map[string]any{"type": "ws"}
+		if path != "" {
+			// Xray encodes early data as "/path?ed=2048"
+			if p, query, ok := strings.Cut(path, "?"); ok {
+				if values, err := url.ParseQuery(query); err == nil {
+					if ed, err := strconv.Atoi(values.Get("ed")); err == nil {
+						path = p
+						transport["max_early_data"] = ed
+						transport["early_data_header_name"] = "Sec-WebSocket-Protocol"
+					}
+				}
+			}
+			transport["path"] = path
+		}
+		if host != "" {
+			transport["headers"] = map[string]any{"Host": host}
+		}
+		return transport
+	case "grpc":
+		transport := map[string]any{"type": "grpc"}
+		if serviceName != "" {
+			transport["service_name"] = serviceName
+		}
+		return transport
+	case "http", "h2":
+		transport := map[string]any{"type": "http"}
+		if hosts := splitList(host); len(hosts) > 0 {
+			transport["host"] = hosts
+		}
+		if path != "" {
+			transport["path"] = path
+		}
+		return transport
+	case "httpupgrade":
+		transport := map[string]any{"type": "httpupgrade"}
+		if host != "" {
+			transport["host"] = host
+		}
+		if path != "" {
+			transport["path"] = path
+		}
+		return transport
+	}
+	return nil
+}
+
+// splitPortRanges turns "443,5000-6000" into sing-box server_ports ("5000:6000").
+func splitPortRanges(ports string) []string {
+	ranges := []string{}
+	for _, part := range splitList(ports) {
+		ranges = append(ranges, strings.ReplaceAll(part, "-", ":"))
+	}
+	return ranges
+}
+
+func linkTag(name, server string, port int) string {
+	if name = strings.TrimSpace(name); name != "" {
+		return name
+	}
+	return net.JoinHostPort(server, strconv.Itoa(port))
+}
+
+func linkBool(q url.Values, key string) bool {
+	value := strings.ToLower(q.Get(key))
+	return value == "1" || value == "true"
+}
+
+func splitList(value string) []string {
+	list := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// decodeBase64String accepts standard and URL-safe alphabets, with or without padding.
+func decodeBase64String(value string) ([]byte, error) {
+	value = strings.Join(strings.Fields(value), "")
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	}
+	var err error
+	for _, encoding := range encodings {
+		var decoded []byte
+		if decoded, err = encoding.DecodeString(value); err == nil {
+			return decoded, nil
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,256 @@
+package bridge
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type ShareLinkExport struct {
+	Tag   string `json:"tag"`
+	Link  string `json:"link"`
+	Error string `json:"error"`
+}
+
+// ExportShareLinks is the reverse of ConvertShareLinks, producing one link per outbound.
+func (a *App) ExportShareLinks(outbounds []map[string]any) []ShareLinkExport {
+	slog.Info("ExportShareLinks", "outbounds", len(outbounds))
+
+	results := make([]ShareLinkExport, 0, len(outbounds))
+	for _, outbound := range outbounds {
+		result := ShareLinkExport{Tag: anyToString(outbound["tag"])}
+		link, err := ExportShareLink(outbound)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Link = link
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// ExportShareLink converts a sing-box outbound back into the share link ParseShareLink reads.
+func ExportShareLink(outbound map[string]any) (string, error) {
+	o := fieldMap(outbound)
+
+	server := o.str("server")
+	port := o.num("server_port")
+	if server == "" || port == 0 {
+		return "", errors.New("missing server or server_port")
+	}
+
+	u := &url.URL{
+		Host:     net.JoinHostPort(server, strconv.Itoa(port)),
+		Fragment: o.str("tag"),
+	}
+	q := url.Values{}
+
+	switch o.str("type") {
+	case "vmess":
+		return exportVMessLink(o, server, port)
+	case "vless":
+		u.Scheme = "vless"
+		u.User = url.User(o.str("uuid"))
+		q.Set("encryption", "none")
+		if flow := o.str("flow"); flow != "" {
+			q.Set("flow", flow)
+		}
+		if encoding := o.str("packet_encoding"); encoding != "" {
+			q.Set("packetEncoding", encoding)
+		}
+		exportLinkTLS(o.obj("tls"), q)
+		exportLinkTransport(o.obj("transport"), q)
+	case "trojan":
+		u.Scheme = "trojan"
+		u.User = url.User(o.str("password"))
+		exportLinkTLS(o.obj("tls"), q)
+		exportLinkTransport(o.obj("transport"), q)
+	case "shadowsocks":
+		u.Scheme = "ss"
+		userinfo := base64.RawURLEncoding.EncodeToString([]byte(o.str("method") + ":" + o.str("password")))
+		u.User = url.User(userinfo)
+		if plugin := o.str("plugin"); plugin != "" {
+			if opts := o.str("plugin_opts"); opts != "" {
+				plugin += ";" + opts
+			}
+			q.Set("plugin", plugin)
+		}
+	case "hysteria2":
+		u.Scheme = "hysteria2"
+		u.User = url.User(o.str("password"))
+		if ports := o.list("server_ports"); len(ports) > 0 {
+			q.Set("mport", strings.ReplaceAll(strings.Join(ports, ","), ":", "-"))
+		}
+		if obfs := o.obj("obfs"); obfs.str("type") != "" {
+			q.Set("obfs", obfs.str("type"))
+			q.Set("obfs-password", obfs.str("password"))
+		}
+		exportLinkTLSParams(o.obj("tls"), q, "insecure")
+	case "tuic":
+		u.Scheme = "tuic"
+		u.User = url.UserPassword(o.str("uuid"), o.str("password"))
+		if cc := o.str("congestion_control"); cc != "" {
+			q.Set("congestion_control", cc)
+		}
+		if mode := o.str("udp_relay_mode"); mode != "" {
+			q.Set("udp_relay_mode", mode)
+		}
+		exportLinkTLSParams(o.obj("tls"), q, "allow_insecure")
+	case "wireguard":
+		u.Scheme = "wireguard"
+		u.User = url.User(o.str("private_key"))
+		q.Set("publickey", o.str("peer_public_key"))
+		if address := o.list("local_address"); len(address) > 0 {
+			q.Set("address", strings.Join(address, ","))
+		}
+		if psk := o.str("pre_shared_key"); psk != "" {
+			q.Set("presharedkey", psk)
+		}
+		if reserved := o.list("reserved"); len(reserved) > 0 {
+			q.Set("reserved", strings.Join(reserved, ","))
+		}
+		if mtu := o.num("mtu"); mtu > 0 {
+			q.Set("mtu", strconv.Itoa(mtu))
+		}
+	default:
+		return "", errors.New("unsupported outbound type: " + o.str("type"))
+	}
+
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func exportVMessLink(o fieldMap, server string, port int) (string, error) {
+	v := map[string]string{
+		"v":    "2",
+		"ps":   o.str("tag"),
+		"add":  server,
+		"port": strconv.Itoa(port),
+		"id":   o.str("uuid"),
+		"aid":  strconv.Itoa(o.num("alter_id")),
+		"scy":  firstNonEmpty(o.str("security"), "auto"),
+		"net":  "tcp",
+		"type": "none",
+	}
+
+	tls := o.obj("tls")
+	if tls.boolean("enabled") {
+		v["tls"] = "tls"
+		v["sni"] = tls.str("server_name")
+		v["alpn"] = strings.Join(tls.list("alpn"), ",")
+		v["fp"] = tls.obj("utls").str("fingerprint")
+	}
+
+	transport := o.obj("transport")
+	switch transport.str("type") {
+	case "ws":
+		v["net"] = "ws"
+		v["host"] = transport.obj("headers").str("Host")
+		v["path"] = websocketLinkPath(transport)
+	case "grpc":
+		v["net"] = "grpc"
+		v["path"] = transport.str("service_name")
+	case "http":
+		v["net"] = "h2"
+		v["host"] = strings.Join(transport.list("host"), ",")
+		v["path"] = transport.str("path")
+	case "httpupgrade":
+		v["net"] = "httpupgrade"
+		v["host"] = transport.str("host")
+		v["path"] = transport.str("path")
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(b), nil
+}
+
+func exportLinkTLS(tls fieldMap, q url.Values) {
+	if !tls.boolean("enabled") {
+		q.Set("security", "none")
+		return
+	}
+	reality := tls.obj("reality")
+	if reality.boolean("enabled") {
+		q.Set("security", "reality")
+		q.Set("pbk", reality.str("public_key"))
+		if sid := reality.str("short_id"); sid != "" {
+			q.Set("sid", sid)
+		}
+	} else {
+		q.Set("security", "tls")
+	}
+	exportLinkTLSParams(tls, q, "allowInsecure")
+	if fp := tls.obj("utls").str("fingerprint"); fp != "" {
+		q.Set("fp", fp)
+	}
+}
+
+// exportLinkTLSParams writes sni/alpn and the scheme-specific insecure flag.
+func exportLinkTLSParams(tls fieldMap, q url.Values, insecureKey string) {
+	if sni := tls.str("server_name"); sni != "" {
+		q.Set("sni", sni)
+	}
+	if alpn := tls.list("alpn"); len(alpn) > 0 {
+		q.Set("alpn", strings.Join(alpn, ","))
+	}
+	if tls.boolean("insecure") {
+		q.Set(insecureKey, "1")
+	}
+}
+
+func exportLinkTransport(transport fieldMap, q url.Values) {
+	switch transport.str("type") {
+	case "":
+		q.Set("type", "tcp")
+	case "ws":
+		q.Set("type", "ws")
+		if host := transport.obj("headers").str("Host"); host != "" {
+			q.Set("host", host)
+		}
+		if path := websocketLinkPath(transport); path != "" {
+			q.Set("path", path)
+		}
+	case "grpc":
+		q.Set("type", "grpc")
+		if name := transport.str("service_name"); name != "" {
+			q.Set("serviceName", name)
+		}
+	case "http":
+		q.Set("type", "http")
+		if host := transport.list("host"); len(host) > 0 {
+			q.Set("host", strings.Join(host, ","))
+		}
+		if path := transport.str("path"); path != "" {
+			q.Set("path", path)
+		}
+	case "httpupgrade":
+		q.Set("type", "httpupgrade")
+		if host := transport.str("host"); host != "" {
+			q.Set("host", host)
+		}
+		if path := transport.str("path"); path != "" {
+			q.Set("path", path)
+		}
+	}
+}
+
+// websocketLinkPath folds max_early_data back into the "?ed=" form used by share links.
+func websocketLinkPath(transport fieldMap) string {
+	path := transport.str("path")
+	if early := transport.num("max_early_data"); early > 0 {
+		if path == "" {
+			path = "/"
+		}
+		path += "?ed=" + strconv.Itoa(early)
+	}
+	return path
+}
@@ -0,0 +1,783 @@
+package bridge
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.etcd.io/bbolt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	StatsDatabaseFilePath = "data/stats.db"
+	StatsSettingsFilePath = "data/stats.yaml"
+
+	statsPollInterval    = 5 * time.Second
+	statsFlushInterval   = 15 * time.Second
+	statsPruneInterval   = time.Hour
+	statsSnapshotMillis  = 1000
+	statsMaxPoints       = 10000
+	statsDefaultRange    = 24 * time.Hour
+	statsDefaultMaxSteps = 300
+)
+
+var (
+	statsTrafficBucket  = []byte("traffic")
+	statsOutboundBucket = []byte("outbounds")
+	statsRuleBucket     = []byte("rules")
+)
+
+// statsResolution is one granularity kept in the store; every sample is written to all of them
+// so that long ranges can be charted from the coarser one after fine samples expire.
+type statsResolution struct {
+	name      string
+	size      time.Duration
+	retention func(StatsSettings) int
+}
+
+var statsResolutions = []statsResolution{
+	{"minute", time.Minute, func(s StatsSettings) int { return s.MinuteRetention }},
+	{"hour", time.Hour, func(s StatsSettings) int { return s.HourRetention }},
+}
+
+type StatsSettings struct {
+	Disabled        bool `yaml:"disabled" json:"disabled"`
+	MinuteRetention int  `yaml:"minuteRetention" json:"minuteRetention"` // days of per-minute samples, -1 keeps them
+	HourRetention   int  `yaml:"hourRetention" json:"hourRetention"`     // days of per-hour samples, -1 keeps them
+}
+
+type StatsStatus struct {
+	Settings  StatsSettings `json:"settings"`
+	Recording bool          `json:"recording"`
+	Error     string        `json:"error"`
+}
+
+type TrafficQuery struct {
+	From int64         // unix milliseconds, defaults to To minus one day
+	To   int64         // unix milliseconds, defaults to now
+	Step time.Duration // rounded up to the sample resolution, picked from the range when zero
+}
+
+type TrafficPoint struct {
+	Time int64 `json:"time"`
+	Up   int64 `json:"up"`
+	Down int64 `json:"down"`
+}
+
+type TrafficCounter struct {
+	Name string `json:"name"`
+	Up   int64  `json:"up"`
+	Down int64  `json:"down"`
+}
+
+// StatsTotals are running counters since the server started, for /metrics.
+type StatsTotals struct {
+	Recording   bool
+	Up          int64
+	Down        int64
+	Connections int // open in the last /connections snapshot
+	Outbounds   []TrafficCounter
+	Rules       []TrafficCounter
+}
+
+type TrafficHistory struct {
+	From       int64            `json:"from"`
+	To         int64            `json:"to"`
+	Step       int64            `json:"step"` // seconds
+	Resolution string           `json:"resolution"`
+	Points     []TrafficPoint   `json:"points"`
+	Outbounds  []TrafficCounter `json:"outbounds"`
+	Rules      []TrafficCounter `json:"rules"`
+}
+
+type statsCounter struct {
+	up, down, count int64
+}
+
+type statsSample struct {
+	traffic   statsCounter
+	outbounds map[string]*statsCounter
+	rules     map[string]*statsCounter
+	usage     map[string]map[string]*statsCounter // closed connections by usage dimension
+}
+
+type coreConnection struct {
+	ID       string `json:"id"`
+	Metadata struct {
+		Type          string `json:"type"` // inbound type/tag
+		SourceIP      string `json:"sourceIP"`
+		DestinationIP string `json:"destinationIP"`
+		Host          string `json:"host"`
+	} `json:"metadata"`
+	Upload   int64    `json:"upload"`
+	Download int64    `json:"download"`
+	Chains   []string `json:"chains"`
+	Rule     string   `json:"rule"`
+}
+
+type coreConnectionsSnapshot struct {
+	Connections []coreConnection `json:"connections"`
+}
+
+// StatsRecorder follows the core's Clash API while the core runs and keeps per-minute traffic
+// totals, plus per-outbound and per-rule byte counters, in data/stats.db.
+type StatsRecorder struct {
+	app *App
+
+	mu        sync.Mutex
+	db        *bbolt.DB
+	settings  StatsSettings
+	pending   map[int64]*statsSample // keyed by unix minute
+	totals    *statsSample           // since the server started, usage left out
+	open      int
+	recording bool
+	lastError string
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+func NewStatsRecorder(a *App) *StatsRecorder {
+	return &StatsRecorder{
+		app:      a,
+		settings: loadStatsSettings(),
+		pending:  make(map[int64]*statsSample),
+		totals:   newStatsSample(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (r *StatsRecorder) Start() {
+	db, err := bbolt.Open(GetPath(StatsDatabaseFilePath), 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		slog.Error("StatsRecorder open", "error", err)
+		r.setError(err)
+		close(r.done)
+		return
+	}
+	r.mu.Lock()
+	r.db = db
+	r.mu.Unlock()
+	go r.loop()
+}
+
+// Stop ends recording, writes out pending samples and closes the store.
+func (r *StatsRecorder) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.db != nil {
+		_ = r.db.Close()
+		r.db = nil
+	}
+}
+
+func (r *StatsRecorder) Status() StatsStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return StatsStatus{Settings: r.settings, Recording: r.recording, Error: r.lastError}
+}
+
+func (r *StatsRecorder) Totals() StatsTotals {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totals := StatsTotals{
+		Recording: r.recording,
+		Up:        r.totals.traffic.up,
+		Down:      r.totals.traffic.down,
+	}
+	if r.recording {
+		totals.Connections = r.open
+	}
+	for name, counter := range r.totals.outbounds {
+		totals.Outbounds = append(totals.Outbounds, TrafficCounter{Name: name, Up: counter.up, Down: counter.down})
+	}
+	for name, counter := range r.totals.rules {
+		totals.Rules = append(totals.Rules, TrafficCounter{Name: name, Up: counter.up, Down: counter.down})
+	}
+	return totals
+}
+
+func (r *StatsRecorder) loop() {
+	defer close(r.done)
+
+	poll := time.NewTicker(statsPollInterval)
+	defer poll.Stop()
+	flush := time.NewTicker(statsFlushInterval)
+	defer flush.Stop()
+
+	var cancel context.CancelFunc
+	var session chan struct{}
+	endSession := func() {
+		if cancel != nil {
+			cancel()
+			<-session
+			cancel, session = nil, nil
+		}
+	}
+	var lastPrune time.Time
+
+	for {
+		settings := loadStatsSettings()
+		r.mu.Lock()
+		r.settings = settings
+		r.mu.Unlock()
+
+		if session != nil {
+			select {
+			case <-session:
+				cancel, session = nil, nil
+			default:
+			}
+		}
+
+		running := !settings.Disabled && r.app.CoreStatus().Running
+		if running && session == nil {
+			if api, err := ActiveClashAPI(); err != nil {
+				r.setError(err)
+			} else {
+				cancel, session = r.startRecording(api)
+			}
+		} else if !running {
+			endSession()
+		}
+
+		if time.Since(lastPrune) >= statsPruneInterval {
+			lastPrune = time.Now()
+			if err := r.prune(settings); err != nil {
+				slog.Error("StatsRecorder prune", "error", err)
+			}
+		}
+
+		select {
+		case <-r.stop:
+			endSession()
+			if err := r.flush(); err != nil {
+				slog.Error("StatsRecorder flush", "error", err)
+			}
+			return
+		case <-flush.C:
+			if err := r.flush(); err != nil {
+				slog.Error("StatsRecorder flush", "error", err)
+			}
+		case <-poll.C:
+		}
+	}
+}
+
+func (r *StatsRecorder) startRecording(api ClashAPI) (context.CancelFunc, chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.record(ctx, api)
+	}()
+	return cancel, done
+}
+
+// record follows /traffic and /connections until either stream fails or ctx is cancelled.
+func (r *StatsRecorder) record(ctx context.Context, api ClashAPI) {
+	r.mu.Lock()
+	r.recording = true
+	r.lastError = ""
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	errCh := make(chan error, 2)
+	go func() { errCh <- r.streamTraffic(ctx, api) }()
+	go func() { errCh <- r.watchConnections(ctx, api) }()
+	err := <-errCh
+	cancel()
+	<-errCh
+
+	r.mu.Lock()
+	r.recording = false
+	r.mu.Unlock()
+	if err != nil && !errors.Is(err, context.Canceled) {
+		r.setError(err)
+	}
+}
+
+// streamTraffic reads the per-second {"up","down"} lines of /traffic.
+func (r *StatsRecorder) streamTraffic(ctx context.Context, api ClashAPI) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api.Base+"/traffic", nil)
+	if err != nil {
+		return err
+	}
+	if api.Secret != "" {
+		req.Header.Set("Authorization", "Bearer "+api.Secret)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("traffic: %s", resp.Status)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var tick struct {
+			Up   int64 `json:"up"`
+			Down int64 `json:"down"`
+		}
+		if err := decoder.Decode(&tick); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("traffic: %w", err)
+		}
+		r.add(time.Now(), func(sample *statsSample) {
+			sample.traffic.up += tick.Up
+			sample.traffic.down += tick.Down
+		})
+	}
+}
+
+// watchConnections turns /connections snapshots into per-outbound and per-rule byte deltas, and
+// accounts connections that disappear from a snapshot as closed. Bytes a connection moves after
+// its last snapshot and before it closes are not attributed.
+func (r *StatsRecorder) watchConnections(ctx context.Context, api ClashAPI) error {
+	wsURL, err := url.Parse(api.Base + "/connections")
+	if err != nil {
+		return err
+	}
+	if wsURL.Scheme == "https" {
+		wsURL.Scheme = "wss"
+	} else {
+		wsURL.Scheme = "ws"
+	}
+	wsURL.RawQuery = fmt.Sprintf("interval=%d", statsSnapshotMillis)
+
+	header := http.Header{}
+	if api.Secret != "" {
+		header.Set("Authorization", "Bearer "+api.Secret)
+	}
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL.String(), header)
+	if err != nil {
+		return fmt.Errorf("connections: %w", err)
+	}
+	defer conn.Close()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	// The first snapshot only sets the baseline, its bytes predate this session
+	var seen map[string]coreConnection
+	for {
+		var snapshot coreConnectionsSnapshot
+		if err := conn.ReadJSON(&snapshot); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("connections: %w", err)
+		}
+
+		current := make(map[string]coreConnection, len(snapshot.Connections))
+		for _, c := range snapshot.Connections {
+			current[c.ID] = c
+		}
+		r.mu.Lock()
+		r.open = len(current)
+		r.mu.Unlock()
+		if seen == nil {
+			seen = current
+			continue
+		}
+		r.add(time.Now(), func(sample *statsSample) {
+			for _, c := range snapshot.Connections {
+				last := seen[c.ID]
+				delta := statsCounter{up: c.Upload - last.Upload, down: c.Download - last.Download}
+				if delta.up <= 0 && delta.down <= 0 {
+					continue
+				}
+				outbound := "unknown"
+				if len(c.Chains) > 0 {
+					outbound = c.Chains[0]
+				}
+				addStatsCounter(sample.outbounds, outbound, delta)
+				addStatsCounter(sample.rules, c.Rule, delta)
+			}
+			for id, c := range seen {
+				if _, ok := current[id]; !ok {
+					addUsage(sample, c)
+				}
+			}
+		})
+		seen = current
+	}
+}
+
+func addStatsCounter(counters map[string]*statsCounter, name string, delta statsCounter) {
+	counter, ok := counters[name]
+	if !ok {
+		counter = &statsCounter{}
+		counters[name] = counter
+	}
+	counter.up += max(delta.up, 0)
+	counter.down += max(delta.down, 0)
+	counter.count += delta.count
+}
+
+func (r *StatsRecorder) add(at time.Time, update func(sample *statsSample)) {
+	minute := at.Truncate(time.Minute).Unix()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sample, ok := r.pending[minute]
+	if !ok {
+		sample = newStatsSample()
+		r.pending[minute] = sample
+	}
+	update(sample)
+	// Updates only add, so replaying them keeps the running totals; hosts and clients would
+	// grow without bound
+	update(r.totals)
+	clear(r.totals.usage)
+}
+
+func newStatsSample() *statsSample {
+	return &statsSample{
+		outbounds: make(map[string]*statsCounter),
+		rules:     make(map[string]*statsCounter),
+		usage:     make(map[string]map[string]*statsCounter),
+	}
+}
+
+func (r *StatsRecorder) setError(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastError = err.Error()
+}
+
+// flush adds the pending samples onto the stored counters of every resolution.
+func (r *StatsRecorder) flush() error {
+	r.mu.Lock()
+	db := r.db
+	pending := r.pending
+	r.pending = make(map[int64]*statsSample)
+	r.mu.Unlock()
+
+	if db == nil || len(pending) == 0 {
+		return nil
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		for _, res := range statsResolutions {
+			root, err := tx.CreateBucketIfNotExists([]byte(res.name))
+			if err != nil {
+				return err
+			}
+			buckets := make(map[string]*bbolt.Bucket)
+			for _, name := range statsBucketNames() {
+				bucket, err := root.CreateBucketIfNotExists(name)
+				if err != nil {
+					return err
+				}
+				buckets[string(name)] = bucket
+			}
+			for minute, sample := range pending {
+				slot := time.Unix(minute, 0).Truncate(res.size).Unix()
+				if err := addStatsValue(buckets[string(statsTrafficBucket)], statsKey(slot, ""), sample.traffic); err != nil {
+					return err
+				}
+				counters := map[string]map[string]*statsCounter{
+					string(statsOutboundBucket): sample.outbounds,
+					string(statsRuleBucket):     sample.rules,
+				}
+				for by, values := range sample.usage {
+					counters[string(usageBucket(by))] = values
+				}
+				for bucket, values := range counters {
+					for name, counter := range values {
+						if err := addStatsValue(buckets[bucket], statsKey(slot, name), *counter); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// prune drops samples older than each resolution's retention.
+func (r *StatsRecorder) prune(settings StatsSettings) error {
+	r.mu.Lock()
+	db := r.db
+	r.mu.Unlock()
+
+	if db == nil {
+		return nil
+	}
+
+	return db.Update(func(tx *bbolt.Tx) error {
+		for _, res := range statsResolutions {
+			days := res.retention(settings)
+			root := tx.Bucket([]byte(res.name))
+			if days <= 0 || root == nil {
+				continue
+			}
+			cutoff := time.Now().AddDate(0, 0, -days).Unix()
+			for _, name := range statsBucketNames() {
+				bucket := root.Bucket(name)
+				if bucket == nil {
+					continue
+				}
+				var expired [][]byte
+				c := bucket.Cursor()
+				for k, _ := c.First(); k != nil; k, _ = c.Next() {
+					if statsKeyTime(k) >= cutoff {
+						break
+					}
+					expired = append(expired, k)
+				}
+				for _, k := range expired {
+					if err := bucket.Delete(k); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// statsWindow is a query range aligned to its step, read from one resolution.
+type statsWindow struct {
+	start, to time.Time
+	step      time.Duration
+	res       statsResolution
+}
+
+// window flushes pending samples and resolves a from/to/step query against the store.
+func (r *StatsRecorder) window(fromMillis, toMillis int64, step time.Duration) (*bbolt.DB, statsWindow, error) {
+	if err := r.flush(); err != nil {
+		return nil, statsWindow{}, err
+	}
+
+	r.mu.Lock()
+	db := r.db
+	settings := r.settings
+	r.mu.Unlock()
+
+	if db == nil {
+		return nil, statsWindow{}, errors.New("the traffic history is not available")
+	}
+
+	to := time.Now()
+	if toMillis > 0 {
+		to = time.UnixMilli(toMillis)
+	}
+	from := to.Add(-statsDefaultRange)
+	if fromMillis > 0 {
+		from = time.UnixMilli(fromMillis)
+	}
+	if !from.Before(to) {
+		return nil, statsWindow{}, errors.New("from must be before to")
+	}
+
+	if step <= 0 {
+		step = to.Sub(from) / statsDefaultMaxSteps
+	}
+
+	// Hour samples are used when the step allows it or the minute samples have already expired
+	res := statsResolutions[0]
+	minuteCutoff := time.Now().AddDate(0, 0, -settings.MinuteRetention)
+	if step >= time.Hour || (settings.MinuteRetention > 0 && from.Before(minuteCutoff)) {
+		res = statsResolutions[1]
+	}
+	step = max(res.size, (step + res.size - 1).Truncate(res.size))
+	if to.Sub(from)/step > statsMaxPoints {
+		return nil, statsWindow{}, fmt.Errorf("the range would produce more than %d points, use a larger step", statsMaxPoints)
+	}
+	return db, statsWindow{start: from.Truncate(step), to: to, step: step, res: res}, nil
+}
+
+// slots returns the start of every step in the window.
+func (w statsWindow) slots() []time.Time {
+	var slots []time.Time
+	for t := w.start; t.Before(w.to); t = t.Add(w.step) {
+		slots = append(slots, t)
+	}
+	return slots
+}
+
+// index returns the step a stored sample falls in.
+func (w statsWindow) index(slot int64) int {
+	return int((slot - w.start.Unix()) / int64(w.step/time.Second))
+}
+
+// QueryTraffic sums stored samples into step-sized points between query.From and query.To, and
+// totals the outbound and rule counters over the same range.
+func (r *StatsRecorder) QueryTraffic(query TrafficQuery) (TrafficHistory, error) {
+	db, window, err := r.window(query.From, query.To, query.Step)
+	if err != nil {
+		return TrafficHistory{}, err
+	}
+
+	history := TrafficHistory{
+		From:       window.start.UnixMilli(),
+		To:         window.to.UnixMilli(),
+		Step:       int64(window.step / time.Second),
+		Resolution: window.res.name,
+		Points:     []TrafficPoint{},
+		Outbounds:  []TrafficCounter{},
+		Rules:      []TrafficCounter{},
+	}
+	for _, t := range window.slots() {
+		history.Points = append(history.Points, TrafficPoint{Time: t.UnixMilli()})
+	}
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		root := tx.Bucket([]byte(window.res.name))
+		if root == nil {
+			return nil
+		}
+		lo, hi := window.start.Unix(), window.to.Unix()
+		scanStatsBucket(root.Bucket(statsTrafficBucket), lo, hi, func(slot int64, _ string, counter statsCounter) {
+			point := &history.Points[window.index(slot)]
+			point.Up += counter.up
+			point.Down += counter.down
+		})
+		history.Outbounds = sumStatsBucket(root.Bucket(statsOutboundBucket), lo, hi)
+		history.Rules = sumStatsBucket(root.Bucket(statsRuleBucket), lo, hi)
+		return nil
+	})
+	return history, err
+}
+
+func scanStatsBucket(bucket *bbolt.Bucket, from, to int64, fn func(slot int64, name string, counter statsCounter)) {
+	if bucket == nil {
+		return
+	}
+	c := bucket.Cursor()
+	for k, v := c.Seek(statsKey(from, "")); k != nil; k, v = c.Next() {
+		slot := statsKeyTime(k)
+		if slot >= to {
+			break
+		}
+		if len(v) != 24 {
+			continue
+		}
+		fn(slot, string(k[8:]), decodeStatsValue(v))
+	}
+}
+
+func sumStatsBucket(bucket *bbolt.Bucket, from, to int64) []TrafficCounter {
+	totals := totalStatsBucket(bucket, from, to)
+	counters := make([]TrafficCounter, 0, len(totals))
+	for name, counter := range totals {
+		counters = append(counters, TrafficCounter{Name: name, Up: counter.up, Down: counter.down})
+	}
+	slices.SortFunc(counters, func(a, b TrafficCounter) int {
+		return compareStatsTotals(a.Up+a.Down, b.Up+b.Down, a.Name, b.Name)
+	})
+	return counters
+}
+
+func totalStatsBucket(bucket *bbolt.Bucket, from, to int64) map[string]*statsCounter {
+	totals := make(map[string]*statsCounter)
+	scanStatsBucket(bucket, from, to, func(_ int64, name string, counter statsCounter) {
+		addStatsCounter(totals, name, counter)
+	})
+	return totals
+}
+
+// compareStatsTotals orders by bytes, largest first, then by name.
+func compareStatsTotals(a, b int64, nameA, nameB string) int {
+	if a != b {
+		if a > b {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(nameA, nameB)
+}
+
+// statsKey orders samples by time: an 8 byte big-endian unix second followed by the counter name.
+func statsKey(slot int64, name string) []byte {
+	key := make([]byte, 8, 8+len(name))
+	binary.BigEndian.PutUint64(key, uint64(slot))
+	return append(key, name...)
+}
+
+func statsKeyTime(key []byte) int64 {
+	if len(key) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(key[:8]))
+}
+
+// addStatsValue adds counter onto the stored value, three big-endian uint64: up, down, count.
+func addStatsValue(bucket *bbolt.Bucket, key []byte, counter statsCounter) error {
+	if counter.up == 0 && counter.down == 0 && counter.count == 0 {
+		return nil
+	}
+	if v := bucket.Get(key); len(v) == 24 {
+		stored := decodeStatsValue(v)
+		counter.up += stored.up
+		counter.down += stored.down
+		counter.count += stored.count
+	}
+	value := make([]byte, 24)
+	binary.BigEndian.PutUint64(value[:8], uint64(counter.up))
+	binary.BigEndian.PutUint64(value[8:16], uint64(counter.down))
+	binary.BigEndian.PutUint64(value[16:], uint64(counter.count))
+	return bucket.Put(key, value)
+}
+
+func decodeStatsValue(v []byte) statsCounter {
+	return statsCounter{
+		up:    int64(binary.BigEndian.Uint64(v[:8])),
+		down:  int64(binary.BigEndian.Uint64(v[8:16])),
+		count: int64(binary.BigEndian.Uint64(v[16:])),
+	}
+}
+
+func statsBucketNames() [][]byte {
+	names := [][]byte{statsTrafficBucket, statsOutboundBucket, statsRuleBucket}
+	for _, by := range usageDimensions {
+		names = append(names, usageBucket(by))
+	}
+	return names
+}
+
+func loadStatsSettings() StatsSettings {
+	settings := StatsSettings{}
+	b, err := os.ReadFile(GetPath(StatsSettingsFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	if settings.MinuteRetention == 0 {
+		settings.MinuteRetention = 7
+	}
+	if settings.HourRetention == 0 {
+		settings.HourRetention = 365
+	}
+	return settings
+}
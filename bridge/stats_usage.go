@@ -0,0 +1,277 @@
+package bridge
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.etcd.io/bbolt"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ClientNamesFilePath = "data/clients.yaml"
+
+	UsageByClient  = "client"
+	UsageByHost    = "host"
+	UsageByInbound = "inbound"
+	UsageByChain   = "chain"
+	UsageByRule    = "rule"
+
+	defaultUsageLimit = 10
+)
+
+var usageDimensions = []string{UsageByClient, UsageByHost, UsageByInbound, UsageByChain, UsageByRule}
+
+// ClientName labels a LAN device, matched by exact address or by CIDR prefix.
+type ClientName struct {
+	Address string `yaml:"address" json:"address"`
+	Name    string `yaml:"name" json:"name"`
+}
+
+type UsageQuery struct {
+	By    string
+	From  int64         // unix milliseconds, defaults to To minus one day
+	To    int64         // unix milliseconds, defaults to now
+	Step  time.Duration // zero returns totals only
+	Limit int           // top-N items, 10 when zero, every item when negative
+}
+
+type UsagePoint struct {
+	Time        int64 `json:"time"`
+	Up          int64 `json:"up"`
+	Down        int64 `json:"down"`
+	Connections int64 `json:"connections"`
+}
+
+type UsageItem struct {
+	Key         string       `json:"key"`
+	Name        string       `json:"name"` // client name from clients.yaml
+	Up          int64        `json:"up"`
+	Down        int64        `json:"down"`
+	Connections int64        `json:"connections"`
+	Points      []UsagePoint `json:"points,omitempty"`
+}
+
+type UsageReport struct {
+	By         string      `json:"by"`
+	From       int64       `json:"from"`
+	To         int64       `json:"to"`
+	Step       int64       `json:"step"` // seconds, zero for totals only
+	Resolution string      `json:"resolution"`
+	Items      []UsageItem `json:"items"`
+}
+
+func usageBucket(by string) []byte {
+	return []byte("usage:" + by)
+}
+
+// addUsage accounts a closed connection under each usage dimension.
+func addUsage(sample *statsSample, c coreConnection) {
+	host := c.Metadata.Host
+	if host == "" {
+		host = c.Metadata.DestinationIP
+	}
+	chain := slices.Clone(c.Chains)
+	slices.Reverse(chain)
+	keys := map[string]string{
+		UsageByClient:  c.Metadata.SourceIP,
+		UsageByHost:    host,
+		UsageByInbound: c.Metadata.Type,
+		UsageByChain:   strings.Join(chain, " > "),
+		UsageByRule:    c.Rule,
+	}
+	delta := statsCounter{up: c.Upload, down: c.Download, count: 1}
+	for by, key := range keys {
+		if key == "" {
+			key = "unknown"
+		}
+		counters, ok := sample.usage[by]
+		if !ok {
+			counters = make(map[string]*statsCounter)
+			sample.usage[by] = counters
+		}
+		addStatsCounter(counters, key, delta)
+	}
+}
+
+// QueryUsage ranks closed connections by one dimension over a range, and splits the top items
+// into step-sized points when query.Step is set.
+func (r *StatsRecorder) QueryUsage(query UsageQuery) (UsageReport, error) {
+	if !slices.Contains(usageDimensions, query.By) {
+		return UsageReport{}, errors.New("unknown usage dimension: " + query.By)
+	}
+	db, window, err := r.window(query.From, query.To, query.Step)
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	report := UsageReport{
+		By:         query.By,
+		From:       window.start.UnixMilli(),
+		To:         window.to.UnixMilli(),
+		Resolution: window.res.name,
+		Items:      []UsageItem{},
+	}
+	if query.Step > 0 {
+		report.Step = int64(window.step / time.Second)
+	}
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultUsageLimit
+	}
+
+	err = db.View(func(tx *bbolt.Tx) error {
+		root := tx.Bucket([]byte(window.res.name))
+		if root == nil {
+			return nil
+		}
+		bucket := root.Bucket(usageBucket(query.By))
+		lo, hi := window.start.Unix(), window.to.Unix()
+
+		for key, counter := range totalStatsBucket(bucket, lo, hi) {
+			report.Items = append(report.Items, UsageItem{
+				Key:         key,
+				Up:          counter.up,
+				Down:        counter.down,
+				Connections: counter.count,
+			})
+		}
+		slices.SortFunc(report.Items, func(a, b UsageItem) int {
+			return compareStatsTotals(a.Up+a.Down, b.Up+b.Down, a.Key, b.Key)
+		})
+		if limit > 0 && len(report.Items) > limit {
+			report.Items = report.Items[:limit]
+		}
+		if query.Step <= 0 {
+			return nil
+		}
+
+		items := make(map[string]*UsageItem, len(report.Items))
+		slots := window.slots()
+		for i := range report.Items {
+			item := &report.Items[i]
+			item.Points = make([]UsagePoint, len(slots))
+			for j, t := range slots {
+				item.Points[j].Time = t.UnixMilli()
+			}
+			items[item.Key] = item
+		}
+		scanStatsBucket(bucket, lo, hi, func(slot int64, key string, counter statsCounter) {
+			if item, ok := items[key]; ok {
+				point := &item.Points[window.index(slot)]
+				point.Up += counter.up
+				point.Down += counter.down
+				point.Connections += counter.count
+			}
+		})
+		return nil
+	})
+	if err != nil {
+		return UsageReport{}, err
+	}
+
+	if query.By == UsageByClient {
+		names := loadClientNames()
+		for i := range report.Items {
+			report.Items[i].Name = names.lookup(report.Items[i].Key)
+		}
+	}
+	return report, nil
+}
+
+// WriteUsageCSV writes one row per item, or one row per item and point for a stepped report.
+func WriteUsageCSV(w io.Writer, report UsageReport) error {
+	out := csv.NewWriter(w)
+	header := []string{report.By, "name", "upload", "download", "connections"}
+	if report.Step > 0 {
+		header = append([]string{"time"}, header...)
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	for _, item := range report.Items {
+		if report.Step <= 0 {
+			_ = out.Write([]string{item.Key, item.Name, formatInt(item.Up), formatInt(item.Down), formatInt(item.Connections)})
+			continue
+		}
+		for _, point := range item.Points {
+			_ = out.Write([]string{
+				time.UnixMilli(point.Time).Format(time.RFC3339),
+				item.Key, item.Name,
+				formatInt(point.Up), formatInt(point.Down), formatInt(point.Connections),
+			})
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+func formatInt(v int64) string {
+	return strconv.FormatInt(v, 10)
+}
+
+type clientNames []ClientName
+
+func (names clientNames) lookup(address string) string {
+	addr, err := netip.ParseAddr(address)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	// Exact addresses win over prefixes, and longer prefixes over shorter ones
+	best, bestBits := "", -1
+	for _, client := range names {
+		if a, err := netip.ParseAddr(client.Address); err == nil && a.Unmap() == addr {
+			return client.Name
+		}
+		if prefix, err := netip.ParsePrefix(client.Address); err == nil && prefix.Contains(addr) && prefix.Bits() > bestBits {
+			best, bestBits = client.Name, prefix.Bits()
+		}
+	}
+	return best
+}
+
+func loadClientNames() clientNames {
+	var names clientNames
+	b, err := os.ReadFile(GetPath(ClientNamesFilePath))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &names)
+	}
+	return names
+}
+
+func (a *App) ListClientNames() []ClientName {
+	slog.Info("ListClientNames")
+
+	names := loadClientNames()
+	if names == nil {
+		return []ClientName{}
+	}
+	return names
+}
+
+func (a *App) SaveClientNames(names []ClientName) error {
+	slog.Info("SaveClientNames", "count", len(names))
+
+	for _, client := range names {
+		if _, err := netip.ParseAddr(client.Address); err == nil {
+			continue
+		}
+		if _, err := netip.ParsePrefix(client.Address); err != nil {
+			return errors.New("invalid client address: " + client.Address)
+		}
+	}
+	b, err := yaml.Marshal(names)
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(GetPath(ClientNamesFilePath), b, 0644)
+}
@@ -0,0 +1,476 @@
+package bridge
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	SubscribesFilePath = "data/subscribes.yaml"
+
+	SubscriptionFormatJSON   = "json"
+	SubscriptionFormatYAML   = "yaml"
+	SubscriptionFormatBase64 = "base64"
+	SubscriptionFormatLinks  = "links"
+	SubscriptionFormatManual = "manual"
+
+	maxSubscriptionSize = 32 << 20
+)
+
+var (
+	subscribesFileMu sync.Mutex
+
+	subscriptionUpdatingMu sync.Mutex
+	subscriptionUpdating   = make(map[string]bool)
+)
+
+type SubscriptionProxy struct {
+	ID   string `yaml:"id" json:"id"`
+	Tag  string `yaml:"tag" json:"tag"`
+	Type string `yaml:"type" json:"type"`
+}
+
+type SubscriptionHeader struct {
+	Request  map[string]string `yaml:"request" json:"request"`
+	Response map[string]string `yaml:"response" json:"response"`
+}
+
+// Subscription mirrors the fields of data/subscribes.yaml that the server reads; unknown
+// fields (script, website ...) are left untouched when the file is updated.
+type Subscription struct {
+	ID              string              `yaml:"id" json:"id"`
+	Name            string              `yaml:"name" json:"name"`
+	Upload          int64               `yaml:"upload" json:"upload"`
+	Download        int64               `yaml:"download" json:"download"`
+	Total           int64               `yaml:"total" json:"total"`
+	Expire          int64               `yaml:"expire" json:"expire"`
+	UpdateTime      int64               `yaml:"updateTime" json:"updateTime"`
+	Type            string              `yaml:"type" json:"type"` // Http / File / Manual
+	URL             string              `yaml:"url" json:"url"`
+	Path            string              `yaml:"path" json:"path"`
+	Include         string              `yaml:"include" json:"include"`
+	Exclude         string              `yaml:"exclude" json:"exclude"`
+	IncludeProtocol string              `yaml:"includeProtocol" json:"includeProtocol"`
+	ExcludeProtocol string              `yaml:"excludeProtocol" json:"excludeProtocol"`
+	ProxyPrefix     string              `yaml:"proxyPrefix" json:"proxyPrefix"`
+	Disabled        bool                `yaml:"disabled" json:"disabled"`
+	InSecure        bool                `yaml:"inSecure" json:"inSecure"`
+	RequestMethod   string              `yaml:"requestMethod" json:"requestMethod"`
+	RequestTimeout  int                 `yaml:"requestTimeout" json:"requestTimeout"`
+	Header          SubscriptionHeader  `yaml:"header" json:"header"`
+	Proxies         []SubscriptionProxy `yaml:"proxies" json:"proxies"`
+}
+
+type SubscriptionUpdateResult struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Format     string   `json:"format"`
+	Count      int      `json:"count"`
+	Skipped    []string `json:"skipped"`
+	Upload     int64    `json:"upload"`
+	Download   int64    `json:"download"`
+	Total      int64    `json:"total"`
+	Expire     int64    `json:"expire"`
+	UpdateTime int64    `json:"updateTime"`
+}
+
+func (a *App) ListSubscriptions() ([]Subscription, error) {
+	slog.Info("ListSubscriptions")
+
+	return readSubscriptions()
+}
+
+// UpdateSubscription fetches and parses one subscription, writes its outbounds to the
+// subscription path and records traffic, expiry and the proxy list in subscribes.yaml.
+func (a *App) UpdateSubscription(id string) (SubscriptionUpdateResult, error) {
+	slog.Info("UpdateSubscription", "id", id)
+
+	subs, err := readSubscriptions()
+	if err != nil {
+		return SubscriptionUpdateResult{}, err
+	}
+	var sub *Subscription
+	for i := range subs {
+		if subs[i].ID == id {
+			sub = &subs[i]
+			break
+		}
+	}
+	if sub == nil {
+		return SubscriptionUpdateResult{}, errors.New(id + " Not Found")
+	}
+	if sub.Disabled {
+		return SubscriptionUpdateResult{}, errors.New(sub.Name + " Disabled")
+	}
+
+	subscriptionUpdatingMu.Lock()
+	if subscriptionUpdating[id] {
+		subscriptionUpdatingMu.Unlock()
+		return SubscriptionUpdateResult{}, errors.New(sub.Name + " is already updating")
+	}
+	subscriptionUpdating[id] = true
+	subscriptionUpdatingMu.Unlock()
+	defer func() {
+		subscriptionUpdatingMu.Lock()
+		delete(subscriptionUpdating, id)
+		subscriptionUpdatingMu.Unlock()
+	}()
+
+	result, err := updateSubscription(sub)
+	if err != nil {
+		if a.Bus != nil {
+			a.Bus.Emit("subscription::failed", UpdateFailure{ID: sub.ID, Name: sub.Name, Error: err.Error()})
+		}
+		return result, err
+	}
+
+	if a.Bus != nil {
+		a.Bus.Emit("subscription::updated", result)
+	}
+
+	return result, nil
+}
+
+func updateSubscription(sub *Subscription) (SubscriptionUpdateResult, error) {
+	result := SubscriptionUpdateResult{ID: sub.ID, Name: sub.Name, Skipped: []string{}}
+
+	var body []byte
+	var header http.Header
+	var err error
+
+	switch sub.Type {
+	case "Manual":
+		body, err = os.ReadFile(GetPath(sub.Path))
+	case "File":
+		body, err = os.ReadFile(GetPath(sub.URL))
+	case "Http":
+		body, header, err = fetchSubscription(sub)
+	default:
+		err = errors.New("unknown subscription type: " + sub.Type)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	format, outbounds, skipped, err := ParseSubscription(body)
+	if err != nil && sub.Type == "Manual" {
+		format = SubscriptionFormatManual
+		err = json.Unmarshal(body, &outbounds)
+	}
+	if err != nil {
+		return result, errors.New("Not a valid subscription data")
+	}
+	result.Format = format
+	result.Skipped = skipped
+
+	if sub.Type != "Manual" {
+		if outbounds, err = filterSubscriptionOutbounds(sub, outbounds); err != nil {
+			return result, err
+		}
+	}
+
+	info := parseSubscriptionUserinfo(header, sub.Header.Response)
+	result.Upload = info["upload"]
+	result.Download = info["download"]
+	result.Total = info["total"]
+	result.Expire = info["expire"] * 1000
+	result.UpdateTime = time.Now().UnixMilli()
+	result.Count = len(outbounds)
+
+	proxies := make([]SubscriptionProxy, 0, len(outbounds))
+	for _, outbound := range outbounds {
+		tag := anyToString(outbound["tag"])
+		proxies = append(proxies, SubscriptionProxy{
+			ID:   subscriptionProxyID(sub.Proxies, tag),
+			Tag:  tag,
+			Type: anyToString(outbound["type"]),
+		})
+	}
+
+	b, err := json.MarshalIndent(outbounds, "", "  ")
+	if err != nil {
+		return result, err
+	}
+	if err := WriteFileAtomic(GetPath(sub.Path), b, 0644); err != nil {
+		return result, err
+	}
+
+	if err := updateSubscriptionEntry(sub.ID, result, proxies); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// ParseSubscription sniffs the body the same way the panel does (sing-box JSON, Clash YAML,
+// then base64) and also accepts a plain list of share links.
+func ParseSubscription(body []byte) (string, []map[string]any, []string, error) {
+	var singbox struct {
+		Outbounds []map[string]any `json:"outbounds"`
+	}
+	if json.Unmarshal(body, &singbox) == nil && singbox.Outbounds != nil {
+		return SubscriptionFormatJSON, singbox.Outbounds, []string{}, nil
+	}
+
+	var clash struct {
+		Proxies []any `yaml:"proxies"`
+	}
+	if yaml.Unmarshal(body, &clash) == nil && clash.Proxies != nil {
+		outbounds := []map[string]any{}
+		skipped := []string{}
+		for _, item := range ConvertClashProxies(clash.Proxies) {
+			if item.Error != "" {
+				skipped = append(skipped, item.Name+": "+item.Error)
+				continue
+			}
+			outbounds = append(outbounds, item.Outbound)
+		}
+		return SubscriptionFormatYAML, outbounds, skipped, nil
+	}
+
+	text := strings.TrimSpace(string(body))
+	if !strings.Contains(text, "://") {
+		if decoded, err := decodeBase64String(text); err == nil && strings.Contains(string(decoded), "://") {
+			outbounds, skipped := ParseShareLinks(string(decoded))
+			return SubscriptionFormatBase64, outbounds, skipped, nil
+		}
+	} else {
+		outbounds, skipped := ParseShareLinks(text)
+		if len(outbounds) > 0 {
+			return SubscriptionFormatLinks, outbounds, skipped, nil
+		}
+	}
+
+	return "", nil, nil, errors.New("Not a valid subscription data")
+}
+
+func fetchSubscription(sub *Subscription) ([]byte, http.Header, error) {
+	method := sub.RequestMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	client, ctx, cancel := withRequestOptionsClient(RequestOptions{
+		Proxy:    loadDownloadProxy(),
+		Insecure: sub.InSecure,
+		Redirect: true,
+		Timeout:  sub.RequestTimeout,
+	})
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, sub.URL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = GetHeader(sub.Header.Request)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, nil, errors.New("subscription server responded " + resp.Status)
+	}
+
+	body, err := readAllLimited(resp.Body, maxSubscriptionSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Header, nil
+}
+
+func filterSubscriptionOutbounds(sub *Subscription, outbounds []map[string]any) ([]map[string]any, error) {
+	patterns := []string{sub.Include, sub.Exclude, sub.IncludeProtocol, sub.ExcludeProtocol}
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.New("invalid filter " + strconv.Quote(pattern) + ": " + err.Error())
+		}
+		compiled[i] = re
+	}
+	include, exclude, includeProtocol, excludeProtocol := compiled[0], compiled[1], compiled[2], compiled[3]
+
+	filtered := []map[string]any{}
+	seen := make(map[string]int)
+	for _, outbound := range outbounds {
+		tag := anyToString(outbound["tag"])
+		outboundType := anyToString(outbound["type"])
+		if include != nil && !include.MatchString(tag) ||
+			exclude != nil && exclude.MatchString(tag) ||
+			includeProtocol != nil && !includeProtocol.MatchString(outboundType) ||
+			excludeProtocol != nil && excludeProtocol.MatchString(outboundType) {
+			continue
+		}
+
+		if sub.ProxyPrefix != "" && !strings.HasPrefix(tag, sub.ProxyPrefix) {
+			tag = sub.ProxyPrefix + tag
+		}
+		// The core refuses duplicate tags, which are common in merged link lists
+		if n := seen[tag]; n > 0 {
+			seen[tag] = n + 1
+			tag += " " + strconv.Itoa(n+1)
+		} else {
+			seen[tag] = 1
+		}
+		outbound["tag"] = tag
+
+		filtered = append(filtered, outbound)
+	}
+	return filtered, nil
+}
+
+// parseSubscriptionUserinfo reads "upload=1; download=2; total=3; expire=4", letting the
+// subscription's configured response headers override the server's.
+func parseSubscriptionUserinfo(header http.Header, overrides map[string]string) map[string]int64 {
+	merged := http.Header{}
+	for key, values := range header {
+		merged[key] = values
+	}
+	for key, value := range overrides {
+		merged.Set(key, value)
+	}
+
+	info := make(map[string]int64)
+	for _, part := range strings.Split(merged.Get("Subscription-Userinfo"), ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			f, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			n = int64(f)
+		}
+		info[strings.TrimSpace(key)] = n
+	}
+	return info
+}
+
+// subscriptionProxyID keeps the existing ID of a proxy so groups referencing it stay intact.
+func subscriptionProxyID(proxies []SubscriptionProxy, tag string) string {
+	for _, proxy := range proxies {
+		if proxy.Tag == tag {
+			return proxy.ID
+		}
+	}
+	return sampleID()
+}
+
+// sampleID matches the "ID_xxxxxxxx" identifiers generated by the panel.
+func sampleID() string {
+	const alphabet = "0123456789abcdefghijklmnopqrstuvwxyz"
+	id := make([]byte, 8)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "ID_" + strconv.FormatInt(time.Now().UnixNano(), 36)
+		}
+		id[i] = alphabet[n.Int64()]
+	}
+	return "ID_" + string(id)
+}
+
+func readSubscriptions() ([]Subscription, error) {
+	b, err := os.ReadFile(GetPath(SubscribesFilePath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Subscription{}, nil
+		}
+		return nil, err
+	}
+	subs := []Subscription{}
+	if err := yaml.Unmarshal(b, &subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+func updateSubscriptionEntry(id string, result SubscriptionUpdateResult, proxies []SubscriptionProxy) error {
+	subscribesFileMu.Lock()
+	defer subscribesFileMu.Unlock()
+
+	path := GetPath(SubscribesFilePath)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.SequenceNode {
+		return errors.New("unexpected subscriptions format")
+	}
+
+	for _, item := range doc.Content[0].Content {
+		if item.Kind != yaml.MappingNode || yamlMappingValue(item, "id") != id {
+			continue
+		}
+		for _, field := range []struct {
+			key   string
+			value int64
+		}{
+			{"upload", result.Upload},
+			{"download", result.Download},
+			{"total", result.Total},
+			{"expire", result.Expire},
+			{"updateTime", result.UpdateTime},
+		} {
+			setYAMLMappingNode(item, field.key, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.FormatInt(field.value, 10)})
+		}
+		var proxiesNode yaml.Node
+		if err := proxiesNode.Encode(proxies); err != nil {
+			return err
+		}
+		setYAMLMappingNode(item, "proxies", &proxiesNode)
+		return writeYAMLNode(path, &doc)
+	}
+
+	return errors.New(id + " Not Found")
+}
+
+func loadDownloadProxy() string {
+	var settings struct {
+		DownloadProxy string `yaml:"downloadProxy"`
+	}
+	b, err := os.ReadFile(GetPath("data/user.yaml"))
+	if err == nil {
+		_ = yaml.Unmarshal(b, &settings)
+	}
+	return strings.TrimSpace(settings.DownloadProxy)
+}
+
+func (a *App) subscriptionTaskRunner(_ context.Context, task ScheduledTask) ([]string, error) {
+	output := []string{}
+	var errs []error
+	for _, id := range task.Subscriptions {
+		result, err := a.UpdateSubscription(id)
+		if err != nil {
+			output = append(output, err.Error())
+			errs = append(errs, err)
+			continue
+		}
+		output = append(output, "Subscription ["+result.Name+"] updated successfully.")
+	}
+	return output, errors.Join(errs...)
+}
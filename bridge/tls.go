@@ -0,0 +1,457 @@
+package bridge
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	CertificatesDirectory = "data/certs"
+
+	KeyTypeECDSA   = "ecdsa"
+	KeyTypeRSA     = "rsa"
+	KeyTypeEd25519 = "ed25519"
+
+	defaultCertificateDays = 365
+	defaultCADays          = 3650
+)
+
+type TLSCertificateOptions struct {
+	Name       string   `json:"name"`       // file name without extension
+	Dir        string   `json:"dir"`        // defaults to data/certs
+	CommonName string   `json:"commonName"` // defaults to the first SAN
+	SANs       []string `json:"sans"`       // DNS names and IP addresses
+	Days       int      `json:"days"`
+	KeyType    string   `json:"keyType"` // ecdsa / rsa / ed25519
+	CA         string   `json:"ca"`      // name of the CA in Dir, when issuing a leaf
+	Overwrite  bool     `json:"overwrite"`
+}
+
+type ECHKeyOptions struct {
+	Name       string `json:"name"`
+	Dir        string `json:"dir"`
+	PublicName string `json:"publicName"` // the outer server name clients connect with
+	Overwrite  bool   `json:"overwrite"`
+}
+
+type CertificateInfo struct {
+	Subject            string   `json:"subject"`
+	Issuer             string   `json:"issuer"`
+	SerialNumber       string   `json:"serialNumber"`
+	DNSNames           []string `json:"dnsNames"`
+	IPAddresses        []string `json:"ipAddresses"`
+	NotBefore          int64    `json:"notBefore"`
+	NotAfter           int64    `json:"notAfter"`
+	DaysLeft           int      `json:"daysLeft"`
+	Expired            bool     `json:"expired"`
+	IsCA               bool     `json:"isCA"`
+	SelfSigned         bool     `json:"selfSigned"`
+	KeyType            string   `json:"keyType"`
+	SignatureAlgorithm string   `json:"signatureAlgorithm"`
+	SHA256Fingerprint  string   `json:"sha256Fingerprint"`
+}
+
+type TLSCertificateResult struct {
+	CertPath    string          `json:"certPath"`
+	KeyPath     string          `json:"keyPath"`
+	Certificate CertificateInfo `json:"certificate"`
+}
+
+type ECHKeyResult struct {
+	ConfigPath string `json:"configPath"`
+	KeyPath    string `json:"keyPath"`
+	Config     string `json:"config"` // PEM "ECH CONFIGS", for the client tls.ech.config
+}
+
+// CreateSelfSignedCertificate writes <name>.crt and <name>.key for a certificate signed by itself.
+func (a *App) CreateSelfSignedCertificate(options TLSCertificateOptions) (TLSCertificateResult, error) {
+	slog.Info("CreateSelfSignedCertificate", "name", options.Name, "sans", options.SANs)
+
+	options.CA = ""
+	return createCertificate(options, false)
+}
+
+// CreateCertificateAuthority writes <name>.crt and <name>.key for a private CA that
+// IssueCertificate can sign leaf certificates with.
+func (a *App) CreateCertificateAuthority(options TLSCertificateOptions) (TLSCertificateResult, error) {
+	slog.Info("CreateCertificateAuthority", "name", options.Name)
+
+	options.CA = ""
+	return createCertificate(options, true)
+}
+
+// IssueCertificate signs a leaf certificate with the CA named by options.CA.
+func (a *App) IssueCertificate(options TLSCertificateOptions) (TLSCertificateResult, error) {
+	slog.Info("IssueCertificate", "name", options.Name, "ca", options.CA, "sans", options.SANs)
+
+	if options.CA == "" {
+		return TLSCertificateResult{}, errors.New("missing ca")
+	}
+	return createCertificate(options, false)
+}
+
+// InspectCertificate reads every certificate of a PEM file, or of pemText when path is empty.
+func (a *App) InspectCertificate(path string, pemText string) ([]CertificateInfo, error) {
+	slog.Info("InspectCertificate", "path", path)
+
+	data := []byte(pemText)
+	if path != "" {
+		b, err := os.ReadFile(GetPath(path))
+		if err != nil {
+			return nil, err
+		}
+		data = b
+	}
+	return parseCertificateInfos(data)
+}
+
+func parseCertificateInfos(data []byte) ([]CertificateInfo, error) {
+	infos := []CertificateInfo{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, certificateInfo(cert))
+	}
+	if len(infos) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return infos, nil
+}
+
+// GenerateECHKeyPair writes <name>.ech.key (server tls.ech.key_path) and <name>.ech.config
+// (client tls.ech.config_path) in the format of `sing-box generate ech-keypair`.
+func (a *App) GenerateECHKeyPair(options ECHKeyOptions) (ECHKeyResult, error) {
+	slog.Info("GenerateECHKeyPair", "name", options.Name, "publicName", options.PublicName)
+
+	if options.PublicName == "" {
+		return ECHKeyResult{}, errors.New("missing publicName")
+	}
+	if len(options.PublicName) > 255 {
+		return ECHKeyResult{}, errors.New("publicName is too long")
+	}
+	dir, err := certificateDir(options.Dir, options.Name)
+	if err != nil {
+		return ECHKeyResult{}, err
+	}
+	result := ECHKeyResult{
+		ConfigPath: dir + "/" + options.Name + ".ech.config",
+		KeyPath:    dir + "/" + options.Name + ".ech.key",
+	}
+	if err := checkOverwrite(options.Overwrite, result.ConfigPath, result.KeyPath); err != nil {
+		return ECHKeyResult{}, err
+	}
+
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return ECHKeyResult{}, err
+	}
+	config := marshalECHConfig(key.PublicKey().Bytes(), options.PublicName)
+
+	configPEM := pem.EncodeToMemory(&pem.Block{Type: "ECH CONFIGS", Bytes: appendUint16Prefixed(nil, config)})
+	keyBytes := appendUint16Prefixed(nil, key.Bytes())
+	keyBytes = appendUint16Prefixed(keyBytes, config)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "ECH KEYS", Bytes: keyBytes})
+
+	if err := WriteFileAtomic(GetPath(result.KeyPath), keyPEM, 0600); err != nil {
+		return ECHKeyResult{}, err
+	}
+	if err := WriteFileAtomic(GetPath(result.ConfigPath), configPEM, 0644); err != nil {
+		return ECHKeyResult{}, err
+	}
+	result.Config = string(configPEM)
+
+	return result, nil
+}
+
+func createCertificate(options TLSCertificateOptions, isCA bool) (TLSCertificateResult, error) {
+	dir, err := certificateDir(options.Dir, options.Name)
+	if err != nil {
+		return TLSCertificateResult{}, err
+	}
+	result := TLSCertificateResult{
+		CertPath: dir + "/" + options.Name + ".crt",
+		KeyPath:  dir + "/" + options.Name + ".key",
+	}
+	if err := checkOverwrite(options.Overwrite, result.CertPath, result.KeyPath); err != nil {
+		return TLSCertificateResult{}, err
+	}
+
+	commonName := options.CommonName
+	if commonName == "" && len(options.SANs) > 0 {
+		commonName = options.SANs[0]
+	}
+	if commonName == "" {
+		return TLSCertificateResult{}, errors.New("missing commonName or sans")
+	}
+
+	days := options.Days
+	if days <= 0 {
+		days = defaultCertificateDays
+		if isCA {
+			days = defaultCADays
+		}
+	}
+
+	signer, err := generateSigner(options.KeyType)
+	if err != nil {
+		return TLSCertificateResult{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return TLSCertificateResult{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(0, 0, days),
+		BasicConstraintsValid: true,
+	}
+	if isCA {
+		template.IsCA = true
+		template.MaxPathLenZero = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature
+	} else {
+		template.KeyUsage = x509.KeyUsageDigitalSignature
+		if _, ok := signer.(*rsa.PrivateKey); ok {
+			template.KeyUsage |= x509.KeyUsageKeyEncipherment
+		}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+		sans := options.SANs
+		if len(sans) == 0 {
+			sans = []string{commonName}
+		}
+		for _, san := range sans {
+			san = strings.TrimSpace(san)
+			if ip := net.ParseIP(san); ip != nil {
+				template.IPAddresses = append(template.IPAddresses, ip)
+			} else if san != "" {
+				template.DNSNames = append(template.DNSNames, san)
+			}
+		}
+	}
+
+	parent := template
+	var parentKey crypto.Signer = signer
+	if options.CA != "" {
+		parent, parentKey, err = loadCertificateAuthority(dir, options.CA)
+		if err != nil {
+			return TLSCertificateResult{}, err
+		}
+		if template.NotAfter.After(parent.NotAfter) {
+			template.NotAfter = parent.NotAfter
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, signer.Public(), parentKey)
+	if err != nil {
+		return TLSCertificateResult{}, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return TLSCertificateResult{}, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return TLSCertificateResult{}, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := WriteFileAtomic(GetPath(result.KeyPath), keyPEM, 0600); err != nil {
+		return TLSCertificateResult{}, err
+	}
+	if err := WriteFileAtomic(GetPath(result.CertPath), certPEM, 0644); err != nil {
+		return TLSCertificateResult{}, err
+	}
+
+	result.Certificate = certificateInfo(cert)
+	return result, nil
+}
+
+func loadCertificateAuthority(dir, name string) (*x509.Certificate, crypto.Signer, error) {
+	if err := validateCertificateName(name); err != nil {
+		return nil, nil, err
+	}
+	certPEM, err := os.ReadFile(GetPath(dir + "/" + name + ".crt"))
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(GetPath(dir + "/" + name + ".key"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, errors.New("invalid CA files: " + name)
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !cert.IsCA {
+		return nil, nil, errors.New(name + " is not a certificate authority")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("unsupported CA key")
+	}
+	return cert, signer, nil
+}
+
+func generateSigner(keyType string) (crypto.Signer, error) {
+	switch keyType {
+	case "", KeyTypeECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case KeyTypeRSA:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case KeyTypeEd25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, errors.New("unsupported key type: " + keyType)
+}
+
+func certificateInfo(cert *x509.Certificate) CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+	hexFingerprint := strings.ToUpper(hex.EncodeToString(fingerprint[:]))
+	pairs := make([]string, 0, len(fingerprint))
+	for i := 0; i < len(hexFingerprint); i += 2 {
+		pairs = append(pairs, hexFingerprint[i:i+2])
+	}
+
+	ips := []string{}
+	for _, ip := range cert.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	dnsNames := cert.DNSNames
+	if dnsNames == nil {
+		dnsNames = []string{}
+	}
+
+	keyType := "unknown"
+	switch cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		keyType = KeyTypeECDSA
+	case *rsa.PublicKey:
+		keyType = KeyTypeRSA
+	case ed25519.PublicKey:
+		keyType = KeyTypeEd25519
+	}
+
+	remaining := time.Until(cert.NotAfter)
+	return CertificateInfo{
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       fmt.Sprintf("%X", cert.SerialNumber),
+		DNSNames:           dnsNames,
+		IPAddresses:        ips,
+		NotBefore:          cert.NotBefore.UnixMilli(),
+		NotAfter:           cert.NotAfter.UnixMilli(),
+		DaysLeft:           int(remaining.Hours() / 24),
+		Expired:            remaining <= 0,
+		IsCA:               cert.IsCA,
+		SelfSigned:         cert.CheckSignatureFrom(cert) == nil,
+		KeyType:            keyType,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		SHA256Fingerprint:  strings.Join(pairs, ":"),
+	}
+}
+
+// marshalECHConfig encodes a draft-ietf-tls-esni ECHConfig for DHKEM(X25519, HKDF-SHA256).
+func marshalECHConfig(publicKey []byte, publicName string) []byte {
+	const (
+		extensionEncryptedClientHello = 0xfe0d
+		kemX25519HKDFSHA256           = 0x0020
+		kdfHKDFSHA256                 = 0x0001
+	)
+
+	var suites []byte
+	for _, aead := range []uint16{0x0001, 0x0002, 0x0003} { // AES-128-GCM, AES-256-GCM, ChaCha20Poly1305
+		suites = binary.BigEndian.AppendUint16(suites, kdfHKDFSHA256)
+		suites = binary.BigEndian.AppendUint16(suites, aead)
+	}
+
+	contents := []byte{0} // config_id
+	contents = binary.BigEndian.AppendUint16(contents, kemX25519HKDFSHA256)
+	contents = appendUint16Prefixed(contents, publicKey)
+	contents = appendUint16Prefixed(contents, suites)
+	contents = append(contents, 0) // maximum_name_length
+	contents = append(contents, byte(len(publicName)))
+	contents = append(contents, publicName...)
+	contents = binary.BigEndian.AppendUint16(contents, 0) // extensions
+
+	config := binary.BigEndian.AppendUint16(nil, extensionEncryptedClientHello)
+	return appendUint16Prefixed(config, contents)
+}
+
+func appendUint16Prefixed(b []byte, data []byte) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+func certificateDir(dir, name string) (string, error) {
+	if err := validateCertificateName(name); err != nil {
+		return "", err
+	}
+	if dir == "" {
+		dir = CertificatesDirectory
+	}
+	return strings.TrimSuffix(dir, "/"), nil
+}
+
+func validateCertificateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errors.New("invalid name: " + name)
+	}
+	return nil
+}
+
+func checkOverwrite(overwrite bool, paths ...string) error {
+	if overwrite {
+		return nil
+	}
+	for _, path := range paths {
+		if _, err := os.Stat(GetPath(path)); err == nil {
+			return errors.New(path + " already exists")
+		}
+	}
+	return nil
+}
@@ -8,8 +8,15 @@ import (
 
 // App struct
 type App struct {
-	Bus  *eventbus.Bus
-	Exit func()
+	Bus       *eventbus.Bus
+	Exit      func()
+	Scheduler *Scheduler
+	Stats     *StatsRecorder
+	Prober    *LatencyProber
+	Failover  *Failover
+	Alerts    *Alerter
+	Inbox     *Inbox
+	Quota     *QuotaTracker
 }
 
 type EnvResult struct {
@@ -47,6 +54,13 @@ type FlagResult struct {
 	Data string `json:"data"`
 }
 
+// UpdateFailure is emitted as "subscription::failed" and "ruleset::failed".
+type UpdateFailure struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
 type ServerOptions struct {
 	Cert          string
 	Key           string
@@ -58,8 +72,10 @@ type ServerOptions struct {
 }
 
 type NotifyOptions struct {
-	AppName string
-	Beep    bool
+	AppName  string
+	Beep     bool
+	Severity string // info / warning / error, for the inbox
+	Source   string // what raised it, "app" when empty
 }
 
 type HTTPResult struct {
@@ -79,6 +95,30 @@ type AppConfig struct {
 	StartHidden      bool
 }
 
+type KernelConfig struct {
+	Branch string            `yaml:"branch"`
+	Main   CoreRuntimeConfig `yaml:"main"`
+	Alpha  CoreRuntimeConfig `yaml:"alpha"`
+}
+
+type CoreRuntimeConfig struct {
+	Env  map[string]string `yaml:"env"`
+	Args []string          `yaml:"args"`
+}
+
+type CoreConfigError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+}
+
+type CoreValidateResult struct {
+	Valid  bool              `json:"valid"`
+	Errors []CoreConfigError `json:"errors"`
+	Output string            `json:"output"`
+}
+
 type TrayContent struct {
 	Icon    string `json:"icon"`
 	Title   string `json:"title"`
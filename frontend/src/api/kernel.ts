@@ -1,5 +1,4 @@
 import { apiBaseURL } from '@/bridge/http'
-import { useAppSettingsStore, useProfilesStore } from '@/stores'
 import { Request } from '@/utils/request'
 
 import type { CoreApiConfig, CoreApiProxies, CoreApiConnections } from '@/types/kernel'
@@ -14,18 +13,9 @@ export enum Api {
   Logs = '/logs',
 }
 
-type CoreConnectionOptions = {
-  coreBase: string
-  coreBearer: string
-}
-
+// The panel resolves the controller and secret from the active core config itself
 const setupKernelApi = () => {
-  const { coreBase, coreBearer } = resolveCoreConnection()
   request.base = getCoreProxyBase()
-  request.headers = {
-    'X-Core-Base': coreBase,
-    ...(coreBearer ? { 'X-Core-Bearer': coreBearer } : {}),
-  }
 }
 
 const request = new Request({ beforeRequest: setupKernelApi, timeout: 60 * 1000 })
@@ -51,37 +41,6 @@ export const getProxyDelay = (proxy: string, url: string) => {
   })
 }
 
-export const resolveCoreConnection = (): CoreConnectionOptions => {
-  const appSettingsStore = useAppSettingsStore()
-  const profilesStore = useProfilesStore()
-  const profile = profilesStore.getProfileById(appSettingsStore.app.kernel.profile)
-  const controller = (profile?.experimental.clash_api.external_controller || '127.0.0.1:20123').trim()
-  let normalized = controller
-  if (!normalized.includes('://')) {
-    normalized = `http://${normalized}`
-  }
-  let coreBase = 'http://127.0.0.1:20123'
-  try {
-    const url = new URL(normalized)
-    let host = url.hostname || '127.0.0.1'
-    if (host === '0.0.0.0') host = '127.0.0.1'
-    if (host === '' || host === '*') host = '127.0.0.1'
-    if (host === '::') host = '::1'
-    if (!host.startsWith('127.') && host !== '::1' && host !== 'localhost') {
-      host = '127.0.0.1'
-    }
-    const port = url.port || '20123'
-    const protocol = url.protocol === 'https:' ? 'https' : 'http'
-    coreBase = `${protocol}://${host}:${port}`
-  } catch (error) {
-    console.error('[kernelApi] failed to parse controller address, fallback to loopback', error)
-  }
-  return {
-    coreBase,
-    coreBearer: profile?.experimental.clash_api.secret || '',
-  }
-}
-
 export const getCoreProxyBase = () => {
   let base = apiBaseURL || '/api'
   if (base.endsWith('/')) {
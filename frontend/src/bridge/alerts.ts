@@ -0,0 +1,38 @@
+import { httpClient } from './http'
+
+export interface AlertRule {
+  name: string
+  events: string[]
+  match?: Record<string, string>
+  channels: string[]
+  title?: string
+  message?: string
+  dedup: number
+  dedupKey?: string
+  rateLimit: number
+  disabled?: boolean
+}
+
+export interface AlertDelivery {
+  time: number
+  rule: string
+  event: string
+  channel: string
+  title: string
+  suppressed?: 'dedup' | 'rate-limit'
+  error?: string
+}
+
+export const GetAlertStatus = () =>
+  httpClient.get<{
+    disabled: boolean
+    channels: { name: string; type: 'webhook' | 'smtp' | 'telegram' | 'discord' }[]
+    rules: AlertRule[]
+    thresholds: { certificateDays: number }
+    deliveries: AlertDelivery[]
+  }>('/alerts')
+
+export const TestAlertChannel = (channel: string, title = '', message = '') =>
+  httpClient.post('/alerts/test', { channel, title, message })
+
+export const ReloadAlerts = () => httpClient.post('/alerts/reload')
@@ -35,6 +35,8 @@ export const GetInterfaces = async () => {
   return data.split('|').filter(Boolean)
 }
 
+export const GetInterfaceDetails = () => httpClient.get<InterfacesResult>('/interfaces/details')
+
 export const GetRealityPublicKey = async (privateKey: string) => {
   const res = await httpClient.post<{ public_key: string }>('/reality/public-key', {
     private_key: privateKey,
@@ -42,6 +44,23 @@ export const GetRealityPublicKey = async (privateKey: string) => {
   return res.public_key
 }
 
+export interface GeneratedKey {
+  privateKey?: string
+  publicKey?: string
+  shortId?: string
+  preSharedKey?: string
+  uuid?: string
+  key?: string
+  password?: string
+}
+
+export const GenerateKeys = (options: {
+  type: 'reality' | 'wireguard' | 'uuid' | 'shadowsocks' | 'password'
+  count?: number
+  method?: string
+  length?: number
+}) => httpClient.post<{ type: string; keys: GeneratedKey[] }>('/keys/generate', options)
+
 export type EnvResult = {
   appName: string
   appVersion: string
@@ -49,3 +68,28 @@ export type EnvResult = {
   os: string
   arch: string
 }
+
+export type InterfaceAddress = {
+  ip: string
+  prefix: number
+  cidr: string
+}
+
+export type InterfaceInfo = {
+  index: number
+  name: string
+  mtu: number
+  hardwareAddr: string
+  flags: string[]
+  up: boolean
+  loopback: boolean
+  tunnel: boolean
+  default: boolean
+  ipv4: InterfaceAddress[]
+  ipv6: InterfaceAddress[]
+}
+
+export type InterfacesResult = {
+  interfaces: InterfaceInfo[]
+  defaultRoute: { interface: string; gateway: string } | null
+}
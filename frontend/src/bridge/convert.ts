@@ -0,0 +1,30 @@
+import { httpClient } from './http'
+
+export interface ShareLinkResult {
+  link: string
+  outbound: Recordable | null
+  error: string
+}
+
+export interface ShareLinkExport {
+  tag: string
+  link: string
+  error: string
+}
+
+export const ConvertShareLinks = (links: string[]) =>
+  httpClient.post<ShareLinkResult[]>('/convert/links', { links })
+
+export const ExportShareLinks = (outbounds: Recordable[]) =>
+  httpClient.post<ShareLinkExport[]>('/convert/export', { outbounds })
+
+export interface ClashConvertItem {
+  name: string
+  type: string
+  outbound: Recordable | null
+  dropped: string[] | null
+  error: string
+}
+
+export const ConvertClashConfig = (config: string) =>
+  httpClient.post<{ outbounds: Recordable[]; report: ClashConvertItem[] }>('/clash/convert', { config })
@@ -0,0 +1,27 @@
+import { httpClient } from './http'
+
+export interface FailoverEvent {
+  time: number
+  group: string
+  from: string
+  to: string
+  reason: string
+  error?: string
+}
+
+export const GetFailoverStatus = () =>
+  httpClient.get<{
+    settings: Recordable
+    pins: Record<string, { outbound: string; time: number }>
+    blacklist: Record<string, Record<string, number>>
+    lastSwitch: Record<string, number>
+  }>('/failover')
+
+export const GetFailoverEvents = () => httpClient.get<FailoverEvent[]>('/failover/events')
+
+export const PinFailoverGroup = (group: string, outbound: string) =>
+  httpClient.post('/failover/pin', { group, outbound })
+
+export const UnpinFailoverGroup = (group: string) => httpClient.post('/failover/unpin', { group })
+
+export const EvaluateFailover = () => httpClient.post<FailoverEvent[]>('/failover/evaluate')
@@ -8,3 +8,14 @@ export * from './notification'
 export * from './events'
 export * from './window'
 export * from './browser'
+export * from './scheduler'
+export * from './subscribes'
+export * from './convert'
+export * from './tls'
+export * from './share'
+export * from './rulesets'
+export * from './stats'
+export * from './latency'
+export * from './failover'
+export * from './alerts'
+export * from './logs'
@@ -0,0 +1,35 @@
+import { httpClient } from './http'
+
+export interface LatencySample {
+  time: number
+  delay: number
+  error?: string
+}
+
+export interface OutboundHealth {
+  name: string
+  groups: string[]
+  up: boolean
+  delay: number
+  successRate: number
+  failures: number
+  lastCheck: number
+  lastChange: number
+  history: LatencySample[]
+}
+
+export const GetLatencyStatus = () =>
+  httpClient.get<{
+    settings: Recordable
+    running: boolean
+    lastRound: number
+    error: string
+    outbounds: OutboundHealth[]
+  }>('/latency')
+
+export const GetLatencyHistory = (name: string) =>
+  httpClient.get<LatencySample[]>('/latency/history?name=' + encodeURIComponent(name))
+
+export const ProbeLatency = () => httpClient.post<OutboundHealth[]>('/latency/probe')
+
+export const ReloadLatencyProber = () => httpClient.post('/latency/reload')
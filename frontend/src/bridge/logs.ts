@@ -0,0 +1,21 @@
+import { httpClient } from './http'
+
+export interface LogEntry {
+  time: number
+  level: 'DEBUG' | 'INFO' | 'WARN' | 'ERROR'
+  message: string
+  attrs?: Record<string, any>
+}
+
+// Newest matching entries in order; pass the last entry's time as since to tail
+export const GetLogs = (
+  options: { limit?: number; level?: 'debug' | 'info' | 'warn' | 'error'; q?: string; since?: number } = {},
+) => {
+  const params = new URLSearchParams()
+  if (options.limit) params.set('limit', String(options.limit))
+  if (options.level) params.set('level', options.level)
+  if (options.q) params.set('q', options.q)
+  if (options.since) params.set('since', String(options.since))
+  const query = params.toString()
+  return httpClient.get<LogEntry[]>('/logs' + (query ? '?' + query : ''))
+}
@@ -1,5 +1,7 @@
 import { message } from '@/utils'
 
+import { httpClient } from './http'
+
 interface NotifyOptions {
   silent?: boolean
 }
@@ -42,3 +44,30 @@ const formatIcon = (icon: string) => {
   if (/^https?:\/\//i.test(icon)) return icon
   return `${window.location.origin}/${icon.replace(/^\//, '')}`
 }
+
+export interface InboxNotification {
+  id: string
+  time: number
+  title: string
+  message: string
+  severity: 'info' | 'warning' | 'error'
+  source: string
+  read: boolean
+}
+
+export const ListNotifications = (options: { unread?: boolean; source?: string; limit?: number } = {}) => {
+  const params = new URLSearchParams()
+  if (options.unread) params.set('unread', 'true')
+  if (options.source) params.set('source', options.source)
+  if (options.limit) params.set('limit', String(options.limit))
+  const query = params.toString()
+  return httpClient.get<{ items: InboxNotification[]; total: number; unread: number }>(
+    '/notifications' + (query ? '?' + query : ''),
+  )
+}
+
+export const MarkNotificationsRead = (ids: string[] = []) =>
+  httpClient.post<{ changed: number }>('/notifications/read', { ids })
+
+export const ClearNotifications = (ids: string[] = [], readOnly = false) =>
+  httpClient.post<{ removed: number }>('/notifications/clear', { ids, readOnly })
@@ -0,0 +1,41 @@
+import { httpClient } from './http'
+
+import type { RuleSet } from '@/stores/rulesets'
+
+export interface RulesetUpdateResult {
+  id: string
+  tag: string
+  format: 'source' | 'binary'
+  count: number
+  notModified: boolean
+  compiled: string
+  compileError: string
+  updateTime: number
+}
+
+export const ListRulesets = () => httpClient.get<RuleSet[]>('/rulesets')
+
+export const UpdateRulesetHeadless = (id: string) =>
+  httpClient.post<RulesetUpdateResult>('/rulesets/update', { id })
+
+export const CompileRuleset = (path: string) =>
+  httpClient.post<{ path: string }>('/rulesets/compile', { path })
+
+export interface RulesetMatch {
+  tag: string
+  path: string
+  format: 'source' | 'binary'
+  rule: number
+  item: string
+  pattern: string
+  conditions: string[] | null
+}
+
+export const MatchRulesets = (value: string, tags: string[] = []) =>
+  httpClient.post<{
+    value: string
+    kind: 'domain' | 'ip'
+    checked: number
+    matches: RulesetMatch[]
+    errors: string[]
+  }>('/rulesets/match', { value, tags })
@@ -0,0 +1,36 @@
+import { httpClient } from './http'
+
+export interface TaskRun {
+  taskId: string
+  name: string
+  type: string
+  trigger: 'schedule' | 'missed' | 'manual'
+  startTime: number
+  endTime: number
+  success: boolean
+  result: string[]
+  error: string
+}
+
+export interface TaskState {
+  id: string
+  name: string
+  type: string
+  cron: string
+  disabled: boolean
+  next: number
+  running: boolean
+  error: string
+  lastTime: number
+  lastRun: TaskRun | null
+}
+
+export const GetScheduledTasksStatus = () =>
+  httpClient.get<{ settings: Recordable; tasks: TaskState[] }>('/scheduledtasks/status')
+
+export const GetScheduledTasksHistory = (id = '') =>
+  httpClient.get<TaskRun[]>('/scheduledtasks/history?id=' + encodeURIComponent(id))
+
+export const ReloadScheduledTasks = () => httpClient.post('/scheduledtasks/reload')
+
+export const RunScheduledTask = (id: string) => httpClient.post<TaskRun>('/scheduledtasks/run', { id })
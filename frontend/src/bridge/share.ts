@@ -0,0 +1,48 @@
+import { httpClient } from './http'
+
+export interface ShareSelection {
+  profile?: string
+  subscriptions?: string[]
+  tags?: string[]
+}
+
+export interface ShareToken extends ShareSelection {
+  id: string
+  name: string
+  format: 'base64' | 'clash' | 'sing-box'
+  token: string
+  revoked: boolean
+  hits: number
+  lastAccess: number
+  createTime: number
+}
+
+export interface QRCodeOptions {
+  format?: 'png' | 'svg'
+  size?: number
+}
+
+export interface ShareQRCode {
+  tag: string
+  link: string
+  image: string
+  error: string
+}
+
+export const ListShareTokens = () => httpClient.get<ShareToken[]>('/share/tokens')
+
+export const CreateShareToken = (options: ShareSelection & { name: string; format: ShareToken['format'] }) =>
+  httpClient.post<ShareToken>('/share/tokens', options)
+
+export const RevokeShareToken = (id: string) => httpClient.post('/share/tokens/revoke', { id })
+
+export const RemoveShareToken = (id: string) => httpClient.post('/share/tokens/remove', { id })
+
+// Returns the SVG markup; PNG is meant for direct downloads of the endpoint
+export const RenderQRCodeSVG = (content: string, size?: number) =>
+  httpClient.post<string>('/share/qrcode', { content, format: 'svg', size })
+
+export const ShareQRCodes = (selection: ShareSelection, options: QRCodeOptions = {}) =>
+  httpClient.post<ShareQRCode[]>('/share/qrcodes', { ...selection, ...options })
+
+export const GetShareTokenURL = (token: string) => `${location.origin}/sub/${token}`
@@ -0,0 +1,79 @@
+import { httpClient } from './http'
+
+export interface StatsSettings {
+  disabled: boolean
+  minuteRetention: number
+  hourRetention: number
+}
+
+export interface TrafficCounter {
+  name: string
+  up: number
+  down: number
+}
+
+export interface TrafficHistory {
+  from: number
+  to: number
+  step: number
+  resolution: 'minute' | 'hour'
+  points: { time: number; up: number; down: number }[]
+  outbounds: TrafficCounter[]
+  rules: TrafficCounter[]
+}
+
+export const GetStatsStatus = () =>
+  httpClient.get<{ settings: StatsSettings; recording: boolean; error: string }>('/stats/status')
+
+// from/to are unix milliseconds, step is seconds or a duration such as '1h'
+export const GetTrafficHistory = (options: { from?: number; to?: number; step?: number | string } = {}) => {
+  const params = new URLSearchParams()
+  Object.entries(options).forEach(([key, value]) => value && params.set(key, String(value)))
+  return httpClient.get<TrafficHistory>('/stats/traffic?' + params.toString())
+}
+
+export type UsageDimension = 'client' | 'host' | 'inbound' | 'chain' | 'rule'
+
+export interface UsageItem {
+  key: string
+  name: string
+  up: number
+  down: number
+  connections: number
+  points?: { time: number; up: number; down: number; connections: number }[]
+}
+
+export interface UsageOptions {
+  by: UsageDimension
+  from?: number
+  to?: number
+  step?: number | string
+  limit?: number
+}
+
+const usageParams = (options: UsageOptions) => {
+  const params = new URLSearchParams()
+  Object.entries(options).forEach(([key, value]) => value && params.set(key, String(value)))
+  return params
+}
+
+export const GetUsage = (options: UsageOptions) =>
+  httpClient.get<{
+    by: UsageDimension
+    from: number
+    to: number
+    step: number
+    resolution: 'minute' | 'hour'
+    items: UsageItem[]
+  }>('/stats/usage?' + usageParams(options).toString())
+
+export const GetUsageCSV = (options: UsageOptions) => {
+  const params = usageParams(options)
+  params.set('format', 'csv')
+  return httpClient.get<string>('/stats/usage?' + params.toString())
+}
+
+export const ListClientNames = () => httpClient.get<{ address: string; name: string }[]>('/stats/clients')
+
+export const SaveClientNames = (names: { address: string; name: string }[]) =>
+  httpClient.post('/stats/clients', names)
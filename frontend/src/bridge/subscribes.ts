@@ -0,0 +1,49 @@
+import { httpClient } from './http'
+
+import type { Subscription } from '@/types/app'
+
+export interface SubscriptionUpdateResult {
+  id: string
+  name: string
+  format: 'json' | 'yaml' | 'base64' | 'links' | 'manual'
+  count: number
+  skipped: string[]
+  upload: number
+  download: number
+  total: number
+  expire: number
+  updateTime: number
+}
+
+export const ListSubscriptions = () => httpClient.get<Subscription[]>('/subscribes')
+
+export const UpdateSubscriptionHeadless = (id: string) =>
+  httpClient.post<SubscriptionUpdateResult>('/subscribes/update', { id })
+
+export interface SubscriptionQuota {
+  id: string
+  name: string
+  upload: number
+  download: number
+  total: number
+  used: number
+  remaining: number
+  percent: number
+  expire: number
+  daysLeft: number
+  expired: boolean
+  updateTime: number
+}
+
+export interface QuotaSample {
+  time: number
+  upload: number
+  download: number
+  total: number
+  expire: number
+}
+
+export const ListSubscriptionQuotas = () => httpClient.get<SubscriptionQuota[]>('/subscribes/quota')
+
+export const GetSubscriptionQuotaHistory = (id: string) =>
+  httpClient.get<QuotaSample[]>('/subscribes/quota/history?id=' + encodeURIComponent(id))
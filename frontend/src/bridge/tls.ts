@@ -0,0 +1,50 @@
+import { httpClient } from './http'
+
+export interface TLSCertificateOptions {
+  name: string
+  dir?: string
+  commonName?: string
+  sans?: string[]
+  days?: number
+  keyType?: 'ecdsa' | 'rsa' | 'ed25519'
+  ca?: string
+  overwrite?: boolean
+}
+
+export interface CertificateInfo {
+  subject: string
+  issuer: string
+  serialNumber: string
+  dnsNames: string[]
+  ipAddresses: string[]
+  notBefore: number
+  notAfter: number
+  daysLeft: number
+  expired: boolean
+  isCA: boolean
+  selfSigned: boolean
+  keyType: string
+  signatureAlgorithm: string
+  sha256Fingerprint: string
+}
+
+export interface TLSCertificateResult {
+  certPath: string
+  keyPath: string
+  certificate: CertificateInfo
+}
+
+export const CreateSelfSignedCertificate = (options: TLSCertificateOptions) =>
+  httpClient.post<TLSCertificateResult>('/tls/self-signed', options)
+
+export const CreateCertificateAuthority = (options: TLSCertificateOptions) =>
+  httpClient.post<TLSCertificateResult>('/tls/ca', options)
+
+export const IssueCertificate = (options: TLSCertificateOptions) =>
+  httpClient.post<TLSCertificateResult>('/tls/issue', options)
+
+export const InspectCertificate = (path: string, pem = '') =>
+  httpClient.post<CertificateInfo[]>('/tls/inspect', { path, pem })
+
+export const GenerateECHKeyPair = (options: { name: string; dir?: string; publicName: string; overwrite?: boolean }) =>
+  httpClient.post<{ configPath: string; keyPath: string; config: string }>('/tls/ech', options)
@@ -1,7 +1,7 @@
 import { defineStore } from 'pinia'
 import { computed, ref, watch } from 'vue'
 
-import { getProxies, getConfigs, setConfigs, Api, resolveCoreConnection, getCoreProxyBase } from '@/api/kernel'
+import { getProxies, getConfigs, setConfigs, Api, getCoreProxyBase } from '@/api/kernel'
 import { ProcessInfo, KillProcess, ExecBackground, ReadFile, WriteFile, RemoveFile } from '@/bridge'
 import {
   CoreConfigFilePath,
@@ -315,12 +315,8 @@ export const useKernelApiStore = defineStore('kernelApi', () => {
   const initCoreWebsockets = () => {
     websocketInstance = new WebSockets({
       beforeConnect() {
-        const { coreBase, coreBearer } = resolveCoreConnection()
         this.base = resolveCoreProxyWSBase()
-        const params: Record<string, string> = { coreBase }
-        if (coreBearer) {
-          params.coreBearer = coreBearer
-        }
+        const params: Record<string, string> = {}
         if (authStore.token) {
           params.token = authStore.token
         }
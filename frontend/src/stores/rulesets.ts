@@ -2,18 +2,12 @@ import { defineStore } from 'pinia'
 import { ref } from 'vue'
 import { stringify, parse } from 'yaml'
 
-import { ReadFile, WriteFile, CopyFile, Download, HttpGet } from '@/bridge'
+import { ReadFile, WriteFile, HttpGet, EventsOn, UpdateRulesetHeadless } from '@/bridge'
 import { RulesetHubFilePath, RulesetsFilePath } from '@/constant/app'
-import { EmptyRuleSet } from '@/constant/kernel'
 import { RulesetFormat } from '@/enums/kernel'
-import {
-  asyncPool,
-  stringifyNoFolding,
-  eventBus,
-  ignoredError,
-  isValidRulesJson,
-  omitArray,
-} from '@/utils'
+import { asyncPool, stringifyNoFolding, eventBus, ignoredError, omitArray } from '@/utils'
+
+import type { RulesetUpdateResult } from '@/bridge'
 
 export interface RuleSet {
   id: string
@@ -38,6 +32,7 @@ export interface RuleSetHub {
 export const useRulesetsStore = defineStore('rulesets', () => {
   const rulesets = ref<RuleSet[]>([])
   const rulesetHub = ref<RuleSetHub>({ geosite: '', geoip: '', list: [] })
+  let updatedListening = false
 
   const setupRulesets = async () => {
     const data = await ignoredError(ReadFile, RulesetsFilePath)
@@ -45,6 +40,17 @@ export const useRulesetsStore = defineStore('rulesets', () => {
 
     const list = await ignoredError(ReadFile, RulesetHubFilePath)
     list && (rulesetHub.value = JSON.parse(list))
+
+    if (updatedListening) return
+    updatedListening = true
+    // Scheduled updates run on the server; keep the in-memory entry in step with rulesets.yaml
+    EventsOn('ruleset::updated', ({ id, count, updateTime }: RulesetUpdateResult) => {
+      const r = rulesets.value.find((v) => v.id === id)
+      if (!r) return
+      r.count = count
+      r.updateTime = updateTime
+      eventBus.emit('rulesetChange', { id })
+    })
   }
 
   const saveRulesets = () => {
@@ -93,59 +99,11 @@ export const useRulesetsStore = defineStore('rulesets', () => {
     eventBus.emit('rulesetChange', { id })
   }
 
+  // Fetching, validation and compiling source rule-sets to .srs happen on the server
   const _doUpdateRuleset = async (r: RuleSet) => {
-    if (r.format === RulesetFormat.Source) {
-      let body = ''
-      let isExist = true
-
-      if (r.type === 'File') {
-        body = await ReadFile(r.url)
-      } else if (r.type === 'Http') {
-        const { body: b } = await HttpGet(r.url)
-        body = b
-        if (typeof body !== 'string') {
-          body = JSON.stringify(body)
-        }
-      } else if (r.type === 'Manual') {
-        body = await ReadFile(r.path).catch(() => '')
-        if (!body) {
-          body = JSON.stringify(EmptyRuleSet)
-          isExist = false
-        }
-      }
-
-      if (!isValidRulesJson(body)) {
-        throw 'Not a valid ruleset data'
-      }
-
-      const ruleset = JSON.parse(body)
-
-      r.count = ruleset.rules.reduce(
-        (p: number, c: string[]) =>
-          Object.values(c).reduce(
-            (p, c: string[] | string) => (Array.isArray(c) ? p + c.length : p + 1),
-            0,
-          ) + p,
-        0,
-      )
-
-      if (
-        (['Http', 'File'].includes(r.type) && r.url !== r.path) ||
-        (r.type === 'Manual' && !isExist)
-      ) {
-        await WriteFile(r.path, JSON.stringify(ruleset, null, 2))
-      }
-    }
-
-    if (r.format === RulesetFormat.Binary) {
-      if (r.type === 'File' && r.url !== r.path) {
-        await CopyFile(r.url, r.path)
-      } else if (r.type === 'Http') {
-        await Download(r.url, r.path)
-      }
-    }
-
-    r.updateTime = Date.now()
+    const result = await UpdateRulesetHeadless(r.id)
+    r.count = result.count
+    r.updateTime = result.updateTime
   }
 
   const updateRuleset = async (id: string) => {
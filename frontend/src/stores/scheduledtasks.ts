@@ -1,28 +1,66 @@
-import { Cron } from 'croner'
 import { defineStore } from 'pinia'
 import { ref } from 'vue'
 import { parse } from 'yaml'
 
-import { ReadFile, WriteFile, Notify } from '@/bridge'
+import {
+  ReadFile,
+  WriteFile,
+  EventsOn,
+  EventsEmit,
+  ReloadScheduledTasks,
+  RunScheduledTask,
+  GetScheduledTasksStatus,
+} from '@/bridge'
 import { ScheduledTasksFilePath } from '@/constant/app'
 import { ScheduledTasksType, PluginTriggerEvent } from '@/enums/app'
 import { useSubscribesStore, useRulesetsStore, usePluginsStore, useLogsStore } from '@/stores'
-import { ignoredError, stringifyNoFolding } from '@/utils'
+import { ignoredError, omitArray, sampleID, stringifyNoFolding } from '@/utils'
 
 import type { ScheduledTask } from '@/types/app'
 
+const clientId = sampleID()
+
 export const useScheduledTasksStore = defineStore('scheduledtasks', () => {
   const scheduledtasks = ref<ScheduledTask[]>([])
-  const cronJobsMap: Recordable<Cron> = {}
+  let dispatchListening = false
 
+  // Tasks are scheduled by the server. Types it cannot run itself (plugins, scripts)
+  // are dispatched to every open panel; each claims the request and only the one the
+  // server confirms runs the task and answers on the request id.
   const setupScheduledTasks = async () => {
     const data = await ignoredError(ReadFile, ScheduledTasksFilePath)
     data && (scheduledtasks.value = parse(data))
 
-    scheduledtasks.value.forEach(async ({ disabled, cron, id }) => {
-      if (!disabled) {
-        cronJobsMap[id] = new Cron(cron, () => runScheduledTask(id))
-      }
+    // lastTime is kept by the server, not in scheduledtasks.yaml
+    const status = await ignoredError(GetScheduledTasksStatus)
+    status?.tasks.forEach(({ id, lastTime }) => {
+      const task = getScheduledTaskById(id)
+      task && (task.lastTime = lastTime)
+    })
+
+    if (dispatchListening) return
+    dispatchListening = true
+    EventsOn('scheduledtask::started', ({ taskId, startTime }: { taskId: string; startTime: number }) => {
+      const task = getScheduledTaskById(taskId)
+      task && (task.lastTime = startTime)
+    })
+    EventsOn('scheduledtask::dispatch', (requestId: string, id: string) => {
+      const off = EventsOn(requestId + '::claimed', async (winner: string) => {
+        off()
+        if (winner !== clientId) return
+        const task = getScheduledTaskById(id)
+        const fn = task && getTaskFn(task)
+        if (!fn) {
+          EventsEmit(requestId, [], id + ' Not Found')
+          return
+        }
+        try {
+          EventsEmit(requestId, await fn(), '')
+        } catch (error: any) {
+          EventsEmit(requestId, [], error.message || String(error))
+        }
+      })
+      EventsEmit(requestId + '::claim', clientId)
     })
   }
 
@@ -32,19 +70,14 @@ export const useScheduledTasksStore = defineStore('scheduledtasks', () => {
 
     const logsStore = useLogsStore()
 
-    task.lastTime = Date.now()
-    editScheduledTask(id, task)
-
-    const startTime = Date.now()
-    const result = await getTaskFn(task)()
-
-    task.notification && Notify(task.name, result.join('\n'))
+    const run = await RunScheduledTask(id)
+    task.lastTime = run.startTime
 
     logsStore.recordScheduledTasksLog({
       name: task.name,
-      startTime,
-      endTime: Date.now(),
-      result,
+      startTime: run.startTime,
+      endTime: run.endTime,
+      result: run.error ? [...run.result, run.error] : run.result,
     })
   }
 
@@ -89,18 +122,17 @@ export const useScheduledTasksStore = defineStore('scheduledtasks', () => {
     }
   }
 
-  const saveScheduledTasks = () => {
-    return WriteFile(ScheduledTasksFilePath, stringifyNoFolding(scheduledtasks.value))
+  const saveScheduledTasks = async () => {
+    const s = omitArray(scheduledtasks.value, ['lastTime'])
+    await WriteFile(ScheduledTasksFilePath, stringifyNoFolding(s))
+    await ReloadScheduledTasks()
   }
 
   const addScheduledTask = async (s: ScheduledTask) => {
     scheduledtasks.value.push(s)
     try {
-      cronJobsMap[s.id] = new Cron(s.cron, () => runScheduledTask(s.id))
       await saveScheduledTasks()
     } catch (error) {
-      cronJobsMap[s.id]?.stop()
-      delete cronJobsMap[s.id]
       const idx = scheduledtasks.value.indexOf(s)
       if (idx !== -1) {
         scheduledtasks.value.splice(idx, 1)
@@ -115,8 +147,6 @@ export const useScheduledTasksStore = defineStore('scheduledtasks', () => {
     const backup = scheduledtasks.value.splice(idx, 1)[0]!
     try {
       await saveScheduledTasks()
-      cronJobsMap[id]?.stop()
-      delete cronJobsMap[id]
     } catch (error) {
       scheduledtasks.value.splice(idx, 0, backup)
       throw error
@@ -129,12 +159,6 @@ export const useScheduledTasksStore = defineStore('scheduledtasks', () => {
     const backup = scheduledtasks.value.splice(idx, 1, s)[0]!
     try {
       await saveScheduledTasks()
-      cronJobsMap[id]?.stop()
-      if (s.disabled) {
-        delete cronJobsMap[id]
-      } else {
-        cronJobsMap[id] = new Cron(s.cron, () => runScheduledTask(id))
-      }
     } catch (error) {
       scheduledtasks.value.splice(idx, 1, backup)
       throw error
@@ -2,7 +2,7 @@ import { defineStore } from 'pinia'
 import { ref } from 'vue'
 import { parse } from 'yaml'
 
-import { ReadFile, WriteFile, Requests } from '@/bridge'
+import { ReadFile, WriteFile, Requests, EventsOn, ConvertClashConfig } from '@/bridge'
 import { DefaultSubscribeScript, SubscribesFilePath } from '@/constant/app'
 import { DefaultExcludeProtocols } from '@/constant/kernel'
 import { PluginTriggerEvent, RequestMethod } from '@/enums/app'
@@ -20,9 +20,11 @@ import {
 } from '@/utils'
 
 import type { Subscription } from '@/types/app'
+import type { SubscriptionUpdateResult } from '@/bridge'
 
 export const useSubscribesStore = defineStore('subscribes', () => {
   const subscribes = ref<Subscription[]>([])
+  let updatedListening = false
 
   const setupSubscribes = async () => {
     const data = await ignoredError(ReadFile, SubscribesFilePath)
@@ -61,6 +63,18 @@ export const useSubscribesStore = defineStore('subscribes', () => {
     if (needSync) {
       await saveSubscribes()
     }
+
+    if (updatedListening) return
+    updatedListening = true
+    // Headless updates by the server rewrite subscribes.yaml; pick up the new entry so a later save keeps it
+    EventsOn('subscription::updated', async ({ id }: SubscriptionUpdateResult) => {
+      const data = await ignoredError(ReadFile, SubscribesFilePath)
+      const updated = data && (parse(data) as Subscription[]).find((v) => v.id === id)
+      const idx = subscribes.value.findIndex((v) => v.id === id)
+      if (!updated || idx === -1) return
+      subscribes.value.splice(idx, 1, { ...subscribes.value[idx]!, ...updated })
+      eventBus.emit('subscriptionChange', { id })
+    })
   }
 
   const saveSubscribes = () => {
@@ -191,7 +205,13 @@ export const useSubscribesStore = defineStore('subscribes', () => {
 
     proxies = await pluginStore.onSubscribeTrigger(proxies, s)
 
-    if (proxies.some((proxy) => proxy.name && !proxy.tag) || proxies[0]?.base64) {
+    // Clash proxies that no plugin converted go through the server-side converter
+    if (proxies.some((proxy) => proxy.name && !proxy.tag)) {
+      const { outbounds } = await ConvertClashConfig(JSON.stringify({ proxies }))
+      proxies = outbounds
+    }
+
+    if (proxies[0]?.base64) {
       throw 'You need to install the [节点转换] plugin first'
     }
 
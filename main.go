@@ -3,23 +3,26 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/ecdh"
 	"crypto/rand"
+	"crypto/subtle"
 	"embed"
-	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
 	"io/fs"
 	"log"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/netip"
 	"net/url"
 	"os"
 	"os/signal"
 	"path"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -32,14 +35,30 @@ import (
 
 	"guiforcores/bridge"
 	"guiforcores/pkg/eventbus"
+	"guiforcores/pkg/metrics"
+	"guiforcores/pkg/sdnotify"
 )
 
 //go:embed all:frontend/dist
 var distFS embed.FS
 
 var (
-	hopHeaders     = []string{"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
-	coreHTTPClient = &http.Client{Timeout: 30 * time.Second}
+	hopHeaders = []string{"Connection", "Proxy-Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+	// coreHTTPClient has no overall timeout so Clash API streams stay open; plain requests
+	// get coreRequestTimeout through their context instead.
+	coreHTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext:           (&net.Dialer{Timeout: coreDialTimeout}).DialContext,
+			ResponseHeaderTimeout: coreRequestTimeout,
+		},
+	}
+	// coreStreamPaths are Clash API endpoints that keep writing chunks until the client leaves.
+	coreStreamPaths = []string{"/traffic", "/memory", "/logs", "/connections"}
+)
+
+const (
+	coreDialTimeout    = 5 * time.Second
+	coreRequestTimeout = 30 * time.Second
 )
 
 type Server struct {
@@ -52,11 +71,20 @@ type Server struct {
 	sessions   map[string]time.Time
 	sessionTTL time.Duration
 	mu         sync.Mutex
+
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+
+	started time.Time
 }
 
 type AuthConfig struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+	// /metrics accepts this bearer token, or scrapes from the allowed addresses and CIDRs;
+	// with neither set it only answers loopback requests
+	MetricsToken string   `yaml:"metricsToken,omitempty"`
+	MetricsAllow []string `yaml:"metricsAllow,omitempty"`
 }
 
 func loadAuthConfig() *AuthConfig {
@@ -71,26 +99,28 @@ func loadAuthConfig() *AuthConfig {
 	}
 	data, err := os.ReadFile(path)
 	if err != nil {
-		log.Fatalf("failed to read auth config: %v", err)
+		slog.Error("failed to read auth config", "error", err)
+		os.Exit(1)
 	}
 	if err := yaml.Unmarshal(data, cfg); err != nil {
-		log.Fatalf("failed to parse auth config: %v", err)
+		slog.Error("failed to parse auth config", "error", err)
+		os.Exit(1)
 	}
 	return cfg
 }
 
 func writeAuthConfig(path string, cfg *AuthConfig) {
 	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
-		log.Printf("failed to create auth config directory: %v", err)
+		slog.Error("failed to create auth config directory", "error", err)
 		return
 	}
 	data, err := yaml.Marshal(cfg)
 	if err != nil {
-		log.Printf("failed to marshal auth config: %v", err)
+		slog.Error("failed to marshal auth config", "error", err)
 		return
 	}
 	if err := os.WriteFile(path, data, 0644); err != nil {
-		log.Printf("failed to write auth config: %v", err)
+		slog.Error("failed to write auth config", "error", err)
 	}
 }
 
@@ -109,6 +139,12 @@ func NewServer(app *bridge.App, bus *eventbus.Bus) *Server {
 		auth:       authCfg,
 		sessions:   make(map[string]time.Time),
 		sessionTTL: 24 * time.Hour,
+		started:    time.Now(),
+
+		requests: metrics.NewCounterVec("guiforcores_http_requests_total",
+			"HTTP requests by route pattern.", "method", "route", "code"),
+		requestDuration: metrics.NewHistogramVec("guiforcores_http_request_duration_seconds",
+			"HTTP request latency by route pattern.", metrics.DefaultBuckets, "method", "route"),
 	}
 	app.Exit = server.Shutdown
 	return server
@@ -123,8 +159,9 @@ func (s *Server) Run(addr string) error {
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
-	router.Use(middleware.Logger)
+	router.Use(s.logMiddleware)
 	router.Use(middleware.Recoverer)
+	router.Use(s.metricsMiddleware)
 
 	router.Route("/api", func(api chi.Router) {
 		api.Post("/login", s.handleLogin)
@@ -143,14 +180,62 @@ func (s *Server) Run(addr string) error {
 			private.Route("/mmdb", func(mmdb chi.Router) {
 				s.registerMMDBRoutes(mmdb)
 			})
+			private.Route("/scheduledtasks", func(tasks chi.Router) {
+				s.registerSchedulerRoutes(tasks)
+			})
+			private.Route("/subscribes", func(subs chi.Router) {
+				s.registerSubscriptionRoutes(subs)
+			})
+			private.Route("/rulesets", func(rulesets chi.Router) {
+				s.registerRulesetRoutes(rulesets)
+			})
+			private.Route("/convert", func(convert chi.Router) {
+				s.registerConvertRoutes(convert)
+			})
+			private.Route("/tls", func(tlsRouter chi.Router) {
+				s.registerTLSRoutes(tlsRouter)
+			})
+			private.Route("/share", func(share chi.Router) {
+				s.registerShareRoutes(share)
+			})
+			private.Route("/stats", func(stats chi.Router) {
+				s.registerStatsRoutes(stats)
+			})
+			private.Route("/latency", func(latency chi.Router) {
+				s.registerLatencyRoutes(latency)
+			})
+			private.Route("/failover", func(failover chi.Router) {
+				s.registerFailoverRoutes(failover)
+			})
+			private.Route("/alerts", func(alerts chi.Router) {
+				s.registerAlertRoutes(alerts)
+			})
+			private.Route("/notifications", func(notifications chi.Router) {
+				s.registerInboxRoutes(notifications)
+			})
+			private.Route("/logs", func(logs chi.Router) {
+				s.registerLogRoutes(logs)
+			})
 			private.Route("/core", func(core chi.Router) {
+				core.Post("/validate", s.handleCoreValidate)
+				core.Post("/apply", s.handleCoreApply)
+				core.Get("/status", func(w http.ResponseWriter, _ *http.Request) {
+					writeJSON(w, http.StatusOK, s.app.CoreStatus())
+				})
+				core.Route("/versions", func(versions chi.Router) {
+					s.registerCoreVersionRoutes(versions)
+				})
 				core.HandleFunc("/*", s.handleCoreProxy)
 			})
 			private.Post("/logout", s.handleLogout)
 		})
 	})
 
+	router.Get("/healthz", s.handleHealthz)
+	router.Get("/readyz", s.handleReadyz)
+	router.Get("/metrics", s.handleMetrics)
 	router.HandleFunc("/ws", s.handleWebsocket)
+	router.Get("/sub/{token}", s.handleShareSubscription)
 
 	router.Handle("/*", s.spaHandler())
 	router.Handle("/", s.spaHandler())
@@ -167,13 +252,55 @@ func (s *Server) Run(addr string) error {
 		_ = s.httpServer.Shutdown(ctx)
 	}()
 
-	err := s.httpServer.ListenAndServe()
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	go s.notifySystemd()
+
+	err = s.httpServer.Serve(listener)
 	if errors.Is(err, http.ErrServerClosed) {
 		return nil
 	}
 	return err
 }
 
+// notifySystemd reports readiness once the server listens and, when the unit sets
+// WatchdogSec, keeps the watchdog fed for as long as data/ and the event bus are healthy.
+func (s *Server) notifySystemd() {
+	if ok, err := sdnotify.Notify(sdnotify.Ready); err != nil {
+		slog.Warn("sd_notify", "error", err)
+		return
+	} else if !ok {
+		return
+	}
+
+	interval := sdnotify.WatchdogInterval()
+	if interval <= 0 {
+		<-s.shutdown
+		_, _ = sdnotify.Notify(sdnotify.Stopping)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.shutdown:
+			_, _ = sdnotify.Notify(sdnotify.Stopping)
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			checks := s.readinessChecks(ctx, []string{"core"})
+			cancel()
+			if failed := failedChecks(checks); len(failed) > 0 {
+				slog.Warn("sd_notify: skipping watchdog", "failing", failed)
+				continue
+			}
+			_, _ = sdnotify.Notify(sdnotify.Watchdog)
+		}
+	}
+}
+
 func (s *Server) Shutdown() {
 	select {
 	case <-s.shutdown:
@@ -184,10 +311,26 @@ func (s *Server) Shutdown() {
 }
 
 func main() {
+	bridge.SetupLogging()
+	defer bridge.CloseLogging()
+
 	bus := eventbus.New()
 	app := bridge.NewApp(bus)
 	server := NewServer(app, bus)
 
+	app.Scheduler.Start()
+	defer app.Scheduler.Stop()
+	app.Stats.Start()
+	defer app.Stats.Stop()
+	app.Prober.Start()
+	defer app.Prober.Stop()
+	app.Alerts.Start()
+	defer app.Alerts.Stop()
+	app.Inbox.Start()
+	defer app.Inbox.Stop()
+	app.Quota.Start()
+	defer app.Quota.Stop()
+
 	addr := os.Getenv("SERVER_ADDR")
 	if addr == "" {
 		port := os.Getenv("PORT")
@@ -211,11 +354,13 @@ func main() {
 	case <-ctx.Done():
 		server.Shutdown()
 		if err := <-errCh; err != nil {
-			log.Fatalf("server error: %v", err)
+			slog.Error("server error", "error", err)
+			os.Exit(1)
 		}
 	case err := <-errCh:
 		if err != nil {
-			log.Fatalf("server error: %v", err)
+			slog.Error("server error", "error", err)
+			os.Exit(1)
 		}
 	}
 }
@@ -235,6 +380,15 @@ func (s *Server) registerAppRoutes(r chi.Router) {
 		writeJSON(w, http.StatusOK, s.app.GetInterfaces())
 	})
 
+	r.Get("/interfaces/details", func(w http.ResponseWriter, _ *http.Request) {
+		resp, err := s.app.GetInterfaceDetails()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
 	r.Post("/restart", func(w http.ResponseWriter, _ *http.Request) {
 		result := s.app.RestartApp()
 		writeJSON(w, http.StatusOK, result)
@@ -274,21 +428,44 @@ func (s *Server) registerAppRoutes(r chi.Router) {
 			writeJSONError(w, err)
 			return
 		}
-		privateKeyBytes, err := decodeRealityPrivateKey(payload.PrivateKey)
+		publicKey, err := bridge.RealityPublicKey(payload.PrivateKey)
 		if err != nil {
 			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
 			return
 		}
-		curve := ecdh.X25519()
-		privateKey, err := curve.NewPrivateKey(privateKeyBytes)
+		writeJSON(w, http.StatusOK, map[string]string{
+			"public_key": publicKey,
+		})
+	})
+
+	r.Post("/keys/generate", func(w http.ResponseWriter, r *http.Request) {
+		var payload bridge.KeyGenerateOptions
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.GenerateKeys(payload)
 		if err != nil {
 			writeJSONError(w, err)
 			return
 		}
-		publicKey := privateKey.PublicKey().Bytes()
-		writeJSON(w, http.StatusOK, map[string]string{
-			"public_key": base64.RawStdEncoding.EncodeToString(publicKey),
-		})
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/clash/convert", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Config string `json:"config"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.ConvertClashConfig(payload.Config)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
 	})
 }
 
@@ -361,6 +538,180 @@ func (s *Server) validateToken(token string) bool {
 	return true
 }
 
+func (s *Server) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":  bridge.HealthOK,
+		"version": bridge.Env.AppVersion,
+		"uptime":  int64(time.Since(s.started).Seconds()),
+	})
+}
+
+// handleReadyz answers 503 unless every check passes; ?skip=core leaves out checks by name,
+// for deployments where the core is meant to be stopped at times.
+func (s *Server) handleReadyz(w http.ResponseWriter, r *http.Request) {
+	var skip []string
+	if v := r.URL.Query().Get("skip"); v != "" {
+		skip = strings.Split(v, ",")
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	checks := s.readinessChecks(ctx, skip)
+	status, code := bridge.HealthOK, http.StatusOK
+	if len(failedChecks(checks)) > 0 {
+		status, code = bridge.HealthFail, http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": checks})
+}
+
+func (s *Server) readinessChecks(ctx context.Context, skip []string) map[string]bridge.HealthCheck {
+	checks := map[string]bridge.HealthCheck{}
+	run := func(name string, check func() bridge.HealthCheck) {
+		if slices.Contains(skip, name) {
+			checks[name] = bridge.HealthCheck{Status: bridge.HealthSkipped}
+			return
+		}
+		checks[name] = check()
+	}
+	run("data", s.app.CheckDataDir)
+	run("bus", func() bridge.HealthCheck {
+		if err := s.bus.Check(ctx); err != nil {
+			return bridge.HealthCheck{Status: bridge.HealthFail, Error: err.Error()}
+		}
+		return bridge.HealthCheck{Status: bridge.HealthOK, Detail: map[string]any{"clients": s.bus.Stats().Clients}}
+	})
+	run("core", func() bridge.HealthCheck {
+		return s.app.CheckCore(ctx)
+	})
+	return checks
+}
+
+func failedChecks(checks map[string]bridge.HealthCheck) []string {
+	var failed []string
+	for name, check := range checks {
+		if check.Status == bridge.HealthFail {
+			failed = append(failed, name)
+		}
+	}
+	slices.Sort(failed)
+	return failed
+}
+
+// logMiddleware logs one line per request without the query string or headers, which may
+// carry the token, and with the share token in /sub/ paths masked. Probes and scrapes only
+// show up at debug level.
+func (s *Server) logMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		case r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics":
+			level = slog.LevelDebug
+		}
+		attrs := []any{
+			"method", r.Method,
+			"path", requestLogPath(r.URL.Path),
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration", time.Since(start).Round(time.Microsecond).String(),
+			"remote", r.RemoteAddr,
+		}
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			attrs = append(attrs, "route", rctx.RoutePattern())
+		}
+		slog.Log(r.Context(), level, "http request", attrs...)
+	})
+}
+
+// requestLogPath masks the share token of /sub/{token}, which grants access on its own.
+func requestLogPath(path string) string {
+	if strings.HasPrefix(path, "/sub/") {
+		return "/sub/{token}"
+	}
+	return path
+}
+
+// metricsMiddleware counts requests by the chi route pattern rather than the raw path, so
+// ids and file names do not each become a series.
+func (s *Server) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		s.requests.Inc(r.Method, route, strconv.Itoa(status))
+		s.requestDuration.Observe(time.Since(start).Seconds(), r.Method, route)
+	})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.metricsAllowed(r) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", metrics.ContentType)
+	out := metrics.NewWriter(w)
+	s.requests.Write(out)
+	s.requestDuration.Write(out)
+
+	stats := s.bus.Stats()
+	out.Gauge("guiforcores_websocket_clients", "Connected websocket clients.", float64(stats.Clients))
+	out.Family("guiforcores_bus_events_total", "Events emitted by the server.", "counter")
+	out.Sample("guiforcores_bus_events_total", float64(stats.Emitted))
+	out.Family("guiforcores_bus_dropped_messages_total", "Messages dropped for websocket clients that stopped reading.", "counter")
+	out.Sample("guiforcores_bus_dropped_messages_total", float64(stats.Dropped))
+
+	s.app.WriteMetrics(out)
+	_ = out.Flush()
+}
+
+func (s *Server) metricsAllowed(r *http.Request) bool {
+	if token := getBearerToken(r.Header.Get("Authorization")); token != "" && s.auth.MetricsToken != "" {
+		return subtle.ConstantTimeCompare([]byte(token), []byte(s.auth.MetricsToken)) == 1
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	if s.auth.MetricsToken == "" && len(s.auth.MetricsAllow) == 0 {
+		return addr.IsLoopback()
+	}
+	for _, allow := range s.auth.MetricsAllow {
+		if prefix, err := netip.ParsePrefix(allow); err == nil && prefix.Contains(addr) {
+			return true
+		}
+		if allowed, err := netip.ParseAddr(allow); err == nil && allowed.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Server) handleWebsocket(w http.ResponseWriter, r *http.Request) {
 	token := r.URL.Query().Get("token")
 	if token == "" || !s.validateToken(token) {
@@ -682,113 +1033,843 @@ func (s *Server) registerMMDBRoutes(r chi.Router) {
 	})
 }
 
-func (s *Server) handleCoreProxy(w http.ResponseWriter, r *http.Request) {
-	coreBase := r.Header.Get("X-Core-Base")
-	if coreBase == "" {
-		coreBase = r.URL.Query().Get("coreBase")
-	}
-	if coreBase == "" {
-		http.Error(w, "missing core base", http.StatusBadRequest)
-		return
-	}
-	baseURL, err := url.Parse(coreBase)
-	if err != nil {
-		http.Error(w, "invalid core base", http.StatusBadRequest)
-		return
-	}
-	if !isLoopbackHost(baseURL.Hostname()) {
-		http.Error(w, "core base must be loopback", http.StatusForbidden)
-		return
-	}
-	pathParam := chi.URLParam(r, "*")
-	if !strings.HasPrefix(pathParam, "/") {
-		pathParam = "/" + pathParam
+func (s *Server) registerCoreVersionRoutes(r chi.Router) {
+	type versionPayload struct {
+		Version string `json:"version"`
+		Restart bool   `json:"restart"`
 	}
-	query := r.URL.Query()
-	query.Del("coreBase")
-	query.Del("coreBearer")
-	query.Del("token")
-	rel := &url.URL{Path: pathParam, RawQuery: query.Encode()}
-	targetURL := baseURL.ResolveReference(rel)
-	bearer := r.Header.Get("X-Core-Bearer")
-	if bearer == "" {
-		bearer = r.URL.Query().Get("coreBearer")
-	}
-	if websocket.IsWebSocketUpgrade(r) {
-		s.proxyCoreWebsocket(w, r, targetURL, bearer)
-		return
-	}
-	s.proxyCoreHTTP(w, r, targetURL, bearer)
+
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) {
+		resp, err := s.app.ListCoreVersions()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Put("/source", func(w http.ResponseWriter, r *http.Request) {
+		var payload bridge.CoreReleaseSource
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if err := s.app.SetCoreReleaseSource(payload); err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	r.Post("/install", func(w http.ResponseWriter, r *http.Request) {
+		var payload bridge.CoreInstallOptions
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.InstallCoreVersion(payload)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/activate", func(w http.ResponseWriter, r *http.Request) {
+		var payload versionPayload
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if err := s.app.ActivateCoreVersion(payload.Version, payload.Restart); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"active": payload.Version})
+	})
+
+	r.Post("/rollback", func(w http.ResponseWriter, r *http.Request) {
+		var payload versionPayload
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		version, err := s.app.RollbackCoreVersion(payload.Restart)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"active": version})
+	})
+
+	r.Post("/remove", func(w http.ResponseWriter, r *http.Request) {
+		var payload versionPayload
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if err := s.app.RemoveCoreVersion(payload.Version); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
 }
 
-func (s *Server) proxyCoreHTTP(w http.ResponseWriter, r *http.Request, target *url.URL, bearer string) {
-	var bodyBytes []byte
-	if r.Body != nil {
-		bodyBytes, _ = io.ReadAll(r.Body)
-		r.Body.Close()
+func (s *Server) handleCoreValidate(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Config  json.RawMessage `json:"config"`
+		Timeout int             `json:"timeout"`
 	}
-	req, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), bytes.NewReader(bodyBytes))
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := decodeJSON(r, &payload); err != nil {
+		writeJSONError(w, err)
 		return
 	}
-	copyHeaders(req.Header, r.Header)
-	req.Header.Del("Host")
-	req.Header.Del("Content-Length")
-	if bearer != "" {
-		req.Header.Set("Authorization", "Bearer "+bearer)
+	config := rawConfig(payload.Config)
+	if len(config) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "empty config"})
+		return
 	}
-	resp, err := coreHTTPClient.Do(req)
+	resp, err := s.app.ValidateCoreConfig(config, payload.Timeout)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadGateway)
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
 		return
 	}
-	defer resp.Body.Close()
-	copyHeaders(w.Header(), resp.Header)
-	w.WriteHeader(resp.StatusCode)
-	_, _ = io.Copy(w, resp.Body)
+	writeJSON(w, http.StatusOK, resp)
 }
 
-func (s *Server) proxyCoreWebsocket(w http.ResponseWriter, r *http.Request, target *url.URL, bearer string) {
-	wsURL := *target
-	switch wsURL.Scheme {
-	case "http":
-		wsURL.Scheme = "ws"
-	case "https":
-		wsURL.Scheme = "wss"
+func (s *Server) handleCoreApply(w http.ResponseWriter, r *http.Request) {
+	var payload struct {
+		Config json.RawMessage `json:"config"`
+		bridge.CoreApplyOptions
 	}
-	header := http.Header{}
-	if bearer != "" {
-		header.Set("Authorization", "Bearer "+bearer)
+	if err := decodeJSON(r, &payload); err != nil {
+		writeJSONError(w, err)
+		return
 	}
-	backendConn, resp, err := websocket.DefaultDialer.Dial(wsURL.String(), header)
-	if err != nil {
-		status := http.StatusBadGateway
-		message := err.Error()
-		if resp != nil {
-			status = resp.StatusCode
-			message = resp.Status
-		}
-		http.Error(w, message, status)
+	config := rawConfig(payload.Config)
+	if len(config) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "empty config"})
 		return
 	}
-	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
-	clientConn, err := upgrader.Upgrade(w, r, nil)
+	resp, err := s.app.ApplyCoreConfig(config, payload.CoreApplyOptions)
 	if err != nil {
-		backendConn.Close()
+		writeJSONError(w, err)
 		return
 	}
-	errCh := make(chan error, 2)
-	go proxyWebsocketPump(clientConn, backendConn, errCh)
-	go proxyWebsocketPump(backendConn, clientConn, errCh)
-	<-errCh
-	backendConn.Close()
-	clientConn.Close()
+	writeJSON(w, http.StatusOK, resp)
 }
 
-func proxyWebsocketPump(src, dst *websocket.Conn, errCh chan<- error) {
-	for {
-		msgType, msg, err := src.ReadMessage()
+func (s *Server) registerSubscriptionRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) {
+		resp, err := s.app.ListSubscriptions()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/update", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ID string `json:"id"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.UpdateSubscription(payload.ID)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Get("/quota", func(w http.ResponseWriter, _ *http.Request) {
+		resp, err := s.app.Quota.List()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Get("/quota/history", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := s.app.Quota.History(r.URL.Query().Get("id"))
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func (s *Server) registerRulesetRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) {
+		resp, err := s.app.ListRulesets()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/update", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			ID string `json:"id"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.UpdateRuleset(payload.ID)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/match", func(w http.ResponseWriter, r *http.Request) {
+		var payload bridge.RulesetMatchOptions
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.MatchRulesets(payload)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/compile", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Path string `json:"path"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		output, err := s.app.CompileRuleset(payload.Path)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"path": output})
+	})
+}
+
+func (s *Server) registerConvertRoutes(r chi.Router) {
+	r.Post("/links", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Links []string `json:"links"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.app.ConvertShareLinks(payload.Links))
+	})
+
+	r.Post("/export", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Outbounds []map[string]any `json:"outbounds"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.app.ExportShareLinks(payload.Outbounds))
+	})
+}
+
+func (s *Server) registerTLSRoutes(r chi.Router) {
+	certificateHandler := func(create func(bridge.TLSCertificateOptions) (bridge.TLSCertificateResult, error)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var payload bridge.TLSCertificateOptions
+			if err := decodeJSON(r, &payload); err != nil {
+				writeJSONError(w, err)
+				return
+			}
+			resp, err := create(payload)
+			if err != nil {
+				writeJSONError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, resp)
+		}
+	}
+
+	r.Post("/self-signed", certificateHandler(s.app.CreateSelfSignedCertificate))
+	r.Post("/ca", certificateHandler(s.app.CreateCertificateAuthority))
+	r.Post("/issue", certificateHandler(s.app.IssueCertificate))
+
+	r.Post("/inspect", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Path string `json:"path"`
+			PEM  string `json:"pem"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.InspectCertificate(payload.Path, payload.PEM)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/ech", func(w http.ResponseWriter, r *http.Request) {
+		var payload bridge.ECHKeyOptions
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.GenerateECHKeyPair(payload)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func (s *Server) registerShareRoutes(r chi.Router) {
+	r.Get("/tokens", func(w http.ResponseWriter, _ *http.Request) {
+		resp, err := s.app.ListShareTokens()
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	r.Post("/tokens", func(w http.ResponseWriter, r *http.Request) {
+		var payload bridge.ShareTokenOptions
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.CreateShareToken(payload)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+
+	tokenHandler := func(action func(string) error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			var payload struct {
+				ID string `json:"id"`
+			}
+			if err := decodeJSON(r, &payload); err != nil {
+				writeJSONError(w, err)
+				return
+			}
+			if err := action(payload.ID); err != nil {
+				writeJSONError(w, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		}
+	}
+
+	r.Post("/tokens/revoke", tokenHandler(s.app.RevokeShareToken))
+	r.Post("/tokens/remove", tokenHandler(s.app.RemoveShareToken))
+
+	r.Post("/qrcode", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			bridge.QRCodeOptions
+			Content string `json:"content"`
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		image, contentType, err := bridge.RenderQRCode(payload.Content, payload.QRCodeOptions)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(image)
+	})
+
+	r.Post("/qrcodes", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			bridge.ShareSelection
+			bridge.QRCodeOptions
+		}
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		resp, err := s.app.ShareQRCodes(payload.ShareSelection, payload.QRCodeOptions)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+// handleShareSubscription serves GET /sub/{token}. It sits outside /api auth: the token
+// itself is the credential, and it is read-only.
+func (s *Server) handleShareSubscription(w http.ResponseWriter, r *http.Request) {
+	content, err := s.app.ServeShareToken(chi.URLParam(r, "token"))
+	if errors.Is(err, bridge.ErrShareTokenNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", content.ContentType)
+	w.Header().Set("Content-Disposition", "attachment; filename*=UTF-8''"+url.PathEscape(content.Name))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(content.Body)
+}
+
+func (s *Server) registerSchedulerRoutes(r chi.Router) {
+	type idPayload struct {
+		ID string `json:"id"`
+	}
+
+	r.Get("/status", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Scheduler.Status())
+	})
+
+	r.Get("/history", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Scheduler.History(r.URL.Query().Get("id")))
+	})
+
+	r.Post("/reload", func(w http.ResponseWriter, _ *http.Request) {
+		if err := s.app.Scheduler.Reload(); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.app.Scheduler.Status())
+	})
+
+	r.Post("/run", func(w http.ResponseWriter, r *http.Request) {
+		var payload idPayload
+		if err := decodeJSON(r, &payload); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		run, err := s.app.Scheduler.RunTask(payload.ID)
+		if err != nil && run.TaskID == "" {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, run)
+	})
+}
+
+func (s *Server) registerStatsRoutes(r chi.Router) {
+	r.Get("/status", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Stats.Status())
+	})
+	r.Get("/traffic", func(w http.ResponseWriter, r *http.Request) {
+		var query bridge.TrafficQuery
+		var err error
+		if query.From, query.To, query.Step, err = parseStatsRange(r.URL.Query()); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		history, err := s.app.Stats.QueryTraffic(query)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, history)
+	})
+	r.Get("/usage", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		query := bridge.UsageQuery{By: params.Get("by")}
+		if query.By == "" {
+			query.By = bridge.UsageByClient
+		}
+		var err error
+		if query.From, query.To, query.Step, err = parseStatsRange(params); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if v := params.Get("limit"); v != "" {
+			if query.Limit, err = strconv.Atoi(v); err != nil {
+				writeJSONError(w, errors.New("invalid limit"))
+				return
+			}
+		}
+		report, err := s.app.Stats.QueryUsage(query)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if params.Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+			w.Header().Set("Content-Disposition", `attachment; filename="usage-`+report.By+`.csv"`)
+			if err := bridge.WriteUsageCSV(w, report); err != nil {
+				slog.Error("WriteUsageCSV", "error", err)
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, report)
+	})
+	r.Get("/clients", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.ListClientNames())
+	})
+	r.Post("/clients", func(w http.ResponseWriter, r *http.Request) {
+		var names []bridge.ClientName
+		if err := decodeJSON(r, &names); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if err := s.app.SaveClientNames(names); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+func (s *Server) registerLatencyRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Prober.Status())
+	})
+	r.Get("/history", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Prober.History(r.URL.Query().Get("name")))
+	})
+	r.Post("/probe", func(w http.ResponseWriter, r *http.Request) {
+		outbounds, err := s.app.Prober.Probe(r.Context())
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, outbounds)
+	})
+	r.Post("/reload", func(w http.ResponseWriter, _ *http.Request) {
+		s.app.Prober.Reload()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+func (s *Server) registerFailoverRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Failover.Status())
+	})
+	r.Get("/events", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Failover.Events())
+	})
+	r.Post("/pin", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Group    string `json:"group"`
+			Outbound string `json:"outbound"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if err := s.app.Failover.Pin(r.Context(), req.Group, req.Outbound); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	r.Post("/unpin", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Group string `json:"group"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		s.app.Failover.Unpin(req.Group)
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	r.Post("/evaluate", func(w http.ResponseWriter, r *http.Request) {
+		events, err := s.app.Failover.Evaluate(r.Context())
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, events)
+	})
+}
+
+func (s *Server) registerAlertRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, s.app.Alerts.Status())
+	})
+	r.Post("/test", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Channel string `json:"channel"`
+			Title   string `json:"title"`
+			Message string `json:"message"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		if req.Title == "" {
+			req.Title = "Test alert"
+		}
+		if err := s.app.Alerts.Test(r.Context(), req.Channel, req.Title, req.Message); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	r.Post("/reload", func(w http.ResponseWriter, _ *http.Request) {
+		s.app.Alerts.Reload()
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+func (s *Server) registerInboxRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		query := bridge.InboxQuery{
+			Unread: params.Get("unread") == "true" || params.Get("unread") == "1",
+			Source: params.Get("source"),
+		}
+		if v := params.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil {
+				writeJSONError(w, errors.New("invalid limit"))
+				return
+			}
+			query.Limit = limit
+		}
+		writeJSON(w, http.StatusOK, s.app.Inbox.List(query))
+	})
+	r.Post("/read", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			IDs []string `json:"ids"` // every notification when empty
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"changed": s.app.Inbox.MarkRead(req.IDs)})
+	})
+	r.Post("/clear", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			IDs      []string `json:"ids"`
+			ReadOnly bool     `json:"readOnly"`
+		}
+		if err := decodeJSON(r, &req); err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]int{"removed": s.app.Inbox.Clear(req.IDs, req.ReadOnly)})
+	})
+}
+
+func (s *Server) registerLogRoutes(r chi.Router) {
+	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		query := bridge.LogQuery{
+			Level:  params.Get("level"),
+			Search: params.Get("q"),
+		}
+		if v := params.Get("limit"); v != "" {
+			limit, err := strconv.Atoi(v)
+			if err != nil {
+				writeJSONError(w, errors.New("invalid limit"))
+				return
+			}
+			query.Limit = limit
+		}
+		if v := params.Get("since"); v != "" {
+			since, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				writeJSONError(w, errors.New("invalid since"))
+				return
+			}
+			query.Since = since
+		}
+		entries, err := s.app.QueryLogs(query)
+		if err != nil {
+			writeJSONError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, entries)
+	})
+}
+
+// parseStatsRange reads from/to (unix milliseconds) and step, where a plain number is seconds
+// and anything else a Go duration such as "1h".
+func parseStatsRange(params url.Values) (from, to int64, step time.Duration, err error) {
+	if v := params.Get("from"); v != "" {
+		if from, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, 0, 0, errors.New("invalid from")
+		}
+	}
+	if v := params.Get("to"); v != "" {
+		if to, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return 0, 0, 0, errors.New("invalid to")
+		}
+	}
+	if v := params.Get("step"); v != "" {
+		if seconds, convErr := strconv.Atoi(v); convErr == nil {
+			step = time.Duration(seconds) * time.Second
+		} else if step, err = time.ParseDuration(v); err != nil {
+			return 0, 0, 0, errors.New("invalid step")
+		}
+	}
+	return from, to, step, nil
+}
+
+// handleCoreProxy forwards a request to the Clash API of the active core config, adding its
+// secret. A client-supplied coreBase is only accepted when it names that same controller.
+func (s *Server) handleCoreProxy(w http.ResponseWriter, r *http.Request) {
+	api, err := bridge.ActiveClashAPI()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	coreBase := r.Header.Get("X-Core-Base")
+	if coreBase == "" {
+		coreBase = r.URL.Query().Get("coreBase")
+	}
+	if coreBase == "" {
+		coreBase = api.Base
+	}
+	baseURL, err := url.Parse(coreBase)
+	if err != nil {
+		http.Error(w, "invalid core base", http.StatusBadRequest)
+		return
+	}
+	if !isLoopbackHost(baseURL.Hostname()) || !bridge.SameClashAPIBase(api, coreBase) {
+		http.Error(w, "core base does not match the configured core", http.StatusForbidden)
+		return
+	}
+	pathParam := chi.URLParam(r, "*")
+	if !strings.HasPrefix(pathParam, "/") {
+		pathParam = "/" + pathParam
+	}
+	query := r.URL.Query()
+	query.Del("coreBase")
+	query.Del("coreBearer")
+	query.Del("token")
+	rel := &url.URL{Path: pathParam, RawQuery: query.Encode()}
+	targetURL := baseURL.ResolveReference(rel)
+	if websocket.IsWebSocketUpgrade(r) {
+		s.proxyCoreWebsocket(w, r, targetURL, api.Secret)
+		return
+	}
+	s.proxyCoreHTTP(w, r, targetURL, api.Secret)
+}
+
+func (s *Server) proxyCoreHTTP(w http.ResponseWriter, r *http.Request, target *url.URL, bearer string) {
+	ctx := r.Context()
+	stream := slices.Contains(coreStreamPaths, strings.TrimSuffix(target.Path, "/"))
+	if !stream {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, coreRequestTimeout)
+		defer cancel()
+	}
+
+	body := r.Body
+	if r.ContentLength == 0 {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, r.Method, target.String(), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.ContentLength = r.ContentLength
+	copyHeaders(req.Header, r.Header)
+	req.Header.Del("Host")
+	req.Header.Del("Content-Length")
+	// Neither the panel session token nor the routing headers belong to the core
+	req.Header.Del("Authorization")
+	req.Header.Del("X-Core-Base")
+	req.Header.Del("X-Core-Bearer")
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	resp, err := coreHTTPClient.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	copyHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	copyFlushing(w, resp.Body)
+}
+
+// copyFlushing forwards a response body chunk by chunk, flushing after each write so
+// streamed JSON lines reach the browser as soon as the core emits them.
+func copyFlushing(w http.ResponseWriter, src io.Reader) {
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := src.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return
+			}
+			if flushErr := rc.Flush(); flushErr != nil && !errors.Is(flushErr, http.ErrNotSupported) {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) proxyCoreWebsocket(w http.ResponseWriter, r *http.Request, target *url.URL, bearer string) {
+	wsURL := *target
+	switch wsURL.Scheme {
+	case "http":
+		wsURL.Scheme = "ws"
+	case "https":
+		wsURL.Scheme = "wss"
+	}
+	header := http.Header{}
+	if bearer != "" {
+		header.Set("Authorization", "Bearer "+bearer)
+	}
+	backendConn, resp, err := websocket.DefaultDialer.Dial(wsURL.String(), header)
+	if err != nil {
+		status := http.StatusBadGateway
+		message := err.Error()
+		if resp != nil {
+			status = resp.StatusCode
+			message = resp.Status
+		}
+		http.Error(w, message, status)
+		return
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	clientConn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		backendConn.Close()
+		return
+	}
+	errCh := make(chan error, 2)
+	go proxyWebsocketPump(clientConn, backendConn, errCh)
+	go proxyWebsocketPump(backendConn, clientConn, errCh)
+	<-errCh
+	backendConn.Close()
+	clientConn.Close()
+}
+
+func proxyWebsocketPump(src, dst *websocket.Conn, errCh chan<- error) {
+	for {
+		msgType, msg, err := src.ReadMessage()
 		if err != nil {
 			errCh <- err
 			return
@@ -850,39 +1931,16 @@ func decodeJSON(r *http.Request, v any) error {
 	return json.Unmarshal(body, v)
 }
 
-func decodeRealityPrivateKey(value string) ([]byte, error) {
-	value = strings.TrimSpace(value)
-	if value == "" {
-		return nil, errors.New("empty private key")
-	}
-	if decoded, err := base64.RawStdEncoding.DecodeString(value); err == nil {
-		return decoded, nil
-	}
-	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
-		return decoded, nil
-	}
-	if decoded, err := base64.StdEncoding.DecodeString(padBase64(value)); err == nil {
-		return decoded, nil
-	}
-	if decoded, err := base64.URLEncoding.DecodeString(padBase64(value)); err == nil {
-		return decoded, nil
-	}
-	value = strings.TrimPrefix(value, "0x")
-	if decoded, err := hex.DecodeString(value); err == nil {
-		return decoded, nil
-	}
-	return nil, errors.New("invalid private key encoding")
-}
-
-func padBase64(value string) string {
-	if value == "" {
-		return value
+// rawConfig accepts a core config sent either as a JSON object or as its serialized string form.
+func rawConfig(raw json.RawMessage) []byte {
+	if string(raw) == "null" {
+		return nil
 	}
-	padding := len(value) % 4
-	if padding == 0 {
-		return value
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return bytes.TrimSpace([]byte(text))
 	}
-	return value + strings.Repeat("=", 4-padding)
+	return bytes.TrimSpace(raw)
 }
 
 func (s *Server) spaHandler() http.HandlerFunc {
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func TestLogMiddlewareMasksShareToken(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	s := &Server{}
+	router := chi.NewRouter()
+	router.Use(s.logMiddleware)
+	router.Get("/sub/{token}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	const token = "s3cr3tShareToken0123456789"
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/sub/"+token, nil))
+
+	out := buf.String()
+	if !strings.Contains(out, "http request") {
+		t.Fatalf("request was not logged: %q", out)
+	}
+	if strings.Contains(out, token) {
+		t.Errorf("share token leaked into the log: %s", out)
+	}
+}
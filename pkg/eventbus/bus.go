@@ -1,9 +1,12 @@
 package eventbus
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sync"
+	"sync/atomic"
 
 	"github.com/gorilla/websocket"
 )
@@ -11,6 +14,10 @@ import (
 // Handler defines a callback invoked when the client emits an event.
 type Handler func(payload []any)
 
+// Observer defines a callback invoked for every event the server emits. It runs on the
+// emitting goroutine, so it should hand work off rather than block.
+type Observer func(event string, payload []any)
+
 // Bus maintains websocket clients and server-side handlers.
 type Bus struct {
 	mu sync.RWMutex
@@ -22,8 +29,22 @@ type Bus struct {
 	// events emitted by clients.
 	handlers map[string]map[int]Handler
 
+	// observers receive every event emitted by the server.
+	observers map[int]Observer
+
 	nextHandlerID int
 	upgrader      websocket.Upgrader
+
+	clients atomic.Int64
+	emitted atomic.Uint64
+	dropped atomic.Uint64
+}
+
+// Stats are counters of the bus since it was created.
+type Stats struct {
+	Clients int    // connected websocket clients
+	Emitted uint64 // events emitted by the server
+	Dropped uint64 // messages not delivered to a client that stopped reading
 }
 
 // New creates a new event bus instance.
@@ -31,6 +52,7 @@ func New() *Bus {
 	return &Bus{
 		subscribers: make(map[string]map[*Client]struct{}),
 		handlers:    make(map[string]map[int]Handler),
+		observers:   make(map[int]Observer),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true
@@ -46,6 +68,7 @@ func (b *Bus) ServeWS(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	client := newClient(b, conn)
+	b.clients.Add(1)
 	go client.readLoop()
 	go client.writeLoop()
 }
@@ -56,13 +79,54 @@ func (b *Bus) Emit(event string, payload ...any) {
 	if err != nil {
 		return
 	}
+	b.emitted.Add(1)
 
 	b.mu.RLock()
-	defer b.mu.RUnlock()
-
 	for client := range b.subscribers[event] {
 		client.queue(data)
 	}
+	observers := make([]Observer, 0, len(b.observers))
+	for _, observer := range b.observers {
+		observers = append(observers, observer)
+	}
+	b.mu.RUnlock()
+
+	for _, observer := range observers {
+		observer(event, payload)
+	}
+}
+
+func (b *Bus) Stats() Stats {
+	return Stats{
+		Clients: int(max(b.clients.Load(), 0)),
+		Emitted: b.emitted.Load(),
+		Dropped: b.dropped.Load(),
+	}
+}
+
+// Check takes the bus lock exclusively, failing when an Emit stuck on a client or a
+// deadlock holds it past ctx.
+func (b *Bus) Check(ctx context.Context) error {
+	locked := make(chan struct{})
+	go func() {
+		b.mu.Lock()
+		b.mu.Unlock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		return nil
+	case <-ctx.Done():
+		return errors.New("event bus is blocked")
+	}
+}
+
+// HasSubscribers reports whether any websocket client is subscribed to an event.
+func (b *Bus) HasSubscribers(event string) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.subscribers[event]) > 0
 }
 
 // Subscribe registers a client for an event.
@@ -127,6 +191,22 @@ func (b *Bus) On(event string, handler Handler) func() {
 	}
 }
 
+// Observe registers a server-side observer for every emitted event.
+func (b *Bus) Observe(observer Observer) func() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextHandlerID++
+	id := b.nextHandlerID
+	b.observers[id] = observer
+
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.observers, id)
+	}
+}
+
 // emitFromClient dispatches an event emitted by a client to server-side handlers.
 func (b *Bus) emitFromClient(event string, payload []any) {
 	b.mu.RLock()
@@ -32,10 +32,25 @@ func newClient(bus *Bus, conn *websocket.Conn) *Client {
 	}
 }
 
+// queueTimeout bounds how long Emit waits on a client whose send buffer is full.
+const queueTimeout = 5 * time.Second
+
 func (c *Client) queue(payload []byte) {
+	select {
+	case c.send <- payload:
+		return
+	case <-c.closed:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(queueTimeout)
+	defer timer.Stop()
 	select {
 	case c.send <- payload:
 	case <-c.closed:
+	case <-timer.C:
+		c.bus.dropped.Add(1)
 	}
 }
 
@@ -104,6 +119,7 @@ func (c *Client) close() {
 		close(c.closed)
 	}
 	c.bus.removeClient(c)
+	c.bus.clients.Add(-1)
 	c.conn.Close()
 	close(c.send)
 }
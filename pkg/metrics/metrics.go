@@ -0,0 +1,211 @@
+// Package metrics implements the small part of the Prometheus text exposition format the
+// server needs: labelled counters, histograms and one-off gauges written at scrape time.
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the default histogram upper bounds, in seconds.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Writer writes metric families. Samples of one family must be written together, right after
+// its Family call.
+type Writer struct {
+	w *bufio.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Family starts a metric family; kind is counter, gauge or histogram.
+func (w *Writer) Family(name, help, kind string) {
+	w.w.WriteString("# HELP " + name + " " + escape(help, false) + "\n")
+	w.w.WriteString("# TYPE " + name + " " + kind + "\n")
+}
+
+// Sample writes one value. labels alternate names and values.
+func (w *Writer) Sample(name string, value float64, labels ...string) {
+	w.w.WriteString(name)
+	if len(labels) > 1 {
+		w.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			w.w.WriteString(labels[i] + `="` + escape(labels[i+1], true) + `"`)
+		}
+		w.w.WriteByte('}')
+	}
+	w.w.WriteByte(' ')
+	w.w.WriteString(formatValue(value))
+	w.w.WriteByte('\n')
+}
+
+// Gauge writes a family holding a single unlabelled value.
+func (w *Writer) Gauge(name, help string, value float64) {
+	w.Family(name, help, "gauge")
+	w.Sample(name, value)
+}
+
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// CounterVec is a counter partitioned by a fixed set of labels.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*counterValue
+}
+
+type counterValue struct {
+	labels []string
+	value  float64
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{name: name, help: help, labels: labels, values: make(map[string]*counterValue)}
+}
+
+// Add increases the counter of the given label values, in the order of the label names.
+func (c *CounterVec) Add(delta float64, values ...string) {
+	key := strings.Join(values, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	v, ok := c.values[key]
+	if !ok {
+		v = &counterValue{labels: slices.Clone(values)}
+		c.values[key] = v
+	}
+	v.value += delta
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) Write(w *Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w.Family(c.name, c.help, "counter")
+	for _, key := range sortedKeys(c.values) {
+		v := c.values[key]
+		w.Sample(c.name, v.value, pairs(c.labels, v.labels)...)
+	}
+}
+
+// HistogramVec is a histogram partitioned by a fixed set of labels.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*histogramValue
+}
+
+type histogramValue struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: make(map[string]*histogramValue)}
+}
+
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	key := strings.Join(values, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v, ok := h.values[key]
+	if !ok {
+		v = &histogramValue{labels: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = v
+	}
+	if i, _ := slices.BinarySearch(h.buckets, value); i < len(h.buckets) {
+		v.counts[i]++
+	}
+	v.count++
+	v.sum += value
+}
+
+func (h *HistogramVec) Write(w *Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	w.Family(h.name, h.help, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		v := h.values[key]
+		labels := pairs(h.labels, v.labels)
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += v.counts[i]
+			w.Sample(h.name+"_bucket", float64(cumulative), append(slices.Clone(labels), "le", formatValue(bound))...)
+		}
+		w.Sample(h.name+"_bucket", float64(v.count), append(slices.Clone(labels), "le", "+Inf")...)
+		w.Sample(h.name+"_sum", v.sum, labels...)
+		w.Sample(h.name+"_count", float64(v.count), labels...)
+	}
+}
+
+func pairs(names, values []string) []string {
+	labels := make([]string, 0, len(names)*2)
+	for i, name := range names {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		labels = append(labels, name, value)
+	}
+	return labels
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escape(s string, quoted bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quoted {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}